@@ -2,6 +2,7 @@ package http
 
 import (
 	"os"
+	"strings"
 
 	"github.com/sibeur/gotaro/apps/http/handler"
 	"github.com/sibeur/gotaro/core/common"
@@ -9,33 +10,65 @@ import (
 
 	"github.com/gofiber/contrib/fiberzap/v2"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/gofiber/fiber/v2/utils"
 	"go.uber.org/zap"
 )
 
 // FiberApp represents a Fiber application.
 type FiberApp struct {
-	Instance      *fiber.App
-	Svc           *service.Service
-	ruleHandler   *handler.RuleHandler
-	driverHandler *handler.DriverHandler
-	mediaHandler  *handler.MediaHandler
-	authHandlerV1 *handler.AuthHandlerV1
+	Instance                   *fiber.App
+	Svc                        *service.Service
+	ruleHandler                *handler.RuleHandler
+	driverHandler              *handler.DriverHandler
+	mediaHandler               *handler.MediaHandler
+	authHandlerV1              *handler.AuthHandlerV1
+	migrationHandler           *handler.MigrationHandler
+	apiClientHandler           *handler.ApiClientHandler
+	roleHandler                *handler.RoleHandler
+	personalAccessTokenHandler *handler.PersonalAccessTokenHandler
+	uploadTokenHandler         *handler.UploadTokenHandler
+	auditEventHandler          *handler.AuditEventHandler
 }
 
 // NewFiberApp creates a new instance of FiberApp.
 func NewFiberApp(service *service.Service) *FiberApp {
-	instance := fiber.New(fiber.Config{
-		ErrorHandler: common.FiberDefaultErrorHandler,
-	})
+	instance := fiber.New(newFiberConfig())
 	return &FiberApp{
-		Instance:      instance,
-		Svc:           service,
-		ruleHandler:   handler.NewRuleHandler(instance, service),
-		driverHandler: handler.NewDriverHandler(instance, service),
-		mediaHandler:  handler.NewMediaHandler(instance, service),
-		authHandlerV1: handler.NewAuthHandlerV1(instance, service),
+		Instance:                   instance,
+		Svc:                        service,
+		ruleHandler:                handler.NewRuleHandler(instance, service),
+		driverHandler:              handler.NewDriverHandler(instance, service),
+		mediaHandler:               handler.NewMediaHandler(instance, service),
+		authHandlerV1:              handler.NewAuthHandlerV1(instance, service),
+		migrationHandler:           handler.NewMigrationHandler(instance, service),
+		apiClientHandler:           handler.NewApiClientHandler(instance, service),
+		roleHandler:                handler.NewRoleHandler(instance, service),
+		personalAccessTokenHandler: handler.NewPersonalAccessTokenHandler(instance, service),
+		uploadTokenHandler:         handler.NewUploadTokenHandler(instance, service),
+		auditEventHandler:          handler.NewAuditEventHandler(instance, service),
+	}
+}
+
+// newFiberConfig reads the client IP, which the login lockouts and audit events rely on, from the
+// header of the load balancer when one is set. The header is only trusted from the trusted proxies.
+func newFiberConfig() fiber.Config {
+	config := fiber.Config{
+		ErrorHandler: common.FiberDefaultErrorHandler,
+	}
+	if proxyHeader := os.Getenv("PROXY_HEADER"); proxyHeader != "" {
+		config.ProxyHeader = proxyHeader
+		config.EnableIPValidation = true
+		config.EnableTrustedProxyCheck = true
+		for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				config.TrustedProxies = append(config.TrustedProxies, proxy)
+			}
+		}
 	}
+	return config
 }
 
 // beforeMiddlewares sets up the middlewares to be executed before the main request handler.
@@ -48,15 +81,30 @@ func (f *FiberApp) beforeMiddlewares() {
 		logger, _ = zap.NewProduction()
 	}
 
+	// Add request id middleware, the id of a request is logged and stored with its audit events
+	f.Instance.Use(requestid.New(requestid.Config{
+		Generator:  utils.UUIDv4,
+		ContextKey: "request_id",
+	}))
+
 	// Add fiberzap middleware
 	f.Instance.Use(fiberzap.New(fiberzap.Config{
 		Logger: logger,
+		Fields: []string{"requestId", "latency", "status", "method", "url"},
 	}))
 
 	// Add recover middleware
 	f.Instance.Use(recover.New(recover.Config{
 		EnableStackTrace: true,
 	}))
+
+	// Allow the browsers of these origins to call gotaro, e.g. to upload with an upload token
+	if allowOrigins := os.Getenv("CORS_ALLOW_ORIGINS"); allowOrigins != "" {
+		f.Instance.Use(cors.New(cors.Config{
+			AllowOrigins: allowOrigins,
+			AllowHeaders: "Authorization, Content-Type",
+		}))
+	}
 }
 
 // afterMiddlewares sets up the middlewares to be executed after the main request handler.
@@ -75,8 +123,19 @@ func (f *FiberApp) Run() {
 	f.ruleHandler.Router()
 	f.driverHandler.Router()
 	f.mediaHandler.Router()
+	f.migrationHandler.Router()
+	f.apiClientHandler.Router()
+	f.roleHandler.Router()
+	f.personalAccessTokenHandler.Router()
+	f.uploadTokenHandler.Router()
+	f.auditEventHandler.Router()
 	f.afterMiddlewares()
 	if err := f.Instance.Listen(":3000"); err != nil {
 		panic(err)
 	}
 }
+
+// Shutdown stops accepting requests and waits for the in-flight ones to finish.
+func (f *FiberApp) Shutdown() error {
+	return f.Instance.Shutdown()
+}
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestFiberConfigClientIP(t *testing.T) {
+	// the requests of app.Test come from 0.0.0.0
+	tests := []struct {
+		name           string
+		proxyHeader    string
+		trustedProxies string
+		forwardedFor   string
+		expected       string
+	}{
+		{name: "no proxy header", forwardedFor: "203.0.113.7", expected: "0.0.0.0"},
+		{name: "trusted proxy", proxyHeader: "X-Forwarded-For", trustedProxies: "0.0.0.0", forwardedFor: "203.0.113.7", expected: "203.0.113.7"},
+		{name: "trusted proxy range", proxyHeader: "X-Forwarded-For", trustedProxies: "10.0.0.1, 0.0.0.0/8", forwardedFor: "203.0.113.7", expected: "203.0.113.7"},
+		{name: "untrusted proxy", proxyHeader: "X-Forwarded-For", trustedProxies: "10.0.0.1", forwardedFor: "203.0.113.7", expected: "0.0.0.0"},
+		{name: "no trusted proxy", proxyHeader: "X-Forwarded-For", forwardedFor: "203.0.113.7", expected: "0.0.0.0"},
+		{name: "invalid forwarded ip", proxyHeader: "X-Forwarded-For", trustedProxies: "0.0.0.0", forwardedFor: "not-an-ip", expected: "0.0.0.0"},
+	}
+	for _, test := range tests {
+		t.Setenv("PROXY_HEADER", test.proxyHeader)
+		t.Setenv("TRUSTED_PROXIES", test.trustedProxies)
+
+		app := fiber.New(newFiberConfig())
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString(c.IP())
+		})
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Forwarded-For", test.forwardedFor)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%v: Error send request: %v", test.name, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != test.expected {
+			t.Errorf("%v: Expected %v, got %v", test.name, test.expected, string(body))
+		}
+	}
+}
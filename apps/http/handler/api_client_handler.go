@@ -0,0 +1,351 @@
+package handler
+
+import (
+	"log"
+	"time"
+
+	"github.com/sibeur/gotaro/apps/http/handler/dto"
+	"github.com/sibeur/gotaro/apps/http/handler/middleware"
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ApiClientHandler struct {
+	fiberInstance *fiber.App
+	svc           *service.Service
+}
+
+func NewApiClientHandler(fiberInstance *fiber.App, svc *service.Service) *ApiClientHandler {
+	return &ApiClientHandler{
+		fiberInstance: fiberInstance,
+		svc:           svc,
+	}
+}
+
+func (h *ApiClientHandler) Router() {
+	apiClients := h.fiberInstance.Group("/v1").Group("/api-clients", middleware.VerifyAuth(h.svc), middleware.RateLimit(h.svc, common.EndpointClassManage), middleware.RequirePermission(h.svc, common.PermissionAll))
+	apiClients.Get("/", h.findAllApiClients)
+	apiClients.Post("/", h.createApiClient)
+	apiClients.Get("/:id", h.findApiClientByID)
+	apiClients.Put("/:id", h.updateApiClient)
+	apiClients.Post("/:id/rotate-secret", h.rotateApiClientSecret)
+	apiClients.Post("/:id/rotate-signing-secret", h.rotateApiClientSigningSecret)
+	apiClients.Post("/:id/revoke-tokens", h.revokeApiClientTokens)
+	apiClients.Post("/:id/unlock-login", h.unlockApiClientLogin)
+	apiClients.Post("/:id/disable", h.disableApiClient)
+	apiClients.Post("/:id/enable", h.enableApiClient)
+	apiClients.Get("/:id/usage", h.findApiClientUsage)
+	apiClients.Get("/:id/personal-access-tokens", h.findApiClientPersonalAccessTokens)
+	apiClients.Delete("/:id/personal-access-tokens/:tokenID", h.deleteApiClientPersonalAccessToken)
+	apiClients.Delete("/:id", h.deleteApiClient)
+}
+
+func (h *ApiClientHandler) findAllApiClients(c *fiber.Ctx) error {
+	clients, err := h.svc.ApiClient.FindAll()
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	response := make([]common.GotaroMap, 0)
+	for _, client := range clients {
+		response = append(response, client.ToJSON())
+	}
+
+	return successResponse(c, "", response, nil)
+}
+
+// createApiClient returns the secret key of the new client, it can not be read again afterwards.
+func (h *ApiClientHandler) createApiClient(c *fiber.Ctx) error {
+	clientData := new(dto.NewAPIClientDTO)
+
+	if err := c.BodyParser(clientData); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+	}
+
+	fValidator := common.NewFiberValidator()
+
+	if errs := fValidator.Validate(clientData); len(errs) > 0 {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, errs, nil)
+	}
+
+	client := &entity.APIClient{
+		Name:   clientData.Name,
+		Scopes: common.UniqueArrayString(clientData.Scopes),
+		Limits: newAPIClientLimits(clientData.Limits),
+	}
+
+	secretKey, err := h.svc.ApiClient.Create(client)
+	if err != nil {
+		if err.Error() == common.ErrRoleNotFoundMsg {
+			return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+	h.recordAudit(c, common.AuditActionCreate, nil, client.ID)
+
+	response := client.ToJSON()
+	response["secret_key"] = secretKey
+	return successResponse(c, "", response, nil)
+}
+
+func (h *ApiClientHandler) findApiClientByID(c *fiber.Ctx) error {
+	client, err := h.svc.ApiClient.FindByID(c.Params("id"))
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	if client == nil {
+		return errorResponse(c, fiber.StatusNotFound, common.ErrAPIClientNotFoundMsg, nil, nil)
+	}
+
+	return successResponse(c, "", client.ToJSON(), nil)
+}
+
+func (h *ApiClientHandler) updateApiClient(c *fiber.Ctx) error {
+	clientData := new(dto.EditAPIClientDTO)
+
+	if err := c.BodyParser(clientData); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+	}
+
+	fValidator := common.NewFiberValidator()
+
+	if errs := fValidator.Validate(clientData); len(errs) > 0 {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, errs, nil)
+	}
+
+	client := &entity.APIClient{
+		ID:     c.Params("id"),
+		Name:   clientData.Name,
+		Scopes: common.UniqueArrayString(clientData.Scopes),
+		Limits: newAPIClientLimits(clientData.Limits),
+	}
+
+	before, _ := h.svc.ApiClient.FindByID(client.ID)
+	// a client changing its own scopes could grant itself any permission
+	if client.ID == c.Locals("user_id") && (before == nil || !common.IsSameStringSet(before.Scopes, client.Scopes)) {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrAPIClientSelfChangeMsg, nil, nil)
+	}
+
+	err := h.svc.ApiClient.Update(client)
+	if err == nil {
+		h.recordAudit(c, common.AuditActionUpdate, before, client.ID)
+	}
+	return h.apiClientResponse(c, client, err)
+}
+
+// rotateApiClientSecret returns the new secret key of the client, it can not be read again afterwards.
+func (h *ApiClientHandler) rotateApiClientSecret(c *fiber.Ctx) error {
+	rotateData := new(dto.RotateAPIClientSecretDTO)
+
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(rotateData); err != nil {
+			return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+	}
+
+	fValidator := common.NewFiberValidator()
+
+	if errs := fValidator.Validate(rotateData); len(errs) > 0 {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, errs, nil)
+	}
+
+	var overlap *time.Duration
+	if rotateData.OverlapMinutes != nil {
+		minutes := time.Minute * time.Duration(*rotateData.OverlapMinutes)
+		overlap = &minutes
+	}
+
+	before, _ := h.svc.ApiClient.FindByID(c.Params("id"))
+	client, secretKey, err := h.svc.ApiClient.RotateSecret(c.Params("id"), overlap)
+	if err != nil {
+		return h.apiClientResponse(c, nil, err)
+	}
+	h.recordAudit(c, common.AuditActionRotateSecret, before, client.ID)
+
+	response := client.ToJSON()
+	response["secret_key"] = secretKey
+	return successResponse(c, "", response, nil)
+}
+
+// rotateApiClientSigningSecret returns the new request signing secret of the client, it can not be
+// read again afterwards.
+func (h *ApiClientHandler) rotateApiClientSigningSecret(c *fiber.Ctx) error {
+	before, _ := h.svc.ApiClient.FindByID(c.Params("id"))
+	client, signingSecret, err := h.svc.ApiClient.RotateSigningSecret(c.Params("id"))
+	if err != nil {
+		return h.apiClientResponse(c, nil, err)
+	}
+	h.recordAudit(c, common.AuditActionRotateSigningSecret, before, client.ID)
+
+	response := client.ToJSON()
+	response["signing_secret"] = signingSecret
+	return successResponse(c, "", response, nil)
+}
+
+func (h *ApiClientHandler) revokeApiClientTokens(c *fiber.Ctx) error {
+	before, _ := h.svc.ApiClient.FindByID(c.Params("id"))
+	client, err := h.svc.ApiClient.RevokeTokens(c.Params("id"))
+	if err == nil {
+		h.recordAudit(c, common.AuditActionRevokeTokens, before, client.ID)
+	}
+	return h.apiClientResponse(c, client, err)
+}
+
+// unlockApiClientLogin lifts the lockout and delay of the key of the client after failed logins.
+func (h *ApiClientHandler) unlockApiClientLogin(c *fiber.Ctx) error {
+	unlockData := new(dto.UnlockAPIClientLoginDTO)
+
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(unlockData); err != nil {
+			return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+	}
+
+	fValidator := common.NewFiberValidator()
+
+	if errs := fValidator.Validate(unlockData); len(errs) > 0 {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, errs, nil)
+	}
+
+	client, err := h.svc.ApiClient.FindByID(c.Params("id"))
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	if client == nil {
+		return errorResponse(c, fiber.StatusNotFound, common.ErrAPIClientNotFoundMsg, nil, nil)
+	}
+
+	if err := h.svc.Auth.UnlockLogin(client.Key, unlockData.IP); err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	event := newAuditEvent(c, common.AuditActionUnlockLogin, common.AuditResourceAPIClient, client.ID)
+	if unlockData.IP != "" {
+		event.Details = common.GotaroMap{"ip": unlockData.IP}
+	}
+	h.svc.Audit.Record(event, nil, nil)
+
+	return successResponse(c, "", client.ToJSON(), nil)
+}
+
+func (h *ApiClientHandler) disableApiClient(c *fiber.Ctx) error {
+	if c.Params("id") == c.Locals("user_id") {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrAPIClientSelfChangeMsg, nil, nil)
+	}
+
+	before, _ := h.svc.ApiClient.FindByID(c.Params("id"))
+	client, err := h.svc.ApiClient.SetDisabled(c.Params("id"), true)
+	if err == nil {
+		h.recordAudit(c, common.AuditActionDisable, before, client.ID)
+	}
+	return h.apiClientResponse(c, client, err)
+}
+
+func (h *ApiClientHandler) enableApiClient(c *fiber.Ctx) error {
+	before, _ := h.svc.ApiClient.FindByID(c.Params("id"))
+	client, err := h.svc.ApiClient.SetDisabled(c.Params("id"), false)
+	if err == nil {
+		h.recordAudit(c, common.AuditActionEnable, before, client.ID)
+	}
+	return h.apiClientResponse(c, client, err)
+}
+
+func (h *ApiClientHandler) deleteApiClient(c *fiber.Ctx) error {
+	if c.Params("id") == c.Locals("user_id") {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrAPIClientSelfChangeMsg, nil, nil)
+	}
+
+	before, _ := h.svc.ApiClient.FindByID(c.Params("id"))
+	err := h.svc.ApiClient.Delete(c.Params("id"))
+	if err != nil {
+		if err.Error() == common.ErrAPIClientNotFoundMsg {
+			return errorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		}
+		if err.Error() == common.ErrAPIClientLastAdminMsg {
+			return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+	h.recordAudit(c, common.AuditActionDelete, before, c.Params("id"))
+
+	return successResponse(c, "", nil, nil)
+}
+
+// findApiClientUsage returns the bytes the client uploaded today and this month with its quotas.
+func (h *ApiClientHandler) findApiClientUsage(c *fiber.Ctx) error {
+	client, err := h.svc.ApiClient.FindByID(c.Params("id"))
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	if client == nil {
+		return errorResponse(c, fiber.StatusNotFound, common.ErrAPIClientNotFoundMsg, nil, nil)
+	}
+
+	usage, err := h.svc.RateLimit.GetUploadUsage(client.ID)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	return successResponse(c, "", usage, nil)
+}
+
+func (h *ApiClientHandler) findApiClientPersonalAccessTokens(c *fiber.Ctx) error {
+	return personalAccessTokensResponse(c, h.svc, c.Params("id"))
+}
+
+func (h *ApiClientHandler) deleteApiClientPersonalAccessToken(c *fiber.Ctx) error {
+	return deletePersonalAccessTokenResponse(c, h.svc, c.Params("id"), c.Params("tokenID"))
+}
+
+func (h *ApiClientHandler) apiClientResponse(c *fiber.Ctx, client *entity.APIClient, err error) error {
+	if err != nil {
+		switch err.Error() {
+		case common.ErrAPIClientNotFoundMsg:
+			return errorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		case common.ErrRoleNotFoundMsg, common.ErrMasterKeyNotSetMsg, common.ErrAPIClientLastAdminMsg:
+			return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		case common.ErrAPIClientSecretRotateMsg:
+			return errorResponse(c, fiber.StatusConflict, err.Error(), nil, nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	return successResponse(c, "", client.ToJSON(), nil)
+}
+
+// recordAudit records an action on an api client with its state before, nil for a creation, and its
+// current state, nil once deleted.
+func (h *ApiClientHandler) recordAudit(c *fiber.Ctx, action string, before *entity.APIClient, id string) {
+	var beforeJSON, afterJSON common.GotaroMap
+	if before != nil {
+		beforeJSON = before.ToJSON()
+	}
+
+	after, err := h.svc.ApiClient.FindByID(id)
+	if err != nil {
+		log.Printf("Error finding api client %v to audit: %v", id, err)
+	}
+	if after != nil {
+		afterJSON = after.ToJSON()
+	}
+	h.svc.Audit.Record(newAuditEvent(c, action, common.AuditResourceAPIClient, id), beforeJSON, afterJSON)
+}
+
+func newAPIClientLimits(limitsData *dto.APIClientLimitsDTO) *entity.APIClientLimits {
+	if limitsData == nil {
+		return nil
+	}
+	return &entity.APIClientLimits{
+		UploadRequestsPerMinute: limitsData.UploadRequestsPerMinute,
+		ReadRequestsPerMinute:   limitsData.ReadRequestsPerMinute,
+		ManageRequestsPerMinute: limitsData.ManageRequestsPerMinute,
+		UploadBytesPerDay:       limitsData.UploadBytesPerDay,
+		UploadBytesPerMonth:     limitsData.UploadBytesPerMonth,
+	}
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"github.com/sibeur/gotaro/core/entity"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newAuditEvent returns the audit event of an action done by the client of a request.
+func newAuditEvent(c *fiber.Ctx, action string, resourceType string, resourceID string) *entity.AuditEvent {
+	clientID, _ := c.Locals("user_id").(string)
+	requestID, _ := c.Locals("request_id").(string)
+	return &entity.AuditEvent{
+		Action:        action,
+		ResourceType:  resourceType,
+		ResourceID:    resourceID,
+		ActorClientID: clientID,
+		ActorIP:       c.IP(),
+		RequestID:     requestID,
+	}
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"bufio"
+	"log"
+	"time"
+
+	"github.com/sibeur/gotaro/apps/http/handler/dto"
+	"github.com/sibeur/gotaro/apps/http/handler/middleware"
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuditEventHandler struct {
+	fiberInstance *fiber.App
+	svc           *service.Service
+}
+
+func NewAuditEventHandler(fiberInstance *fiber.App, svc *service.Service) *AuditEventHandler {
+	return &AuditEventHandler{
+		fiberInstance: fiberInstance,
+		svc:           svc,
+	}
+}
+
+func (h *AuditEventHandler) Router() {
+	auditEvents := h.fiberInstance.Group("/v1").Group("/audit-events", middleware.VerifyAuth(h.svc), middleware.RateLimit(h.svc, common.EndpointClassManage), middleware.RequirePermission(h.svc, common.PermissionAuditRead))
+	auditEvents.Get("/", h.findAllAuditEvents)
+	auditEvents.Get("/export", h.exportAuditEvents)
+}
+
+func (h *AuditEventHandler) findAllAuditEvents(c *fiber.Ctx) error {
+	query, filter, errs := parseAuditEventQuery(c)
+	if len(errs) > 0 {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, errs, nil)
+	}
+
+	events, err := h.svc.Audit.FindAll(filter, query.Limit)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	response := make([]common.GotaroMap, 0)
+	for _, event := range events {
+		response = append(response, event.ToJSON())
+	}
+
+	return successResponse(c, "", response, nil)
+}
+
+// exportAuditEvents streams every matching event as newline delimited JSON, or CSV with format=csv.
+func (h *AuditEventHandler) exportAuditEvents(c *fiber.Ctx) error {
+	query, filter, errs := parseAuditEventQuery(c)
+	if len(errs) > 0 {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, errs, nil)
+	}
+
+	format := query.Format
+	if format == "" {
+		format = common.AuditExportFormatNDJSON
+	}
+	contentType := "application/x-ndjson"
+	if format == common.AuditExportFormatCSV {
+		contentType = "text/csv"
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="audit-events.`+format+`"`)
+
+	// the events are written as they are read, the export can be larger than a page
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := h.svc.Audit.Export(filter, format, w); err != nil {
+			log.Printf("Error exporting audit events: %v", err)
+		}
+		if err := w.Flush(); err != nil {
+			log.Printf("Error flushing audit events export: %v", err)
+		}
+	})
+	return nil
+}
+
+func parseAuditEventQuery(c *fiber.Ctx) (*dto.AuditEventQueryDTO, *entity.AuditEventFilter, []common.FiberErrorMessage) {
+	query := new(dto.AuditEventQueryDTO)
+	if err := c.QueryParser(query); err != nil {
+		return nil, nil, []common.FiberErrorMessage{common.NewFiberErrorMessage("Query", err.Error())}
+	}
+
+	fValidator := common.NewFiberValidator()
+
+	if errs := fValidator.Validate(query); len(errs) > 0 {
+		return nil, nil, errs
+	}
+
+	filter := &entity.AuditEventFilter{
+		Action:        query.Action,
+		ResourceType:  query.ResourceType,
+		ResourceID:    query.ResourceID,
+		ActorClientID: query.ActorClientID,
+		RequestID:     query.RequestID,
+	}
+	// the times are already validated
+	if query.From != "" {
+		filter.From, _ = time.Parse(time.RFC3339, query.From)
+	}
+	if query.To != "" {
+		filter.To, _ = time.Parse(time.RFC3339, query.To)
+	}
+	return query, filter, nil
+}
@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"strconv"
+
 	"github.com/sibeur/gotaro/apps/http/handler/dto"
+	"github.com/sibeur/gotaro/apps/http/handler/middleware"
 	"github.com/sibeur/gotaro/core/common"
 	"github.com/sibeur/gotaro/core/service"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type AuthHandlerV1 struct {
@@ -24,7 +28,11 @@ func (h *AuthHandlerV1) Router() {
 	auth := h.fiberInstance.Group("/v1").Group("/auth")
 	auth.Post("/login", h.login)
 	auth.Get("/refresh-token", h.refreshToken)
+	auth.Post("/logout", middleware.VerifyAuth(h.svc), middleware.VerifyAuthMethods([]string{common.AuthMethodJWT}), h.logout)
+
+	h.fiberInstance.Get("/.well-known/jwks.json", h.jwks)
 }
+
 func (h *AuthHandlerV1) login(c *fiber.Ctx) error {
 
 	authData := new(dto.AuthDTO)
@@ -39,30 +47,65 @@ func (h *AuthHandlerV1) login(c *fiber.Ctx) error {
 		return common.ErrorResponse(c, fiber.StatusBadRequest, "Validation error", errs, nil)
 	}
 
-	response, err := h.svc.Auth.Login(authData.APIKey, authData.SecretKey)
+	response, err := h.svc.Auth.Login(authData.APIKey, authData.SecretKey, c.IP())
 	if err != nil {
-		if err.Error() == common.ErrAuthenticationFailedMsg {
+		switch err.Error() {
+		case common.ErrAuthenticationFailedMsg:
+			h.svc.Audit.RecordLogin(newAuditEvent(c, common.AuditActionLoginFailed, "", ""), authData.APIKey)
 			return common.ErrorResponse(c, fiber.StatusUnauthorized, err.Error(), nil, nil)
+		case common.ErrLoginLockedMsg:
+			h.svc.Audit.RecordLogin(newAuditEvent(c, common.AuditActionLoginLocked, "", ""), authData.APIKey)
+			retryAfter := h.svc.Auth.GetLoginRetryAfter(authData.APIKey, c.IP())
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(retryAfter.Seconds())+1))
+			return common.ErrorResponse(c, fiber.StatusTooManyRequests, err.Error(), nil, nil)
 		}
 		return common.ErrorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
 	}
+	h.svc.Audit.RecordLogin(newAuditEvent(c, common.AuditActionLogin, "", ""), authData.APIKey)
 
 	return common.SuccessResponse(c, "Berhasil login", response, nil)
 }
 
 func (h *AuthHandlerV1) refreshToken(c *fiber.Ctx) error {
 	// get refresh token from header
-	refreshToken := c.Get("Authorization")
-
-	// remove "Bearer " from token
-	refreshToken = refreshToken[7:]
+	refreshToken, isExist := common.GetBearerToken(c)
+	if !isExist {
+		return common.ErrorResponse(c, fiber.StatusUnauthorized, common.ErrJWTTokenInvalidMsg, nil, nil)
+	}
 
 	response, err := h.svc.Auth.RefreshToken(refreshToken)
 	if err != nil {
-		if err.Error() == common.ErrJWTTokenInvalidMsg {
+		switch err.Error() {
+		case common.ErrJWTTokenInvalidMsg, common.ErrJWTTokenRevokedMsg, common.ErrJWTTokenReusedMsg:
 			return common.ErrorResponse(c, fiber.StatusUnauthorized, err.Error(), nil, nil)
 		}
 		return common.ErrorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
 	}
 	return common.SuccessResponse(c, "Berhasil refresh token", response, nil)
 }
+
+// logout revokes the access token of the request and the refresh token given in the body.
+func (h *AuthHandlerV1) logout(c *fiber.Ctx) error {
+	logoutData := new(dto.LogoutDTO)
+
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(logoutData); err != nil {
+			return common.ErrorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+	}
+
+	err := h.svc.Auth.Logout(c.Locals("token").(*jwt.Token), logoutData.RefreshToken)
+	if err != nil {
+		if err.Error() == common.ErrJWTTokenInvalidMsg {
+			return common.ErrorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		return common.ErrorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+	return common.SuccessResponse(c, "Berhasil logout", nil, nil)
+}
+
+// jwks publishes the public keys verifying the tokens, as is, for the JWT libraries to consume.
+func (h *AuthHandlerV1) jwks(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(h.svc.Auth.GetJWKS())
+}
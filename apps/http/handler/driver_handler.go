@@ -1,12 +1,13 @@
 package handler
 
 import (
-	"encoding/json"
+	"log"
 
 	"github.com/sibeur/gotaro/apps/http/handler/dto"
 	"github.com/sibeur/gotaro/apps/http/handler/middleware"
 	"github.com/sibeur/gotaro/core/common"
 	"github.com/sibeur/gotaro/core/common/driver"
+	"github.com/sibeur/gotaro/core/common/secret"
 	"github.com/sibeur/gotaro/core/entity"
 	"github.com/sibeur/gotaro/core/service"
 
@@ -28,12 +29,23 @@ func NewDriverHandler(fiberInstance *fiber.App, svc *service.Service) *DriverHan
 func (h *DriverHandler) Router() {
 	driver := h.fiberInstance.
 		Group("/v1").
-		Group("/drivers", middleware.VerifyAuth(h.svc), middleware.VerifyAuthAudiences([]string{common.APIClientSuperAdminScope}))
+		Group("/drivers", middleware.VerifyAuth(h.svc), middleware.RateLimit(h.svc, common.EndpointClassManage), middleware.RequirePermission(h.svc, common.PermissionDriverManage))
 	driver.Get("/", h.findAllDrivers)
 	driver.Get("/:slug", h.findDriverBySlug)
+	driver.Get("/:slug/health", h.findDriverHealth)
 	driver.Post("/", h.createDriver)
+	driver.Post("/validate", h.validateDriver)
+	driver.Post("/rotate-secrets", h.rotateDriverSecrets)
 	driver.Put("/:slug", h.updateDriver)
 	driver.Delete("/:slug", h.deleteDriver)
+	driver.Get("/:slug/reconciliations", h.findReconciliationJobs)
+	driver.Post("/:slug/reconciliations", h.createReconciliationJob)
+	driver.Get("/:slug/reconciliations/:id", h.findReconciliationJobByID)
+
+	driverTypes := h.fiberInstance.
+		Group("/v1").
+		Group("/driver-types", middleware.VerifyAuth(h.svc), middleware.RateLimit(h.svc, common.EndpointClassManage), middleware.RequirePermission(h.svc, common.PermissionDriverManage))
+	driverTypes.Get("/", h.findAllDriverTypes)
 }
 
 func (h *DriverHandler) findAllDrivers(c *fiber.Ctx) error {
@@ -44,7 +56,21 @@ func (h *DriverHandler) findAllDrivers(c *fiber.Ctx) error {
 
 	response := make([]common.GotaroMap, 0)
 	for _, driver := range drivers {
-		response = append(response, driver.ToJSONSimple())
+		driverJSON := driver.ToJSONSimple()
+		driverJSON["health"] = nil
+		if health := h.svc.Driver.HealthMonitor.GetHealth(driver.Slug); health != nil {
+			driverJSON["health"] = health.ToJSON()
+		}
+		response = append(response, driverJSON)
+	}
+
+	return successResponse(c, "Success", response, nil)
+}
+
+func (h *DriverHandler) findAllDriverTypes(c *fiber.Ctx) error {
+	response := make([]common.GotaroMap, 0)
+	for _, driverType := range h.svc.Driver.FindAllTypes() {
+		response = append(response, driverType.ToJSON())
 	}
 
 	return successResponse(c, "Success", response, nil)
@@ -64,6 +90,21 @@ func (h *DriverHandler) findDriverBySlug(c *fiber.Ctx) error {
 	return successResponse(c, "", driver.ToJSON(), nil)
 }
 
+func (h *DriverHandler) findDriverHealth(c *fiber.Ctx) error {
+	driverSlug := c.Params("slug")
+
+	driver, err := h.svc.Driver.FindBySlug(driverSlug)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	if driver == nil {
+		return errorResponse(c, fiber.StatusNotFound, common.ErrDriverNotFoundMsg, nil, nil)
+	}
+
+	return successResponse(c, "", h.svc.Driver.GetHealth(driverSlug).ToJSON(), nil)
+}
+
 func (h *DriverHandler) createDriver(c *fiber.Ctx) error {
 	driverData := new(dto.NewDriverDTO)
 
@@ -89,15 +130,7 @@ func (h *DriverHandler) createDriver(c *fiber.Ctx) error {
 		Type: driverData.Type,
 	}
 
-	switch driverData.Type {
-	case uint32(driver.GCSDriverType):
-		errs, err := validateGCSConfig(driverInput, driverData.DriverConfig, c)
-		if err != nil {
-			return err
-		}
-		validatorErrs = append(validatorErrs, errs...)
-
-	}
+	validatorErrs = append(validatorErrs, buildDriverConfig(driverInput, driverData.DriverConfig, false)...)
 
 	if len(validatorErrs) > 0 {
 		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, validatorErrs, nil)
@@ -109,6 +142,7 @@ func (h *DriverHandler) createDriver(c *fiber.Ctx) error {
 		}
 		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
 	}
+	h.recordAudit(c, common.AuditActionCreate, nil, driverInput.Slug)
 
 	return successResponse(c, "", driverInput.ToJSONSimple(), nil)
 }
@@ -135,22 +169,26 @@ func (h *DriverHandler) updateDriver(c *fiber.Ctx) error {
 		Type: driverData.Type,
 	}
 
-	switch driverData.Type {
-	case uint32(driver.GCSDriverType):
-		errs, err := validateGCSConfig(driverInput, driverData.DriverConfig, c)
-		if err != nil {
-			return err
-		}
-		validatorErrs = append(validatorErrs, errs...)
-	}
+	validatorErrs = append(validatorErrs, buildDriverConfig(driverInput, driverData.DriverConfig, true)...)
 
 	if len(validatorErrs) > 0 {
 		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, validatorErrs, nil)
 	}
-	err := h.svc.Driver.Update(driverInput)
+	before, err := h.svc.Driver.FindBySlug(driverSlug)
 	if err != nil {
 		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
 	}
+	err = h.svc.Driver.Update(driverInput)
+	if err != nil {
+		switch err.Error() {
+		case common.ErrDriverNotFoundMsg:
+			return errorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		case common.ErrDriverSecretMissingMsg:
+			return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+	h.recordAudit(c, common.AuditActionUpdate, before, driverSlug)
 
 	return successResponse(c, "", driverInput.ToJSONSimple(), nil)
 }
@@ -171,33 +209,170 @@ func (h *DriverHandler) deleteDriver(c *fiber.Ctx) error {
 	if err != nil {
 		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
 	}
+	h.recordAudit(c, common.AuditActionDelete, driver, driverSlug)
 
 	return successResponse(c, "", nil, nil)
 }
 
-func validateGCSConfig(driverInput *entity.Driver, driverConfigInput any, c *fiber.Ctx) ([]common.FiberErrorMessage, error) {
+func (h *DriverHandler) validateDriver(c *fiber.Ctx) error {
+	driverData := new(dto.ValidateDriverDTO)
+
+	if err := c.BodyParser(driverData); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+	}
+
 	validatorErrs := make([]common.FiberErrorMessage, 0)
+	fValidator := common.NewFiberValidator()
+
+	if errs := fValidator.Validate(driverData); len(errs) > 0 {
+		validatorErrs = append(validatorErrs, errs...)
+	}
+
+	driverInput := &entity.Driver{
+		Type: driverData.Type,
+	}
+
+	validatorErrs = append(validatorErrs, buildDriverConfig(driverInput, driverData.DriverConfig, false)...)
+
+	if len(validatorErrs) > 0 {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, validatorErrs, nil)
+	}
+
+	return successResponse(c, "", h.svc.Driver.Validate(driverInput).ToJSON(), nil)
+}
 
-	driverConfigJSON, err := json.Marshal(driverConfigInput)
+func (h *DriverHandler) rotateDriverSecrets(c *fiber.Ctx) error {
+	rotatedCount, err := h.svc.Driver.RotateSecrets()
 	if err != nil {
-		return nil, errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		if err.Error() == common.ErrMasterKeyNotSetMsg {
+			return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
 	}
-	driverConfig := new(dto.GCSDriverConfigDTO)
-	if err := json.Unmarshal(driverConfigJSON, &driverConfig); err != nil {
-		return nil, errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
 
+	// the rotated secrets are not part of the driver states, the event only counts them
+	event := newAuditEvent(c, common.AuditActionRotateSecrets, common.AuditResourceDriver, "")
+	event.Details = common.GotaroMap{"rotated_count": rotatedCount}
+	h.svc.Audit.Record(event, nil, nil)
+
+	return successResponse(c, "", common.GotaroMap{"rotated_count": rotatedCount}, nil)
+}
+
+// recordAudit records an action on a driver with its state before, nil for a creation, and its
+// current state, nil once deleted.
+func (h *DriverHandler) recordAudit(c *fiber.Ctx, action string, before *entity.Driver, driverSlug string) {
+	var beforeJSON, afterJSON common.GotaroMap
+	resourceID := ""
+	if before != nil {
+		beforeJSON = before.ToJSON()
+		resourceID = before.ID
 	}
-	serviceAccountJSON, err := driverConfig.GetServiceAccountJSONBytes()
+
+	after, err := h.svc.Driver.FindBySlug(driverSlug)
 	if err != nil {
-		errServiceAccount := common.NewFiberErrorMessage("ServiceAccount", err.Error())
-		validatorErrs = append(validatorErrs, errServiceAccount)
+		log.Printf("Error finding driver %v to audit: %v", driverSlug, err)
 	}
+	if after != nil {
+		afterJSON = after.ToJSON()
+		resourceID = after.ID
+	}
+	// the secrets are masked alike whatever their value, a changed one is marked instead
+	if before != nil && after != nil {
+		afterDriverConfig := afterJSON["driver_config"].(map[string]any)
+		for _, field := range after.GetChangedSecretFields(before) {
+			afterDriverConfig[field] = common.AuditSecretChangedValue
+		}
+	}
+	h.svc.Audit.Record(newAuditEvent(c, action, common.AuditResourceDriver, resourceID), beforeJSON, afterJSON)
+}
 
-	gcsValidator := common.NewFiberValidator()
-	if errs := gcsValidator.Validate(driverConfig); len(errs) > 0 {
-		validatorErrs = append(validatorErrs, errs...)
+func (h *DriverHandler) findReconciliationJobs(c *fiber.Ctx) error {
+	jobs, err := h.svc.Reconciliation.FindByDriver(c.Params("slug"))
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	response := make([]common.GotaroMap, 0)
+	for _, job := range jobs {
+		response = append(response, job.ToJSONSimple())
+	}
+
+	return successResponse(c, "", response, nil)
+}
+
+func (h *DriverHandler) createReconciliationJob(c *fiber.Ctx) error {
+	jobData := new(dto.NewReconciliationJobDTO)
+
+	if err := c.BodyParser(jobData); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+	}
+
+	fValidator := common.NewFiberValidator()
+
+	if errs := fValidator.Validate(jobData); len(errs) > 0 {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, errs, nil)
 	}
-	driverInput.DriverConfig = driver.NewGCSDriverConfig(driverConfig.ProjectID, driverConfig.BucketName, driverConfig.DefaultFolder, serviceAccountJSON)
 
-	return validatorErrs, nil
+	job := &entity.ReconciliationJob{
+		DriverSlug:      c.Params("slug"),
+		Prefix:          jobData.Prefix,
+		UntrackedAction: jobData.UntrackedAction,
+		ImportRuleSlug:  jobData.ImportRuleSlug,
+	}
+
+	err := h.svc.Reconciliation.Create(job)
+	if err != nil {
+		switch err.Error() {
+		case common.ErrDriverNotFoundMsg, common.ErrRuleNotFoundMsg:
+			return errorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		case common.ErrDriverClientNotFoundMsg, common.ErrReconciliationImportRuleMissingMsg:
+			return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	return successResponse(c, "", job.ToJSONSimple(), nil)
+}
+
+func (h *DriverHandler) findReconciliationJobByID(c *fiber.Ctx) error {
+	job, err := h.svc.Reconciliation.FindByID(c.Params("id"))
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	if job == nil || job.DriverSlug != c.Params("slug") {
+		return errorResponse(c, fiber.StatusNotFound, common.ErrReconciliationJobNotFoundMsg, nil, nil)
+	}
+
+	return successResponse(c, "", job.ToJSON(), nil)
+}
+
+// buildDriverConfig validates a driver config against the schema of its driver type and sets it on
+// driverInput. When keepSecret is set omitted or masked secret fields are left empty so the stored
+// ones are kept.
+func buildDriverConfig(driverInput *entity.Driver, driverConfigInput any, keepSecret bool) []common.FiberErrorMessage {
+	driverType := driver.GetDriverType(driver.StorageDriverType(driverInput.Type))
+	if driverType == nil {
+		return []common.FiberErrorMessage{common.NewFiberErrorMessage("Type", common.ErrDriverTypeInvalidMsg)}
+	}
+
+	driverConfig, isObject := driverConfigInput.(map[string]any)
+	if keepSecret && isObject {
+		for _, field := range driverType.SecretFields {
+			if value, isExist := driverConfig[field]; isExist && (value == nil || value == "" || value == secret.MaskValue) {
+				delete(driverConfig, field)
+			}
+		}
+	}
+
+	if errs := common.ValidateJSONSchema(driverType.GetConfigSchema(keepSecret), driverConfigInput, "driver_config"); len(errs) > 0 {
+		return errs
+	}
+
+	storedConfig, err := driverType.NewConfig(driverConfig)
+	if err != nil {
+		return []common.FiberErrorMessage{common.NewFiberErrorMessage("driver_config", err.Error())}
+	}
+	driverInput.DriverConfig = storedConfig
+	return nil
 }
@@ -0,0 +1,33 @@
+package dto
+
+type NewAPIClientDTO struct {
+	Name   string              `json:"name" validate:"required"`
+	Scopes []string            `json:"scopes" validate:"required,min=1,dive,required"`
+	Limits *APIClientLimitsDTO `json:"limits"`
+}
+
+// RotateAPIClientSecretDTO sets how long the previous secret stays valid, the configured overlap is
+// used when it is not given.
+type RotateAPIClientSecretDTO struct {
+	OverlapMinutes *uint32 `json:"overlap_minutes" validate:"omitempty,lte=43200"`
+}
+
+// UnlockAPIClientLoginDTO also unlocks the logins from an IP when given.
+type UnlockAPIClientLoginDTO struct {
+	IP string `json:"ip" validate:"omitempty,ip"`
+}
+
+type EditAPIClientDTO struct {
+	Name   string              `json:"name" validate:"required"`
+	Scopes []string            `json:"scopes" validate:"required,min=1,dive,required"`
+	Limits *APIClientLimitsDTO `json:"limits"`
+}
+
+// APIClientLimitsDTO sets the rate limits and upload quotas of a client, zero or missing for no limit.
+type APIClientLimitsDTO struct {
+	UploadRequestsPerMinute uint32 `json:"upload_requests_per_minute"`
+	ReadRequestsPerMinute   uint32 `json:"read_requests_per_minute"`
+	ManageRequestsPerMinute uint32 `json:"manage_requests_per_minute"`
+	UploadBytesPerDay       uint64 `json:"upload_bytes_per_day"`
+	UploadBytesPerMonth     uint64 `json:"upload_bytes_per_month"`
+}
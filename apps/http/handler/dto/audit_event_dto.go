@@ -0,0 +1,15 @@
+package dto
+
+// AuditEventQueryDTO filters the audit events, From and To are RFC 3339 times. A page ends before the
+// created_at of the last event of the previous one when To is set to it.
+type AuditEventQueryDTO struct {
+	Action        string `query:"action"`
+	ResourceType  string `query:"resource_type"`
+	ResourceID    string `query:"resource_id"`
+	ActorClientID string `query:"actor_client_id"`
+	RequestID     string `query:"request_id"`
+	From          string `query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To            string `query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Limit         int64  `query:"limit" validate:"gte=0,lte=1000"`
+	Format        string `query:"format" validate:"omitempty,oneof=csv ndjson"`
+}
@@ -4,3 +4,7 @@ type AuthDTO struct {
 	APIKey    string `json:"api_key" validate:"required"`
 	SecretKey string `json:"secret_key" validate:"required"`
 }
+
+type LogoutDTO struct {
+	RefreshToken string `json:"refresh_token"`
+}
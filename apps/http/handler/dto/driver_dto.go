@@ -1,7 +1,5 @@
 package dto
 
-import "encoding/json"
-
 type NewDriverDTO struct {
 	Slug         string `json:"slug" validate:"required"`
 	Name         string `json:"name" validate:"required"`
@@ -15,13 +13,13 @@ type EditDriverDTO struct {
 	DriverConfig any    `json:"driver_config" validate:"required"`
 }
 
-type GCSDriverConfigDTO struct {
-	ProjectID      string `json:"project_id" validate:"required"`
-	BucketName     string `json:"bucket_name" validate:"required"`
-	DefaultFolder  string `json:"default_folder" validate:"required"`
-	ServiceAccount any    `json:"service_account" validate:"required"`
+type ValidateDriverDTO struct {
+	Type         uint32 `json:"type" validate:"required"`
+	DriverConfig any    `json:"driver_config" validate:"required"`
 }
 
-func (gdc *GCSDriverConfigDTO) GetServiceAccountJSONBytes() ([]byte, error) {
-	return json.Marshal(gdc.ServiceAccount)
+type NewReconciliationJobDTO struct {
+	Prefix          string `json:"prefix"`
+	UntrackedAction string `json:"untracked_action" validate:"omitempty,oneof=report import quarantine delete"`
+	ImportRuleSlug  string `json:"import_rule_slug"`
 }
@@ -0,0 +1,9 @@
+package dto
+
+type NewMigrationJobDTO struct {
+	RuleSlug         string `json:"rule_slug" validate:"required"`
+	SourceDriverSlug string `json:"source_driver_slug"`
+	TargetDriverSlug string `json:"target_driver_slug" validate:"required"`
+	DeleteSource     bool   `json:"delete_source"`
+	RateLimit        uint32 `json:"rate_limit" validate:"lte=100"`
+}
@@ -0,0 +1,9 @@
+package dto
+
+// NewPersonalAccessTokenDTO creates a token with some scopes of the client, all of them when empty,
+// expiring after the days given or never.
+type NewPersonalAccessTokenDTO struct {
+	Name          string   `json:"name" validate:"required"`
+	Scopes        []string `json:"scopes" validate:"dive,required"`
+	ExpiresInDays uint32   `json:"expires_in_days" validate:"lte=3650"`
+}
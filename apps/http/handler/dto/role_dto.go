@@ -0,0 +1,12 @@
+package dto
+
+type NewRoleDTO struct {
+	Slug        string   `json:"slug" validate:"required"`
+	Name        string   `json:"name" validate:"required"`
+	Permissions []string `json:"permissions" validate:"required,min=1"`
+}
+
+type EditRoleDTO struct {
+	Name        string   `json:"name" validate:"required"`
+	Permissions []string `json:"permissions" validate:"required,min=1"`
+}
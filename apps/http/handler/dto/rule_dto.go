@@ -1,16 +1,33 @@
 package dto
 
 type NewRuleDTO struct {
-	Slug       string   `json:"slug" validate:"required"`
-	Name       string   `json:"name" validate:"required"`
-	MaxSize    uint64   `json:"max_size"`
-	Mimes      []string `json:"mimes"`
-	DriverSlug string   `json:"driver_slug" validate:"required"`
+	Slug               string                `json:"slug" validate:"required"`
+	Name               string                `json:"name" validate:"required"`
+	MaxSize            uint64                `json:"max_size"`
+	Mimes              []string              `json:"mimes"`
+	DriverSlug         string                `json:"driver_slug" validate:"required"`
+	ReplicaDriverSlugs []string              `json:"replica_driver_slugs"`
+	ReplicationPolicy  string                `json:"replication_policy" validate:"omitempty,oneof=sync async"`
+	ObjectOptions      *RuleObjectOptionsDTO `json:"object_options"`
+	IsEncrypted        bool                  `json:"is_encrypted"`
 }
 
 type EditRuleDTO struct {
-	Name       string   `json:"name" validate:"required"`
-	MaxSize    uint64   `json:"max_size"`
-	Mimes      []string `json:"mimes"`
-	DriverSlug string   `json:"driver_slug" validate:"required"`
+	Name               string                `json:"name" validate:"required"`
+	MaxSize            uint64                `json:"max_size"`
+	Mimes              []string              `json:"mimes"`
+	DriverSlug         string                `json:"driver_slug" validate:"required"`
+	ReplicaDriverSlugs []string              `json:"replica_driver_slugs"`
+	ReplicationPolicy  string                `json:"replication_policy" validate:"omitempty,oneof=sync async"`
+	ObjectOptions      *RuleObjectOptionsDTO `json:"object_options"`
+	IsEncrypted        bool                  `json:"is_encrypted"`
+}
+
+type RuleObjectOptionsDTO struct {
+	CacheControl       string            `json:"cache_control" validate:"max=256"`
+	ContentDisposition string            `json:"content_disposition" validate:"omitempty,oneof=inline attachment"`
+	ContentLanguage    string            `json:"content_language" validate:"max=35"`
+	ContentEncoding    string            `json:"content_encoding" validate:"omitempty,oneof=gzip br deflate identity"`
+	StorageClass       string            `json:"storage_class" validate:"omitempty,uppercase"`
+	Metadata           map[string]string `json:"metadata"`
 }
@@ -0,0 +1,12 @@
+package dto
+
+// NewUploadTokenDTO mints an upload token for a rule, the optional constraints narrow the rule ones.
+// MaxSize is in KB like the rule max size.
+type NewUploadTokenDTO struct {
+	RuleSlug         string            `json:"rule_slug" validate:"required"`
+	Directory        string            `json:"directory"`
+	MaxSize          uint64            `json:"max_size"`
+	MaxFiles         uint32            `json:"max_files"`
+	Metadata         map[string]string `json:"metadata" validate:"lte=20"`
+	ExpiresInSeconds uint32            `json:"expires_in_seconds" validate:"lte=3600"`
+}
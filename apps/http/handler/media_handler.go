@@ -3,6 +3,7 @@ package handler
 import (
 	"io"
 	"log"
+	"mime"
 	"os"
 
 	"github.com/sibeur/gotaro/apps/http/handler/dto"
@@ -27,10 +28,14 @@ func NewMediaHandler(fiberInstance *fiber.App, svc *service.Service) *MediaHandl
 }
 
 func (h *MediaHandler) Router() {
-	medias := h.fiberInstance.Group("/v1").Group("/medias", middleware.VerifyAuth(h.svc))
-	medias.Post("/get-batch", h.getMediaBatch)
-	medias.Post("/:slug", middleware.VerifyAuthAudiences([]string{common.APIClientSuperAdminScope, common.APIClientUploaderScope}), h.uploadMedia)
-	medias.Get("/:slug/:fileAliasName", middleware.VerifyAuthAudiences([]string{common.APIClientSuperAdminScope, common.APIClientUploaderScope}), h.getMedia)
+	// uploads also accept the upload tokens, the other routes only the client credentials
+	verifyAuth := middleware.VerifyAuth(h.svc)
+	medias := h.fiberInstance.Group("/v1").Group("/medias")
+	medias.Post("/get-batch", verifyAuth, middleware.RateLimit(h.svc, common.EndpointClassRead), h.getMediaBatch)
+	medias.Post("/:slug", middleware.VerifyUploadAuth(h.svc), middleware.RateLimit(h.svc, common.EndpointClassUpload), middleware.RequireRulePermission(h.svc, common.PermissionMediaUpload), h.uploadMedia)
+	medias.Get("/:slug/download/*", verifyAuth, middleware.RateLimit(h.svc, common.EndpointClassRead), middleware.RequireRulePermission(h.svc, common.PermissionMediaRead), h.downloadMedia)
+	medias.Get("/:slug/:fileAliasName", verifyAuth, middleware.RateLimit(h.svc, common.EndpointClassRead), middleware.RequireRulePermission(h.svc, common.PermissionMediaRead), h.getMedia)
+	medias.Delete("/:slug/*", verifyAuth, middleware.RateLimit(h.svc, common.EndpointClassManage), middleware.RequireRulePermission(h.svc, common.PermissionMediaDelete), h.deleteMedia)
 }
 
 func (h *MediaHandler) findAllMedias(c *fiber.Ctx) error {
@@ -55,6 +60,59 @@ func (h *MediaHandler) uploadMedia(c *fiber.Ctx) error {
 		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
 	}
 
+	ruleSlug := c.Params("slug")
+	directory := c.FormValue("directory")
+	var metadata map[string]string
+
+	// an upload token only uploads within its constraints
+	uploadToken, _ := c.Locals("upload_token").(*entity.UploadToken)
+	if uploadToken != nil {
+		if ruleSlug != uploadToken.RuleSlug {
+			return errorResponse(c, fiber.StatusForbidden, common.ErrUploadTokenRuleMsg, nil, nil)
+		}
+
+		var isAllowed bool
+		directory, isAllowed = uploadToken.GetDirectory(directory)
+		if !isAllowed {
+			return errorResponse(c, fiber.StatusForbidden, common.ErrUploadTokenDirectoryMsg, nil, nil)
+		}
+
+		if uploadToken.MaxSize > 0 && uint64(file.Size)/1024 > uploadToken.MaxSize {
+			return errorResponse(c, fiber.StatusBadRequest, common.ErrFileSizeExceededMsg, nil, nil)
+		}
+		metadata = uploadToken.Metadata
+	}
+
+	// count the file against the upload quotas of the client, given back if the upload fails
+	clientID, _ := c.Locals("user_id").(string)
+	reservation, err := h.svc.RateLimit.ReserveUpload(clientID, uint64(file.Size))
+	if err != nil {
+		if err.Error() == common.ErrUploadQuotaExceededMsg {
+			return errorResponse(c, fiber.StatusTooManyRequests, err.Error(), nil, nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+	isUploaded := false
+	defer func() {
+		if !isUploaded {
+			h.svc.RateLimit.ReleaseUpload(reservation)
+		}
+	}()
+
+	if uploadToken != nil {
+		if err := h.svc.UploadToken.ReserveFile(uploadToken); err != nil {
+			if err.Error() == common.ErrUploadTokenFilesMsg {
+				return errorResponse(c, fiber.StatusForbidden, err.Error(), nil, nil)
+			}
+			return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+		}
+		defer func() {
+			if !isUploaded {
+				h.svc.UploadToken.ReleaseFile(uploadToken)
+			}
+		}()
+	}
+
 	isCommit := false
 	isCommitString := c.FormValue("commit")
 
@@ -62,11 +120,10 @@ func (h *MediaHandler) uploadMedia(c *fiber.Ctx) error {
 		isCommit = true
 	}
 
-	directory := c.FormValue("directory")
-
 	mediaOpts := &entity.MediaUploadOpts{
 		IsCommit:  isCommit,
 		Directory: directory,
+		Metadata:  metadata,
 	}
 
 	// Open the uploaded file
@@ -99,14 +156,17 @@ func (h *MediaHandler) uploadMedia(c *fiber.Ctx) error {
 		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
 	}
 
-	ruleSlug := c.Params("slug")
-
 	media, err := h.svc.Media.Upload(ruleSlug, file.Filename, mediaOpts)
 	if err != nil {
 		log.Printf("Error uploading media: %v", err)
+		if err.Error() == common.ErrDriverUnhealthyMsg {
+			return errorResponse(c, fiber.StatusServiceUnavailable, err.Error(), nil, nil)
+		}
 		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
 	}
 
+	isUploaded = true
+	h.svc.Audit.Record(newAuditEvent(c, common.AuditActionCreate, common.AuditResourceMedia, media.ID), nil, media.ToJSON())
 	return successResponse(c, "", media.ToMediaResult(), nil)
 }
 
@@ -126,6 +186,52 @@ func (h *MediaHandler) getMedia(c *fiber.Ctx) error {
 	return successResponse(c, "", media.ToMediaResult(), nil)
 }
 
+// downloadMedia serves the media content through gotaro, the only way to read an encrypted media.
+func (h *MediaHandler) downloadMedia(c *fiber.Ctx) error {
+	ruleSlug := c.Params("slug")
+	fileAliasName := c.Params("*")
+
+	media, content, size, err := h.svc.Media.Download(ruleSlug, fileAliasName)
+	if err != nil {
+		if err.Error() == common.ErrMediaNotFoundMsg {
+			return errorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	// the content is served from the gotaro origin, it is never rendered as a page
+	c.Set(fiber.HeaderContentType, common.GetDownloadContentType(media.FileMime))
+	c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": media.FileOriginalName}))
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	if media.IsEncrypted() {
+		c.Set(fiber.HeaderCacheControl, "private, no-store")
+	}
+	// the stream is closed once the response is written
+	return c.SendStream(content, size)
+}
+
+func (h *MediaHandler) deleteMedia(c *fiber.Ctx) error {
+	ruleSlug := c.Params("slug")
+	fileAliasName := c.Params("*")
+
+	media, err := h.svc.Media.FindMedia(ruleSlug, fileAliasName)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	if media == nil {
+		return errorResponse(c, fiber.StatusNotFound, common.ErrMediaNotFoundMsg, nil, nil)
+	}
+
+	err = h.svc.Media.Delete(ruleSlug, fileAliasName)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+	h.svc.Audit.Record(newAuditEvent(c, common.AuditActionDelete, common.AuditResourceMedia, media.ID), media.ToJSON(), nil)
+
+	return successResponse(c, "", nil, nil)
+}
+
 func (h *MediaHandler) getMediaBatch(c *fiber.Ctx) error {
 	mediaData := new(dto.GetMediaBatchDTO)
 
@@ -139,6 +245,13 @@ func (h *MediaHandler) getMediaBatch(c *fiber.Ctx) error {
 		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, errs, nil)
 	}
 
-	medias := h.svc.Media.FindMediaBatch(mediaData.Files)
+	permissions, err := middleware.GetPermissions(c, h.svc)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	medias := h.svc.Media.FindMediaBatch(mediaData.Files, func(ruleSlug string) bool {
+		return permissions.Allows(common.PermissionMediaRead, ruleSlug)
+	})
 	return successResponse(c, "", medias, nil)
 }
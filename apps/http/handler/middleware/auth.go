@@ -2,22 +2,81 @@ package middleware
 
 import (
 	"log"
+	"slices"
+	"strings"
 
 	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/common/signing"
+	"github.com/sibeur/gotaro/core/entity"
 	"github.com/sibeur/gotaro/core/service"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// VerifyAuth authenticates a request by its access token, personal access token, token of a trusted
+// OIDC issuer, request signature or api key and secret headers, the same scope checks apply to all
+// of them.
 func VerifyAuth(svc *service.Service) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		// Authenticate a request signed with the signing secret of the client
+		if authorization := c.Get(fiber.HeaderAuthorization); strings.HasPrefix(authorization, signing.RequestSignatureAlgorithm+" ") {
+			client, err := svc.Auth.AuthenticateSignedRequest(authorization, c.Method(), c.OriginalURL(), c.Get(common.HeaderGotaroTimestamp), c.Get(common.HeaderGotaroNonce), c.Body())
+			if err != nil {
+				log.Printf("[VerifyAuth] AuthenticateSignedRequest Error: %v", err)
+				return common.ErrorResponse(c, fiber.StatusUnauthorized, common.ErrUnauthorizedMsg, nil, nil)
+			}
+			c.Locals("user_id", client.ID)
+			c.Locals("role_ids", client.Scopes)
+			c.Locals("auth_method", common.AuthMethodSignedRequest)
+			return c.Next()
+		}
+
+		// Authenticate with the key and secret of the client
+		if apiKey := c.Get(common.HeaderGotaroKey); apiKey != "" {
+			client, err := svc.Auth.AuthenticateAPIKey(apiKey, c.Get(common.HeaderGotaroSecret), c.IP())
+			if err != nil {
+				log.Printf("[VerifyAuth] AuthenticateAPIKey Error: %v", err)
+				recordAPIKeyFailure(c, svc, apiKey, err)
+				return common.ErrorResponse(c, fiber.StatusUnauthorized, common.ErrUnauthorizedMsg, nil, nil)
+			}
+			c.Locals("user_id", client.ID)
+			c.Locals("role_ids", client.Scopes)
+			c.Locals("auth_method", common.AuthMethodAPIKey)
+			return c.Next()
+		}
+
 		// Get token from header
-		bearerToken := c.Get("Authorization")
-		if bearerToken == "" {
+		bearerToken, isExist := common.GetBearerToken(c)
+		if !isExist {
 			log.Printf("[VerifyAuth] Bearer token not found")
 			return common.ErrorResponse(c, fiber.StatusUnauthorized, common.ErrUnauthorizedMsg, nil, nil)
 		}
-		bearerToken = bearerToken[7:]
+
+		// Authenticate with a personal access token
+		if strings.HasPrefix(bearerToken, common.PersonalAccessTokenPrefix) {
+			client, scopes, err := svc.Auth.AuthenticatePersonalAccessToken(bearerToken)
+			if err != nil {
+				log.Printf("[VerifyAuth] AuthenticatePersonalAccessToken Error: %v", err)
+				return common.ErrorResponse(c, fiber.StatusUnauthorized, common.ErrUnauthorizedMsg, nil, nil)
+			}
+			c.Locals("user_id", client.ID)
+			c.Locals("role_ids", scopes)
+			c.Locals("auth_method", common.AuthMethodPersonalAccessToken)
+			return c.Next()
+		}
+
+		// Authenticate with a token of a trusted OIDC issuer, its subject stands for the client
+		if svc.Auth.IsOIDCToken(bearerToken) {
+			identity, err := svc.Auth.AuthenticateOIDCToken(bearerToken)
+			if err != nil {
+				log.Printf("[VerifyAuth] AuthenticateOIDCToken Error: %v", err)
+				return common.ErrorResponse(c, fiber.StatusUnauthorized, common.ErrUnauthorizedMsg, nil, nil)
+			}
+			c.Locals("user_id", identity.GetClientID())
+			c.Locals("role_ids", identity.Scopes)
+			c.Locals("auth_method", common.AuthMethodOIDC)
+			return c.Next()
+		}
 
 		// Verify token
 		token, err := svc.Auth.ValidateToken(bearerToken)
@@ -53,23 +112,60 @@ func VerifyAuth(svc *service.Service) fiber.Handler {
 
 		// Set user_id to locals
 		c.Locals("user_id", subject)
+		// set token to locals
+		c.Locals("token", token)
 		// set role_ids to locals
 		c.Locals("role_ids", []string(audiences))
+		c.Locals("auth_method", common.AuthMethodJWT)
 
 		// Continue stack
 		return c.Next()
 	}
 }
 
+// VerifyUploadAuth authenticates an upload request by an upload token, any other request goes through
+// VerifyAuth. The upload token constraints are checked by the upload handler.
+func VerifyUploadAuth(svc *service.Service) fiber.Handler {
+	verifyAuth := VerifyAuth(svc)
+	return func(c *fiber.Ctx) error {
+		bearerToken, isExist := common.GetBearerToken(c)
+		if !isExist || !strings.HasPrefix(bearerToken, common.UploadTokenPrefix) {
+			return verifyAuth(c)
+		}
+
+		uploadToken, err := svc.UploadToken.Authenticate(bearerToken)
+		if err != nil {
+			log.Printf("[VerifyUploadAuth] Authenticate Error: %v", err)
+			return common.ErrorResponse(c, fiber.StatusUnauthorized, common.ErrUnauthorizedMsg, nil, nil)
+		}
+		c.Locals("user_id", uploadToken.ClientID)
+		c.Locals("role_ids", uploadToken.RoleIDs)
+		c.Locals("auth_method", common.AuthMethodUploadToken)
+		c.Locals("upload_token", uploadToken)
+		return c.Next()
+	}
+}
+
+// VerifyAuthMethods allows the requests authenticated with one of the methods, it has to follow
+// VerifyAuth.
+func VerifyAuthMethods(authMethods []string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		authMethod, _ := c.Locals("auth_method").(string)
+		if !slices.Contains(authMethods, authMethod) {
+			return common.ErrorResponse(c, fiber.StatusForbidden, common.ErrAuthMethodNotAllowedMsg, nil, nil)
+		}
+		return c.Next()
+	}
+}
+
 func VerifyAuthWithUserData(svc *service.Service) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Get token from header
-		bearerToken := c.Get("Authorization")
-		if bearerToken == "" {
+		bearerToken, isExist := common.GetBearerToken(c)
+		if !isExist {
 			log.Printf("[VerifyAuthWithUserData] Bearer token not found")
 			return common.ErrorResponse(c, fiber.StatusUnauthorized, common.ErrUnauthorizedMsg, nil, nil)
 		}
-		bearerToken = bearerToken[7:]
 
 		// Verify token
 		token, err := svc.Auth.ValidateToken(bearerToken)
@@ -116,6 +212,8 @@ func VerifyAuthWithUserData(svc *service.Service) fiber.Handler {
 
 		// Set user id to locals
 		c.Locals("user_id", user.ID)
+		// set token to locals
+		c.Locals("token", token)
 
 		// set role_ids to locals
 		c.Locals("role_ids", []string(audiences))
@@ -149,3 +247,59 @@ func VerifyAuthAudiences(audiences []string) fiber.Handler {
 		return common.ErrorResponse(c, fiber.StatusUnauthorized, common.ErrUnauthorizedMsg, nil, nil)
 	}
 }
+
+// GetPermissions returns the permissions granted to the client of the request through the roles of
+// its token, resolved once per request.
+func GetPermissions(c *fiber.Ctx, svc *service.Service) (common.Permissions, error) {
+	if permissions, isExist := c.Locals("permissions").(common.Permissions); isExist {
+		return permissions, nil
+	}
+
+	roleIDs, _ := c.Locals("role_ids").([]string)
+	permissions, err := svc.Role.GetPermissions(roleIDs)
+	if err != nil {
+		return nil, err
+	}
+	c.Locals("permissions", permissions)
+	return permissions, nil
+}
+
+// RequirePermission allows the clients granted a permission on every rule.
+func RequirePermission(svc *service.Service, permission string) fiber.Handler {
+	return verifyPermission(svc, permission, func(c *fiber.Ctx) string { return "" })
+}
+
+// RequireRulePermission allows the clients granted a permission on the rule of the slug route param.
+func RequireRulePermission(svc *service.Service, permission string) fiber.Handler {
+	return verifyPermission(svc, permission, func(c *fiber.Ctx) string { return c.Params("slug") })
+}
+
+func verifyPermission(svc *service.Service, permission string, getRuleSlug func(c *fiber.Ctx) string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		permissions, err := GetPermissions(c, svc)
+		if err != nil {
+			log.Printf("[VerifyPermission] GetPermissions Error: %v", err)
+			return common.ErrorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+		}
+
+		if !permissions.Allows(permission, getRuleSlug(c)) {
+			return common.ErrorResponse(c, fiber.StatusForbidden, common.ErrForbiddenMsg, nil, nil)
+		}
+		return c.Next()
+	}
+}
+
+// recordAPIKeyFailure audits a request refused for the key and secret it carries, as a failed login.
+func recordAPIKeyFailure(c *fiber.Ctx, svc *service.Service, apiKey string, err error) {
+	var action string
+	switch err.Error() {
+	case common.ErrAuthenticationFailedMsg:
+		action = common.AuditActionAPIKeyFailed
+	case common.ErrLoginLockedMsg:
+		action = common.AuditActionAPIKeyLocked
+	default:
+		return
+	}
+	requestID, _ := c.Locals("request_id").(string)
+	svc.Audit.RecordLogin(&entity.AuditEvent{Action: action, ActorIP: c.IP(), RequestID: requestID}, apiKey)
+}
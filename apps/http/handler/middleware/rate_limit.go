@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RateLimit limits the requests of the authenticated client to an endpoint class, it has to follow
+// VerifyAuth. The limited responses carry the RateLimit headers and a request over the limit gets a
+// 429. Requests go through when the limits can not be read.
+func RateLimit(svc *service.Service, endpointClass string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		clientID, _ := c.Locals("user_id").(string)
+		status, err := svc.RateLimit.AllowRequest(clientID, endpointClass)
+		if err != nil {
+			log.Printf("[RateLimit] AllowRequest Error: %v", err)
+			return c.Next()
+		}
+		if status == nil {
+			return c.Next()
+		}
+
+		resetSeconds := int(time.Until(status.ResetAt).Seconds()) + 1
+		c.Set("RateLimit-Policy", fmt.Sprintf("%d;w=60", status.Limit))
+		c.Set("RateLimit-Limit", strconv.FormatUint(status.Limit, 10))
+		c.Set("RateLimit-Remaining", strconv.FormatUint(status.Remaining, 10))
+		c.Set("RateLimit-Reset", strconv.Itoa(resetSeconds))
+
+		if !status.IsAllowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(resetSeconds))
+			return common.ErrorResponse(c, fiber.StatusTooManyRequests, common.ErrRateLimitExceededMsg, nil, nil)
+		}
+		return c.Next()
+	}
+}
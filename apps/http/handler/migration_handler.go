@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"github.com/sibeur/gotaro/apps/http/handler/dto"
+	"github.com/sibeur/gotaro/apps/http/handler/middleware"
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type MigrationHandler struct {
+	fiberInstance *fiber.App
+	svc           *service.Service
+}
+
+func NewMigrationHandler(fiberInstance *fiber.App, svc *service.Service) *MigrationHandler {
+	return &MigrationHandler{
+		fiberInstance: fiberInstance,
+		svc:           svc,
+	}
+}
+
+func (h *MigrationHandler) Router() {
+	migrations := h.fiberInstance.Group("/v1").Group("/migrations", middleware.VerifyAuth(h.svc), middleware.RateLimit(h.svc, common.EndpointClassManage), middleware.RequirePermission(h.svc, common.PermissionAll))
+	migrations.Get("/", h.findAllMigrationJobs)
+	migrations.Post("/", h.createMigrationJob)
+	migrations.Get("/:id", h.findMigrationJobByID)
+	migrations.Post("/:id/pause", h.pauseMigrationJob)
+	migrations.Post("/:id/resume", h.resumeMigrationJob)
+	migrations.Post("/:id/cancel", h.cancelMigrationJob)
+}
+
+func (h *MigrationHandler) findAllMigrationJobs(c *fiber.Ctx) error {
+	jobs, err := h.svc.Migration.FindAll()
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	response := make([]common.GotaroMap, 0)
+	for _, job := range jobs {
+		response = append(response, job.ToJSONSimple())
+	}
+
+	return successResponse(c, "", response, nil)
+}
+
+func (h *MigrationHandler) createMigrationJob(c *fiber.Ctx) error {
+	jobData := new(dto.NewMigrationJobDTO)
+
+	if err := c.BodyParser(jobData); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+	}
+
+	fValidator := common.NewFiberValidator()
+
+	if errs := fValidator.Validate(jobData); len(errs) > 0 {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, errs, nil)
+	}
+
+	job := &entity.MigrationJob{
+		RuleSlug:         jobData.RuleSlug,
+		SourceDriverSlug: jobData.SourceDriverSlug,
+		TargetDriverSlug: jobData.TargetDriverSlug,
+		DeleteSource:     jobData.DeleteSource,
+		RateLimit:        jobData.RateLimit,
+	}
+
+	err := h.svc.Migration.Create(job)
+	if err != nil {
+		switch err.Error() {
+		case common.ErrRuleNotFoundMsg, common.ErrDriverNotFoundMsg:
+			return errorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		case common.ErrMigrationSameDriverMsg, common.ErrDriverClientNotFoundMsg:
+			return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	return successResponse(c, "", job.ToJSON(), nil)
+}
+
+func (h *MigrationHandler) findMigrationJobByID(c *fiber.Ctx) error {
+	job, err := h.svc.Migration.FindByID(c.Params("id"))
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	if job == nil {
+		return errorResponse(c, fiber.StatusNotFound, common.ErrMigrationJobNotFoundMsg, nil, nil)
+	}
+
+	return successResponse(c, "", job.ToJSON(), nil)
+}
+
+func (h *MigrationHandler) pauseMigrationJob(c *fiber.Ctx) error {
+	job, err := h.svc.Migration.Pause(c.Params("id"))
+	return h.migrationJobResponse(c, job, err)
+}
+
+func (h *MigrationHandler) resumeMigrationJob(c *fiber.Ctx) error {
+	job, err := h.svc.Migration.Resume(c.Params("id"))
+	return h.migrationJobResponse(c, job, err)
+}
+
+func (h *MigrationHandler) cancelMigrationJob(c *fiber.Ctx) error {
+	job, err := h.svc.Migration.Cancel(c.Params("id"))
+	return h.migrationJobResponse(c, job, err)
+}
+
+func (h *MigrationHandler) migrationJobResponse(c *fiber.Ctx, job *entity.MigrationJob, err error) error {
+	if err != nil {
+		switch err.Error() {
+		case common.ErrMigrationJobNotFoundMsg:
+			return errorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		case common.ErrMigrationJobStatusInvalidMsg:
+			return errorResponse(c, fiber.StatusConflict, err.Error(), nil, nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	return successResponse(c, "", job.ToJSON(), nil)
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/sibeur/gotaro/apps/http/handler/dto"
+	"github.com/sibeur/gotaro/apps/http/handler/middleware"
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PersonalAccessTokenHandler lets a client manage its own personal access tokens.
+type PersonalAccessTokenHandler struct {
+	fiberInstance *fiber.App
+	svc           *service.Service
+}
+
+func NewPersonalAccessTokenHandler(fiberInstance *fiber.App, svc *service.Service) *PersonalAccessTokenHandler {
+	return &PersonalAccessTokenHandler{
+		fiberInstance: fiberInstance,
+		svc:           svc,
+	}
+}
+
+func (h *PersonalAccessTokenHandler) Router() {
+	tokens := h.fiberInstance.Group("/v1").Group("/personal-access-tokens", middleware.VerifyAuth(h.svc), middleware.RateLimit(h.svc, common.EndpointClassManage))
+	tokens.Get("/", h.findAllPersonalAccessTokens)
+	// a personal access token can not issue other tokens
+	tokens.Post("/", middleware.VerifyAuthMethods([]string{common.AuthMethodJWT, common.AuthMethodAPIKey, common.AuthMethodSignedRequest}), h.createPersonalAccessToken)
+	tokens.Delete("/:id", h.deletePersonalAccessToken)
+}
+
+func (h *PersonalAccessTokenHandler) findAllPersonalAccessTokens(c *fiber.Ctx) error {
+	return personalAccessTokensResponse(c, h.svc, c.Locals("user_id").(string))
+}
+
+// createPersonalAccessToken returns the new token, it can not be read again afterwards.
+func (h *PersonalAccessTokenHandler) createPersonalAccessToken(c *fiber.Ctx) error {
+	tokenData := new(dto.NewPersonalAccessTokenDTO)
+
+	if err := c.BodyParser(tokenData); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+	}
+
+	fValidator := common.NewFiberValidator()
+
+	if errs := fValidator.Validate(tokenData); len(errs) > 0 {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, errs, nil)
+	}
+
+	personalAccessToken := &entity.PersonalAccessToken{
+		ClientID: c.Locals("user_id").(string),
+		Name:     tokenData.Name,
+		Scopes:   common.UniqueArrayString(tokenData.Scopes),
+	}
+	if tokenData.ExpiresInDays > 0 {
+		personalAccessToken.ExpiredAt = time.Now().AddDate(0, 0, int(tokenData.ExpiresInDays))
+	}
+
+	token, err := h.svc.PersonalAccessToken.Create(personalAccessToken)
+	if err != nil {
+		if err.Error() == common.ErrPersonalAccessTokenScopeMsg {
+			return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	response := personalAccessToken.ToJSON()
+	response["token"] = token
+	return successResponse(c, "", response, nil)
+}
+
+func (h *PersonalAccessTokenHandler) deletePersonalAccessToken(c *fiber.Ctx) error {
+	return deletePersonalAccessTokenResponse(c, h.svc, c.Locals("user_id").(string), c.Params("id"))
+}
+
+func personalAccessTokensResponse(c *fiber.Ctx, svc *service.Service, clientID string) error {
+	tokens, err := svc.PersonalAccessToken.FindAllByClientID(clientID)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	response := make([]common.GotaroMap, 0)
+	for _, token := range tokens {
+		response = append(response, token.ToJSON())
+	}
+
+	return successResponse(c, "", response, nil)
+}
+
+func deletePersonalAccessTokenResponse(c *fiber.Ctx, svc *service.Service, clientID string, id string) error {
+	err := svc.PersonalAccessToken.Delete(clientID, id)
+	if err != nil {
+		if err.Error() == common.ErrPersonalAccessTokenNotFoundMsg {
+			return errorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	return successResponse(c, "", nil, nil)
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"github.com/sibeur/gotaro/apps/http/handler/dto"
+	"github.com/sibeur/gotaro/apps/http/handler/middleware"
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type RoleHandler struct {
+	fiberInstance *fiber.App
+	svc           *service.Service
+}
+
+func NewRoleHandler(fiberInstance *fiber.App, svc *service.Service) *RoleHandler {
+	return &RoleHandler{
+		fiberInstance: fiberInstance,
+		svc:           svc,
+	}
+}
+
+func (h *RoleHandler) Router() {
+	roles := h.fiberInstance.Group("/v1").Group("/roles", middleware.VerifyAuth(h.svc), middleware.RateLimit(h.svc, common.EndpointClassManage), middleware.RequirePermission(h.svc, common.PermissionAll))
+	roles.Get("/", h.findAllRoles)
+	roles.Post("/", h.createRole)
+	roles.Get("/:slug", h.findRoleBySlug)
+	roles.Put("/:slug", h.updateRole)
+	roles.Delete("/:slug", h.deleteRole)
+}
+
+func (h *RoleHandler) findAllRoles(c *fiber.Ctx) error {
+	roles, err := h.svc.Role.FindAll()
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	response := make([]common.GotaroMap, 0)
+	for _, role := range roles {
+		response = append(response, role.ToJSON())
+	}
+
+	return successResponse(c, "", response, nil)
+}
+
+func (h *RoleHandler) createRole(c *fiber.Ctx) error {
+	roleData := new(dto.NewRoleDTO)
+
+	if err := c.BodyParser(roleData); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+	}
+
+	fValidator := common.NewFiberValidator()
+
+	if errs := fValidator.Validate(roleData); len(errs) > 0 {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, errs, nil)
+	}
+
+	if !common.IsSlugValid(roleData.Slug) {
+		errSlug := common.NewFiberErrorMessage("Slug", common.ErrSlugInvalidMsg)
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, []common.FiberErrorMessage{errSlug}, nil)
+	}
+
+	role := &entity.Role{
+		Slug:        roleData.Slug,
+		Name:        roleData.Name,
+		Permissions: common.UniqueArrayString(roleData.Permissions),
+	}
+
+	err := h.svc.Role.Create(role)
+	return h.roleResponse(c, role, err)
+}
+
+func (h *RoleHandler) findRoleBySlug(c *fiber.Ctx) error {
+	role, err := h.svc.Role.FindBySlug(c.Params("slug"))
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	if role == nil {
+		return errorResponse(c, fiber.StatusNotFound, common.ErrRoleNotFoundMsg, nil, nil)
+	}
+
+	return successResponse(c, "", role.ToJSON(), nil)
+}
+
+func (h *RoleHandler) updateRole(c *fiber.Ctx) error {
+	roleData := new(dto.EditRoleDTO)
+
+	if err := c.BodyParser(roleData); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+	}
+
+	fValidator := common.NewFiberValidator()
+
+	if errs := fValidator.Validate(roleData); len(errs) > 0 {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, errs, nil)
+	}
+
+	role := &entity.Role{
+		Slug:        c.Params("slug"),
+		Name:        roleData.Name,
+		Permissions: common.UniqueArrayString(roleData.Permissions),
+	}
+
+	err := h.svc.Role.Update(role)
+	return h.roleResponse(c, role, err)
+}
+
+func (h *RoleHandler) deleteRole(c *fiber.Ctx) error {
+	err := h.svc.Role.Delete(c.Params("slug"))
+	if err != nil {
+		return h.roleResponse(c, nil, err)
+	}
+
+	return successResponse(c, "", nil, nil)
+}
+
+func (h *RoleHandler) roleResponse(c *fiber.Ctx, role *entity.Role, err error) error {
+	if err != nil {
+		switch err.Error() {
+		case common.ErrRoleNotFoundMsg:
+			return errorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		case common.ErrRoleAlreadyExistMsg, common.ErrRoleBuiltinMsg, common.ErrRoleInUseMsg, common.ErrPermissionInvalidMsg:
+			return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	return successResponse(c, "", role.ToJSON(), nil)
+}
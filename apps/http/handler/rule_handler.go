@@ -1,6 +1,9 @@
 package handler
 
 import (
+	"log"
+	"slices"
+
 	"github.com/sibeur/gotaro/apps/http/handler/dto"
 	"github.com/sibeur/gotaro/apps/http/handler/middleware"
 	"github.com/sibeur/gotaro/core/common"
@@ -23,12 +26,12 @@ func NewRuleHandler(fiberInstance *fiber.App, svc *service.Service) *RuleHandler
 }
 
 func (h *RuleHandler) Router() {
-	rules := h.fiberInstance.Group("/v1").Group("/rules", middleware.VerifyAuth(h.svc), middleware.VerifyAuthAudiences([]string{common.APIClientSuperAdminScope}))
-	rules.Get("/", h.findAllRules)
-	rules.Post("/", h.createRule)
-	rules.Get("/:slug", h.findRuleBySlug)
-	rules.Put("/:slug", h.updateRule)
-	rules.Delete("/:slug", h.deleteRule)
+	rules := h.fiberInstance.Group("/v1").Group("/rules", middleware.VerifyAuth(h.svc), middleware.RateLimit(h.svc, common.EndpointClassManage))
+	rules.Get("/", middleware.RequirePermission(h.svc, common.PermissionRuleManage), h.findAllRules)
+	rules.Post("/", middleware.RequirePermission(h.svc, common.PermissionRuleManage), h.createRule)
+	rules.Get("/:slug", middleware.RequireRulePermission(h.svc, common.PermissionRuleManage), h.findRuleBySlug)
+	rules.Put("/:slug", middleware.RequireRulePermission(h.svc, common.PermissionRuleManage), h.updateRule)
+	rules.Delete("/:slug", middleware.RequireRulePermission(h.svc, common.PermissionRuleManage), h.deleteRule)
 }
 
 func (h *RuleHandler) findAllRules(c *fiber.Ctx) error {
@@ -73,18 +76,31 @@ func (h *RuleHandler) createRule(c *fiber.Ctx) error {
 		return errorResponse(c, fiber.StatusBadRequest, common.ErrDriverNotFoundMsg, nil, nil)
 	}
 
+	driverIDs, errs := h.findRuleDriverIDs(existingDriver.ID, ruleData.ReplicaDriverSlugs)
+	if len(errs) > 0 {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, errs, nil)
+	}
+
 	rule := &entity.Rule{
-		Name:     ruleData.Name,
-		Slug:     ruleData.Slug,
-		MaxSize:  ruleData.MaxSize,
-		Mimes:    ruleData.Mimes,
-		DriverID: existingDriver.ID,
+		Name:              ruleData.Name,
+		Slug:              ruleData.Slug,
+		MaxSize:           ruleData.MaxSize,
+		Mimes:             ruleData.Mimes,
+		DriverID:          existingDriver.ID,
+		DriverIDs:         driverIDs,
+		ReplicationPolicy: ruleData.ReplicationPolicy,
+		ObjectOptions:     newRuleObjectOptions(ruleData.ObjectOptions),
+		IsEncrypted:       ruleData.IsEncrypted,
 	}
 
 	err = h.svc.Rule.Create(rule)
 	if err != nil {
+		if err.Error() == common.ErrMasterKeyNotSetMsg {
+			return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
 		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
 	}
+	h.recordAudit(c, common.AuditActionCreate, nil, rule.Slug)
 
 	return successResponse(c, "", rule, nil)
 }
@@ -129,18 +145,47 @@ func (h *RuleHandler) updateRule(c *fiber.Ctx) error {
 		return errorResponse(c, fiber.StatusBadRequest, common.ErrDriverNotFoundMsg, nil, nil)
 	}
 
+	driverIDs, errs := h.findRuleDriverIDs(existingDriver.ID, ruleData.ReplicaDriverSlugs)
+	if len(errs) > 0 {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, errs, nil)
+	}
+
 	rule := &entity.Rule{
-		Name:     ruleData.Name,
-		Slug:     ruleSlug,
-		MaxSize:  ruleData.MaxSize,
-		Mimes:    ruleData.Mimes,
-		DriverID: existingDriver.ID,
+		Name:              ruleData.Name,
+		Slug:              ruleSlug,
+		MaxSize:           ruleData.MaxSize,
+		Mimes:             ruleData.Mimes,
+		DriverID:          existingDriver.ID,
+		DriverIDs:         driverIDs,
+		ReplicationPolicy: ruleData.ReplicationPolicy,
+		ObjectOptions:     newRuleObjectOptions(ruleData.ObjectOptions),
+		IsEncrypted:       ruleData.IsEncrypted,
+	}
+
+	before, err := h.svc.Rule.FindBySlug(ruleSlug)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	// where the media are stored and whether they are encrypted is up to the clients managing drivers
+	if before != nil && (before.DriverID != rule.DriverID || !common.IsSameStringSet(before.GetDriverIDs(), rule.DriverIDs) || before.IsEncrypted != rule.IsEncrypted) {
+		permissions, err := middleware.GetPermissions(c, h.svc)
+		if err != nil {
+			return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+		}
+		if !permissions.Allows(common.PermissionDriverManage, "") {
+			return errorResponse(c, fiber.StatusForbidden, common.ErrForbiddenMsg, nil, nil)
+		}
 	}
 
 	err = h.svc.Rule.Update(rule)
 	if err != nil {
+		if err.Error() == common.ErrMasterKeyNotSetMsg {
+			return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
 		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
 	}
+	h.recordAudit(c, common.AuditActionUpdate, before, ruleSlug)
 
 	return successResponse(c, "", rule.ToJSONSimple(), nil)
 }
@@ -161,6 +206,63 @@ func (h *RuleHandler) deleteRule(c *fiber.Ctx) error {
 	if err != nil {
 		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
 	}
+	h.recordAudit(c, common.AuditActionDelete, rule, ruleSlug)
 
 	return successResponse(c, "", nil, nil)
 }
+
+// recordAudit records an action on a rule with its state before, nil for a creation, and its current
+// state, nil once deleted.
+func (h *RuleHandler) recordAudit(c *fiber.Ctx, action string, before *entity.Rule, ruleSlug string) {
+	var beforeJSON, afterJSON common.GotaroMap
+	resourceID := ""
+	if before != nil {
+		beforeJSON = before.ToJSON()
+		resourceID = before.ID
+	}
+
+	after, err := h.svc.Rule.FindBySlug(ruleSlug)
+	if err != nil {
+		log.Printf("Error finding rule %v to audit: %v", ruleSlug, err)
+	}
+	if after != nil {
+		afterJSON = after.ToJSON()
+		resourceID = after.ID
+	}
+	h.svc.Audit.Record(newAuditEvent(c, action, common.AuditResourceRule, resourceID), beforeJSON, afterJSON)
+}
+
+// findRuleDriverIDs returns the ordered driver ids of a rule, starting with the primary driver.
+func (h *RuleHandler) findRuleDriverIDs(primaryDriverID string, replicaDriverSlugs []string) ([]string, []common.FiberErrorMessage) {
+	driverIDs := []string{primaryDriverID}
+	for _, replicaDriverSlug := range replicaDriverSlugs {
+		if !common.IsSlugValid(replicaDriverSlug) {
+			return nil, []common.FiberErrorMessage{common.NewFiberErrorMessage("ReplicaDriverSlugs", common.ErrSlugInvalidMsg)}
+		}
+
+		replicaDriver, err := h.svc.Driver.FindBySlug(replicaDriverSlug)
+		if err != nil || replicaDriver == nil {
+			return nil, []common.FiberErrorMessage{common.NewFiberErrorMessage("ReplicaDriverSlugs", common.ErrDriverNotFoundMsg)}
+		}
+
+		if slices.Contains(driverIDs, replicaDriver.ID) {
+			return nil, []common.FiberErrorMessage{common.NewFiberErrorMessage("ReplicaDriverSlugs", common.ErrRuleDriverDuplicateMsg)}
+		}
+		driverIDs = append(driverIDs, replicaDriver.ID)
+	}
+	return driverIDs, nil
+}
+
+func newRuleObjectOptions(objectOptions *dto.RuleObjectOptionsDTO) *entity.RuleObjectOptions {
+	if objectOptions == nil {
+		return nil
+	}
+	return &entity.RuleObjectOptions{
+		CacheControl:       objectOptions.CacheControl,
+		ContentDisposition: objectOptions.ContentDisposition,
+		ContentLanguage:    objectOptions.ContentLanguage,
+		ContentEncoding:    objectOptions.ContentEncoding,
+		StorageClass:       objectOptions.StorageClass,
+		Metadata:           objectOptions.Metadata,
+	}
+}
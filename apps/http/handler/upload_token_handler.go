@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/sibeur/gotaro/apps/http/handler/dto"
+	"github.com/sibeur/gotaro/apps/http/handler/middleware"
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// UploadTokenHandler lets a backend mint the tokens its browsers upload with.
+type UploadTokenHandler struct {
+	fiberInstance *fiber.App
+	svc           *service.Service
+}
+
+func NewUploadTokenHandler(fiberInstance *fiber.App, svc *service.Service) *UploadTokenHandler {
+	return &UploadTokenHandler{
+		fiberInstance: fiberInstance,
+		svc:           svc,
+	}
+}
+
+func (h *UploadTokenHandler) Router() {
+	uploadTokens := h.fiberInstance.Group("/v1").Group("/upload-tokens", middleware.VerifyAuth(h.svc), middleware.RateLimit(h.svc, common.EndpointClassUpload))
+	// an upload token belongs to an api client, the callers of an OIDC issuer have none
+	uploadTokens.Post("/", middleware.VerifyAuthMethods([]string{common.AuthMethodJWT, common.AuthMethodAPIKey, common.AuthMethodPersonalAccessToken, common.AuthMethodSignedRequest}), h.createUploadToken)
+}
+
+// createUploadToken mints an upload token for a rule the client can upload to, with the roles of
+// the request.
+func (h *UploadTokenHandler) createUploadToken(c *fiber.Ctx) error {
+	tokenData := new(dto.NewUploadTokenDTO)
+
+	if err := c.BodyParser(tokenData); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+	}
+
+	fValidator := common.NewFiberValidator()
+
+	if errs := fValidator.Validate(tokenData); len(errs) > 0 {
+		return errorResponse(c, fiber.StatusBadRequest, common.ErrValidationMsg, errs, nil)
+	}
+
+	permissions, err := middleware.GetPermissions(c, h.svc)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+	if !permissions.Allows(common.PermissionMediaUpload, tokenData.RuleSlug) {
+		return errorResponse(c, fiber.StatusForbidden, common.ErrForbiddenMsg, nil, nil)
+	}
+
+	roleIDs, _ := c.Locals("role_ids").([]string)
+	uploadToken := &entity.UploadToken{
+		ClientID:  c.Locals("user_id").(string),
+		RoleIDs:   roleIDs,
+		RuleSlug:  tokenData.RuleSlug,
+		Directory: tokenData.Directory,
+		MaxSize:   tokenData.MaxSize,
+		MaxFiles:  tokenData.MaxFiles,
+		Metadata:  tokenData.Metadata,
+	}
+
+	token, err := h.svc.UploadToken.Create(uploadToken, time.Second*time.Duration(tokenData.ExpiresInSeconds))
+	if err != nil {
+		switch err.Error() {
+		case common.ErrRuleNotFoundMsg:
+			return errorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		case common.ErrUploadTokenMaxSizeMsg, common.ErrUploadTokenMetadataMsg:
+			return errorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, err.Error(), nil, nil)
+	}
+
+	response := uploadToken.ToJSON()
+	response["token"] = token
+	return successResponse(c, "", response, nil)
+}
@@ -3,11 +3,17 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	go_cache "github.com/sibeur/go-cache"
 	app_http "github.com/sibeur/gotaro/apps/http"
 	"github.com/sibeur/gotaro/core/common"
 	"github.com/sibeur/gotaro/core/common/driver"
+	"github.com/sibeur/gotaro/core/common/oidc"
+	"github.com/sibeur/gotaro/core/common/secret"
+	"github.com/sibeur/gotaro/core/common/signing"
 	core_db "github.com/sibeur/gotaro/core/db"
 	core_repository "github.com/sibeur/gotaro/core/repository"
 	core_service "github.com/sibeur/gotaro/core/service"
@@ -33,16 +39,55 @@ func main() {
 	// load driver manager
 	driverManager := driver.NewDriverManager()
 
+	// load keys signing the tokens
+	signingKeys, err := signing.NewKeySetFromEnv()
+	if err != nil {
+		panic(err)
+	}
+
+	// load the OIDC issuers whose tokens are accepted
+	oidcVerifier, err := oidc.NewVerifierFromEnv()
+	if err != nil {
+		panic(err)
+	}
+
 	// load reapository
-	repo := core_repository.NewRepository(mongoDB, cache)
+	repo := core_repository.NewRepository(mongoDB, cache, signingKeys, oidcVerifier)
+
+	// create the indexes of the token families and rate limit counters
+	if err := repo.Auth.CreateIndexes(); err != nil {
+		panic(err)
+	}
+	if err := repo.RateLimit.CreateIndexes(); err != nil {
+		panic(err)
+	}
+
+	// load master keys for secrets at rest
+	keyring, err := secret.NewKeyringFromEnv()
+	if err != nil {
+		panic(err)
+	}
 
 	// load service
-	service := core_service.NewService(repo, driverManager)
+	service := core_service.NewService(repo, driverManager, keyring)
 
 	if err := service.Driver.LoadDriverManager(); err != nil {
 		panic(err)
 	}
 
+	// follow driver, rule and media changes made by other instances
+	service.ClusterSync.Start()
+
+	// start driver health checks
+	service.Driver.StartHealthMonitor()
+
+	// resume migration jobs interrupted by a restart
+	if err := service.Migration.ResumeInterruptedJobs(); err != nil {
+		panic(err)
+	}
+	// take over migration jobs of instances stopping later on
+	service.Migration.StartLeaseSweep()
+
 	// check temporary folder
 	temporaryFolder := common.TemporaryFolder
 	if err := common.CreateFolder(temporaryFolder); err != nil {
@@ -61,7 +106,22 @@ func main() {
 		fmt.Printf("ClientKey: %s\nSecretKey: %s\n", clientKey, secretKey)
 	}
 
+	// stop the http app gracefully on interrupt
+	app := app_http.NewFiberApp(service)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-quit
+		if err := app.Shutdown(); err != nil {
+			fmt.Printf("Error shutting down http app: %v\n", err)
+		}
+	}()
+
 	// start http app
-	app_http.NewFiberApp(service).Run()
+	app.Run()
+
+	// close driver clients once the running transfers release them
+	service.Driver.HealthMonitor.Stop()
+	driverManager.CloseAll()
 
 }
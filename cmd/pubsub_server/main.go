@@ -10,6 +10,7 @@ import (
 	go_cache "github.com/sibeur/go-cache"
 	app_pubsub "github.com/sibeur/gotaro/apps/pubsub"
 	"github.com/sibeur/gotaro/core/common/driver"
+	"github.com/sibeur/gotaro/core/common/secret"
 
 	"github.com/joho/godotenv"
 )
@@ -32,11 +33,17 @@ func main() {
 	// load driver manager
 	driverManager := driver.NewDriverManager()
 
-	// load reapository
-	repo := core_repository.NewRepository(mongoDB, cache)
+	// load reapository, no token is issued here
+	repo := core_repository.NewRepository(mongoDB, cache, nil, nil)
+
+	// load master keys for secrets at rest
+	keyring, err := secret.NewKeyringFromEnv()
+	if err != nil {
+		panic(err)
+	}
 
 	// load service
-	service := core_service.NewService(repo, driverManager)
+	service := core_service.NewService(repo, driverManager, keyring)
 
 	// start pubsub app
 	app_pubsub.NewApp(service).Run()
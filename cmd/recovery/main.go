@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	go_cache "github.com/sibeur/go-cache"
+	"github.com/sibeur/gotaro/core/common/driver"
+	"github.com/sibeur/gotaro/core/common/secret"
+	core_db "github.com/sibeur/gotaro/core/db"
+	core_repository "github.com/sibeur/gotaro/core/repository"
+	core_service "github.com/sibeur/gotaro/core/service"
+
+	"github.com/joho/godotenv"
+)
+
+// Rebuilds or repairs the medias collection from the gotaro metadata stored with the objects of a
+// driver. The driver must be registered first, e.g. through the drivers API.
+func main() {
+	driverSlug := flag.String("driver", "", "slug of the driver to scan")
+	isDryRun := flag.Bool("dry-run", false, "report the changes without writing them")
+	flag.Parse()
+
+	if *driverSlug == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// dotenv load
+	godotenv.Load()
+
+	// load mongodb
+	mongoDB, err := core_db.NewMongoDBConnection()
+	if err != nil {
+		panic(err)
+	}
+	defer mongoDB.Client().Disconnect(context.Background())
+
+	// load driver manager
+	driverManager := driver.NewDriverManager()
+	defer driverManager.CloseAll()
+
+	// load reapository, no token is issued here
+	repo := core_repository.NewRepository(mongoDB, go_cache.NewCache(), nil, nil)
+
+	// load master keys for secrets at rest
+	keyring, err := secret.NewKeyringFromEnv()
+	if err != nil {
+		panic(err)
+	}
+
+	// load service
+	service := core_service.NewService(repo, driverManager, keyring)
+
+	if err := service.Driver.LoadDriverManager(); err != nil {
+		panic(err)
+	}
+
+	report, err := service.Recovery.Recover(*driverSlug, *isDryRun)
+	if err != nil {
+		fmt.Printf("Error recovering driver %v: %v\n", *driverSlug, err)
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"reflect"
+	"strings"
+)
+
+// AuditChange is the value of a field before and after a change, nil when the field is absent.
+type AuditChange struct {
+	Before any `bson:"before" json:"before"`
+	After  any `bson:"after" json:"after"`
+}
+
+// auditRedactedSuffixes are the suffixes of the field names whose value is never stored in an
+// audit event.
+var auditRedactedSuffixes = []string{"secret", "secret_key", "secret_access_key", "password", "private_key", "credential", "credentials", "token", "wrapped_key"}
+
+// auditIgnoredFields change along every change, they would only add noise to a diff.
+var auditIgnoredFields = []string{"updated_at"}
+
+// GetAuditChanges returns the fields that differ between two states of a resource with their
+// secrets redacted. before is nil for a creation and after is nil for a deletion.
+func GetAuditChanges(before GotaroMap, after GotaroMap) map[string]AuditChange {
+	before = RedactSecrets(before)
+	after = RedactSecrets(after)
+
+	changes := make(map[string]AuditChange)
+	for field, beforeValue := range before {
+		afterValue := after[field]
+		if !reflect.DeepEqual(beforeValue, afterValue) {
+			changes[field] = AuditChange{Before: beforeValue, After: afterValue}
+		}
+	}
+	for field, afterValue := range after {
+		if _, isExist := before[field]; !isExist {
+			changes[field] = AuditChange{After: afterValue}
+		}
+	}
+	for _, field := range auditIgnoredFields {
+		delete(changes, field)
+	}
+	return changes
+}
+
+// RedactSecrets returns a copy of data where the values of the secret fields, at any depth, are
+// replaced with AuditRedactedValue. AuditSecretChangedValue is kept, it marks a secret that changed.
+func RedactSecrets(data GotaroMap) GotaroMap {
+	if data == nil {
+		return nil
+	}
+
+	redacted := make(GotaroMap, len(data))
+	for field, value := range data {
+		if isSecretField(field) && value != nil && value != "" && value != AuditSecretChangedValue {
+			redacted[field] = AuditRedactedValue
+			continue
+		}
+		switch nested := value.(type) {
+		case GotaroMap:
+			redacted[field] = RedactSecrets(nested)
+		case map[string]any:
+			redacted[field] = map[string]any(RedactSecrets(nested))
+		default:
+			redacted[field] = value
+		}
+	}
+	return redacted
+}
+
+func isSecretField(field string) bool {
+	field = strings.ToLower(field)
+	for _, suffix := range auditRedactedSuffixes {
+		if strings.HasSuffix(field, suffix) {
+			return true
+		}
+	}
+	return false
+}
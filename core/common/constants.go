@@ -11,47 +11,279 @@ const (
 	ErrDriverNotFoundMsg       = "Driver not found"
 	ErrDriverClientNotFoundMsg = "Driver client not found"
 	ErrDriverNotInitiate       = "Driver not initiate"
+	ErrDriverUnhealthyMsg      = "Driver is unhealthy"
+	ErrDriverSecretMissingMsg  = "Driver secret config is required"
+	ErrMasterKeyNotSetMsg      = "Master key is not configured"
+	ErrDriverTypeInvalidMsg    = "Driver type is not supported"
 
 	// GCP Driver error message
 	ErrBucketNotExistMsg               = "Bucket not exist"
 	ErrNotHaveStorageAdminPrivilageMsg = "Not have storage admin privilage"
 
 	// Rule error messages
-	ErrRuleAlreadyExistMsg = "Rule already exist"
-	ErrRuleNotFoundMsg     = "Rule not found"
+	ErrRuleAlreadyExistMsg    = "Rule already exist"
+	ErrRuleNotFoundMsg        = "Rule not found"
+	ErrRuleDriverDuplicateMsg = "Rule driver duplicated"
 
 	// Media error messages
-	ErrMediaAlreadyExistMsg = "Media already exist"
-	ErrMediaNotFoundMsg     = "Media not found"
-	ErrFileSizeExceededMsg  = "File size exceeded"
-	ErrFileMimeInvalidMsg   = "File mime invalid"
+	ErrMediaAlreadyExistMsg  = "Media already exist"
+	ErrMediaNotFoundMsg      = "Media not found"
+	ErrFileSizeExceededMsg   = "File size exceeded"
+	ErrFileMimeInvalidMsg    = "File mime invalid"
+	ErrMediaReplicaFailedMsg = "Media replica upload failed"
+	ErrMediaDecryptFailedMsg = "Media decryption failed"
+	ErrMediaObjectCorruptMsg = "Media object corrupt"
+
+	// Migration error messages
+	ErrMigrationJobNotFoundMsg         = "Migration job not found"
+	ErrMigrationJobStatusInvalidMsg    = "Migration job status invalid"
+	ErrMigrationSameDriverMsg          = "Source and target driver are the same"
+	ErrMigrationChecksumMismatchMsg    = "Migration checksum mismatch"
+	ErrMigrationSourceDriverMissingMsg = "Migration source driver client not found"
+
+	// Reconciliation error messages
+	ErrReconciliationJobNotFoundMsg       = "Reconciliation job not found"
+	ErrReconciliationImportRuleMissingMsg = "Import rule slug is required to import objects"
 
 	// API Client error messages
 	ErrAPIClientAlreadyExistMsg = "API client already exist"
 	ErrAPIClientNotFoundMsg     = "API client not found"
+	ErrAPIClientSelfChangeMsg   = "API client can not disable, delete or change the scopes of itself"
+	ErrAPIClientLastAdminMsg    = "API client is the last active super admin"
+	ErrAPIClientSecretRotateMsg = "API client secret was rotated meanwhile"
+
+	// Role error messages
+	ErrRoleAlreadyExistMsg  = "Role already exist"
+	ErrRoleNotFoundMsg      = "Role not found"
+	ErrRoleBuiltinMsg       = "Built-in role can not be changed"
+	ErrRoleInUseMsg         = "Role is used by an API client"
+	ErrPermissionInvalidMsg = "Permission invalid"
 
 	// Auth error messages
-	ErrAuthenticationFailedMsg = "API Key or Secret Key invalid."
-	ErrJWTSecretNotFoundMsg    = "Secret JWT not defined."
-	ErrJWTTokenInvalidMsg      = "Token invalid."
-	ErrUnauthorizedMsg         = "Unauthorized."
+	ErrAuthenticationFailedMsg    = "API Key or Secret Key invalid."
+	ErrJWTSecretNotFoundMsg       = "Secret JWT not defined."
+	ErrJWTTokenInvalidMsg         = "Token invalid."
+	ErrJWTTokenRevokedMsg         = "Token revoked."
+	ErrJWTTokenReusedMsg          = "Refresh token already used."
+	ErrUnauthorizedMsg            = "Unauthorized."
+	ErrForbiddenMsg               = "Forbidden."
+	ErrAuthMethodNotAllowedMsg    = "Not allowed with this authentication method."
+	ErrRequestSignatureInvalidMsg = "Request signature invalid."
+	ErrRequestExpiredMsg          = "Request timestamp is out of the allowed clock skew."
+	ErrRequestReplayedMsg         = "Request nonce already used."
+	ErrLoginLockedMsg             = "Too many failed login attempts, try again later."
+
+	// Personal access token error messages
+	ErrPersonalAccessTokenNotFoundMsg = "Personal access token not found"
+	ErrPersonalAccessTokenScopeMsg    = "Personal access token scopes must be scopes of the API client"
+
+	// Upload token error messages
+	ErrUploadTokenRuleMsg      = "Upload token does not allow this rule"
+	ErrUploadTokenDirectoryMsg = "Upload token does not allow this directory"
+	ErrUploadTokenMaxSizeMsg   = "Upload token max size can not exceed the rule max size"
+	ErrUploadTokenMetadataMsg  = "Upload token metadata can not use the gotaro keys"
+	ErrUploadTokenFilesMsg     = "Upload token file count exceeded"
+
+	// Audit error messages
+	ErrAuditExportFormatInvalidMsg = "Audit export format must be csv or ndjson"
+
+	// Rate limit error messages
+	ErrRateLimitExceededMsg   = "Rate limit exceeded"
+	ErrUploadQuotaExceededMsg = "Upload quota exceeded"
 
 	// Media default config
 	TemporaryFolder     = "tmp"
 	DefaultSignedURLTTL = time.Minute * 10
 
+	// Driver health
+	DriverHealthStatusHealthy    = "healthy"
+	DriverHealthStatusUnhealthy  = "unhealthy"
+	DriverHealthCheckClient      = "client"
+	DriverHealthCheckCredentials = "credentials"
+	DriverHealthCheckBucket      = "bucket_exists"
+	DriverHealthCheckSigning     = "signing"
+	DriverHealthCheckObjectName  = "gotaro-health-check"
+	DefaultDriverHealthInterval  = time.Minute
+
+	// Driver validation
+	DriverValidationStatusPassed       = "passed"
+	DriverValidationStatusFailed       = "failed"
+	DriverValidationStatusWarning      = "warning"
+	DriverValidationStatusSkipped      = "skipped"
+	DriverValidationCheckCredentials   = "credentials"
+	DriverValidationCheckBucket        = "bucket_exists"
+	DriverValidationCheckPermissions   = "permissions"
+	DriverValidationCheckWriteDelete   = "write_delete_probe"
+	DriverValidationCheckSigning       = "signing"
+	DriverValidationCheckPublicACL     = "public_acl"
+	DriverValidationCheckUniformAccess = "uniform_bucket_level_access"
+	DriverValidationProbeObjectPrefix  = "gotaro-validation-"
+
+	// Rule replication policies
+	RuleReplicationPolicySync  = "sync"
+	RuleReplicationPolicyAsync = "async"
+
+	// Media location status
+	MediaLocationStatusSynced  = "synced"
+	MediaLocationStatusPending = "pending"
+	MediaLocationStatusFailed  = "failed"
+
+	// Migration job status
+	MigrationJobStatusPending   = "pending"
+	MigrationJobStatusRunning   = "running"
+	MigrationJobStatusPaused    = "paused"
+	MigrationJobStatusCompleted = "completed"
+	MigrationJobStatusFailed    = "failed"
+	MigrationJobStatusCancelled = "cancelled"
+
+	// Migration default config
+	DefaultMigrationRateLimit   = 5
+	DefaultMigrationBatchSize   = 50
+	MaxMigrationFailuresTracked = 100
+	// MigrationJobLease is how long an instance owns a running job without renewing its lease, another
+	// instance takes the job over once it expired
+	MigrationJobLease = time.Minute
+
+	// Reconciliation job status
+	ReconciliationJobStatusRunning   = "running"
+	ReconciliationJobStatusCompleted = "completed"
+	ReconciliationJobStatusFailed    = "failed"
+
+	// Reconciliation untracked object actions
+	ReconciliationActionReport     = "report"
+	ReconciliationActionImport     = "import"
+	ReconciliationActionQuarantine = "quarantine"
+	ReconciliationActionDelete     = "delete"
+
+	// Reconciliation default config
+	ReconciliationQuarantineFolder = "gotaro-quarantine"
+	MaxReconciliationItemsTracked  = 1000
+
 	// API Client default scope
 	APIClientSuperAdminScope = "super-admin"
 	APIClientUploaderScope   = "uploader"
 
+	// Permissions granted through the roles, a grant may append a rule slug glob to a permission,
+	// e.g. "media:upload:partner-*", to restrict it to the matching rules
+	PermissionAll          = "*"
+	PermissionMediaUpload  = "media:upload"
+	PermissionMediaRead    = "media:read"
+	PermissionMediaDelete  = "media:delete"
+	PermissionRuleManage   = "rule:manage"
+	PermissionDriverManage = "driver:manage"
+	PermissionAuditRead    = "audit:read"
+
+	// Endpoint classes sharing a rate limit
+	EndpointClassUpload = "upload"
+	EndpointClassRead   = "read"
+	EndpointClassManage = "manage"
+
+	// Authentication methods, besides the login and JWT flow a request can carry the api key and
+	// secret of a client, a personal access token or a token of a trusted OIDC issuer
+	AuthMethodJWT                 = "jwt"
+	AuthMethodAPIKey              = "api-key"
+	AuthMethodPersonalAccessToken = "personal-access-token"
+	AuthMethodSignedRequest       = "signed-request"
+	AuthMethodUploadToken         = "upload-token"
+	AuthMethodOIDC                = "oidc"
+	HeaderGotaroKey               = "X-Gotaro-Key"
+	HeaderGotaroSecret            = "X-Gotaro-Secret"
+	PersonalAccessTokenPrefix     = "gtp_"
+	UploadTokenPrefix             = "gtu_"
+	HeaderGotaroTimestamp         = "X-Gotaro-Timestamp"
+	HeaderGotaroNonce             = "X-Gotaro-Nonce"
+	// DefaultRequestClockSkew is how far the timestamp of a signed request can be from the server time
+	DefaultRequestClockSkew = time.Minute * 5
+	// DefaultUploadTokenTTL is how long an upload token lasts when the request does not tell, up to
+	// MaxUploadTokenTTL
+	DefaultUploadTokenTTL = time.Minute * 10
+	MaxUploadTokenTTL     = time.Hour
+	// Failed logins delay the next attempt of the same key or IP longer each time, up to
+	// MaxLoginDelay, until the max failures lock them out
+	DefaultLoginMaxFailuresPerKey = 5
+	DefaultLoginMaxFailuresPerIP  = 20
+	DefaultLoginLockout           = time.Minute * 15
+	MaxLoginDelay                 = time.Second * 30
+	LoginSubjectKey               = "key"
+	LoginSubjectIP                = "ip"
+	// LastUsedInterval is how often the last use of a key or token is stored at most
+	LastUsedInterval = time.Minute
+
 	// JWT Issuer
 	JWTIssuerAccessToken  = "gotaro-access-token"
 	JWTIssuerRefreshToken = "gotaro-refresh-token"
+	JWTIssuerUploadToken  = "gotaro-upload-token"
+
+	// Audit events, an action is done on a resource type
+	AuditActionCreate              = "create"
+	AuditActionUpdate              = "update"
+	AuditActionDelete              = "delete"
+	AuditActionLogin               = "login"
+	AuditActionLoginFailed         = "login-failed"
+	AuditActionLoginLocked         = "login-locked"
+	AuditActionAPIKeyFailed        = "api-key-failed"
+	AuditActionAPIKeyLocked        = "api-key-locked"
+	AuditActionUnlockLogin         = "unlock-login"
+	AuditActionRotateSecret        = "rotate-secret"
+	AuditActionRotateSigningSecret = "rotate-signing-secret"
+	AuditActionRevokeTokens        = "revoke-tokens"
+	AuditActionDisable             = "disable"
+	AuditActionEnable              = "enable"
+	AuditActionRotateSecrets       = "rotate-secrets"
+	AuditResourceDriver            = "driver"
+	AuditResourceRule              = "rule"
+	AuditResourceAPIClient         = "api-client"
+	AuditResourceMedia             = "media"
+	AuditRedactedValue             = "[REDACTED]"
+	AuditSecretChangedValue        = "changed"
+	AuditExportFormatCSV           = "csv"
+	AuditExportFormatNDJSON        = "ndjson"
+	DefaultAuditEventsLimit        = 100
+	MaxAuditEventsLimit            = 1000
+
+	// Object metadata keys
+	ObjectMetadataMediaID      = "gotaro-media-id"
+	ObjectMetadataRuleSlug     = "gotaro-rule-slug"
+	ObjectMetadataOriginalName = "gotaro-original-name"
+	ObjectMetadataAlias        = "gotaro-alias"
+	ObjectMetadataCommit       = "gotaro-commit"
+	ObjectMetadataChecksum     = "gotaro-sha256"
+	ObjectMetadataKeyID        = "gotaro-key-id"
+	ObjectMetadataWrappedKey   = "gotaro-wrapped-key"
+	ObjectMetadataNonce        = "gotaro-nonce"
+	ObjectMetadataChunkSize    = "gotaro-chunk-size"
+
+	// EncryptedObjectMime is the content type of the objects uploaded through an encrypting rule
+	EncryptedObjectMime = "application/octet-stream"
 
 	// Cache Keys
-	CacheGetMediaKey       = "gotaro:media:%s:%s"
-	CacheMediaSignedUrlKey = "gotaro:media:signedUrl:%s:%s"
+	CacheGetMediaKey        = "gotaro:media:%s:%s"
+	CacheMediaSignedUrlKey  = "gotaro:media:signedUrl:%s:%s"
+	CacheRevokedTokenKey    = "gotaro:auth:revoked:%s"
+	CacheRolePermissionsKey = "gotaro:role:permissions:%s"
+	CacheAPIClientLimitsKey = "gotaro:apiClient:limits:%s"
+	CacheAPIKeyVerifiedKey  = "gotaro:apiClient:verifiedKey:%s"
+	CacheLoginLockKey       = "gotaro:login:lock:%s:%s"
+
+	// Rate limit counter keys
+	RateLimitRequestsKey         = "gotaro:rateLimit:%s:%s:%d"
+	RateLimitUploadQuotaKey      = "gotaro:uploadQuota:%s:%s"
+	RateLimitUploadTokenFilesKey = "gotaro:uploadToken:files:%s"
+	RateLimitLoginFailuresKey    = "gotaro:login:failures:%s:%s"
 
 	// Cache TTL
-	DefaultGetMediaCacheTTL = 60 * 10
+	DefaultGetMediaCacheTTL        = 60 * 10
+	DefaultRolePermissionsCacheTTL = 60
+	DefaultAPIClientLimitsCacheTTL = 60
+	DefaultAPIKeyVerifiedCacheTTL  = 60
+
+	// Cluster sync default config
+	DefaultClusterSyncPollInterval = time.Second * 30
+	ClusterSyncRetryDelay          = time.Second * 5
+	// ClusterSyncClockSkew widens the polled period as the changes are timed by the clock of the
+	// instance making them, evicting a change twice is harmless
+	ClusterSyncClockSkew = time.Minute
+
+	// DefaultAPIClientSecretOverlap is how long a rotated API client secret stays valid
+	DefaultAPIClientSecretOverlap = time.Hour * 24
 )
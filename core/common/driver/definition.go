@@ -1,5 +1,7 @@
 package driver
 
+import "time"
+
 type StorageDriverType uint32
 
 const (
@@ -12,5 +14,27 @@ var AllowedDrivers []StorageDriverType = []StorageDriverType{
 }
 
 type UploadFileOpts struct {
-	Mime string
+	Mime               string
+	CacheControl       string
+	ContentDisposition string
+	ContentLanguage    string
+	ContentEncoding    string
+	StorageClass       string
+	Metadata           map[string]string
+}
+
+type ObjectAttrs struct {
+	Name               string
+	Size               int64
+	ContentType        string
+	CacheControl       string
+	ContentDisposition string
+	ContentLanguage    string
+	ContentEncoding    string
+	StorageClass       string
+	MediaLink          string
+	MD5                []byte
+	CRC32C             uint32
+	Metadata           map[string]string
+	UpdatedAt          time.Time
 }
@@ -2,6 +2,7 @@ package driver
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/sibeur/gotaro/core/common"
 )
@@ -12,10 +13,19 @@ type DriverClientUseCase interface {
 	GetTypeString() string
 	GetDriver() any
 	UploadFile(filePath string, targetFilePath string, opts ...*UploadFileOpts) (string, error)
+	DownloadFile(filePath string, targetFilePath string) error
+	DeleteFile(filePath string) error
+	CopyFile(filePath string, targetFilePath string) error
+	GetObjectAttrs(filePath string) (*ObjectAttrs, error)
+	ListObjects(prefix string) ([]*ObjectAttrs, error)
+	// WalkObjects calls walkFn with each object under prefix, page by page, until walkFn fails
+	WalkObjects(prefix string, walkFn func(*ObjectAttrs) error) error
 	GetSignedUrl(filePath string) (string, error)
 	IsStorageAssetPublic() (bool, error)
 	IsStorageBucketExist() (bool, error)
 	ValidateDriver() error
+	RunValidation() *DriverValidationReport
+	CheckHealth() *DriverHealth
 	Close()
 }
 
@@ -82,6 +92,54 @@ func (dc *DriverClient) UploadFile(tempFilePath string, targetFilePath string, o
 	return "", nil
 }
 
+func (dc *DriverClient) DownloadFile(filePath string, targetFilePath string) error {
+	switch dc.driverType {
+	case GCSDriverType:
+		return dc.driver.(GCPDriverClientUseCase).DownloadFile(filePath, targetFilePath)
+	}
+	return nil
+}
+
+func (dc *DriverClient) DeleteFile(filePath string) error {
+	switch dc.driverType {
+	case GCSDriverType:
+		return dc.driver.(GCPDriverClientUseCase).DeleteFile(filePath)
+	}
+	return nil
+}
+
+func (dc *DriverClient) CopyFile(filePath string, targetFilePath string) error {
+	switch dc.driverType {
+	case GCSDriverType:
+		return dc.driver.(GCPDriverClientUseCase).CopyFile(filePath, targetFilePath)
+	}
+	return nil
+}
+
+func (dc *DriverClient) ListObjects(prefix string) ([]*ObjectAttrs, error) {
+	switch dc.driverType {
+	case GCSDriverType:
+		return dc.driver.(GCPDriverClientUseCase).ListObjects(prefix)
+	}
+	return nil, nil
+}
+
+func (dc *DriverClient) WalkObjects(prefix string, walkFn func(*ObjectAttrs) error) error {
+	switch dc.driverType {
+	case GCSDriverType:
+		return dc.driver.(GCPDriverClientUseCase).WalkObjects(prefix, walkFn)
+	}
+	return nil
+}
+
+func (dc *DriverClient) GetObjectAttrs(filePath string) (*ObjectAttrs, error) {
+	switch dc.driverType {
+	case GCSDriverType:
+		return dc.driver.(GCPDriverClientUseCase).GetObjectAttrs(filePath)
+	}
+	return nil, nil
+}
+
 func (dc *DriverClient) GetSignedUrl(filePath string) (string, error) {
 	switch dc.driverType {
 	case GCSDriverType:
@@ -102,45 +160,31 @@ func (dc *DriverClient) IsStorageBucketExist() (bool, error) {
 	return false, nil
 }
 
+// ValidateDriver runs every check of the driver config and returns the error of the first one failing.
 func (dc *DriverClient) ValidateDriver() error {
+	if dc.driver == nil {
+		return errors.New(common.ErrDriverNotInitiate)
+	}
+	if failedCheck := dc.RunValidation().GetFailedCheck(); failedCheck != nil {
+		return fmt.Errorf("%s: %s", failedCheck.Name, failedCheck.Error)
+	}
+	return nil
+}
+
+func (dc *DriverClient) RunValidation() *DriverValidationReport {
 	switch dc.driverType {
 	case GCSDriverType:
-		gcpDriver := dc.driver.(GCPDriverClientUseCase)
-		if dc.driver == nil {
-			return errors.New(common.ErrDriverNotInitiate)
-		}
+		return dc.driver.(GCPDriverClientUseCase).RunValidation()
+	}
+	return NewDriverValidationReport([]DriverValidationCheck{})
+}
 
-		errChan := make(chan error, 2)
-		go func(gcpDriver GCPDriverClientUseCase, errChan chan error) {
-			_, err := gcpDriver.IsHasStorageAdminPrivilage()
-
-			if err != nil {
-				if err.Error() == common.ErrBucketNotExistMsg {
-					err = errors.New(common.ErrBucketNotExistMsg)
-				}
-				err = errors.New(common.ErrNotHaveStorageAdminPrivilageMsg)
-			}
-
-			if err != nil {
-				errChan <- err
-				return
-			}
-			errChan <- nil
-		}(gcpDriver, errChan)
-		go func(gcpDriver GCPDriverClientUseCase, errChan chan error) {
-			_, err := gcpDriver.IsStorageBucketExist()
-			if err != nil {
-				errChan <- errors.New(common.ErrBucketNotExistMsg)
-				return
-			}
-			errChan <- nil
-		}(gcpDriver, errChan)
-
-		if err := <-errChan; err != nil {
-			return err
-		}
+func (dc *DriverClient) CheckHealth() *DriverHealth {
+	switch dc.driverType {
+	case GCSDriverType:
+		return dc.driver.(GCPDriverClientUseCase).CheckHealth()
 	}
-	return nil
+	return NewDriverHealth([]DriverHealthCheck{}, 0)
 }
 
 func (dc *DriverClient) Close() {
@@ -0,0 +1,157 @@
+package driver
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+)
+
+type DriverHealthCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+type DriverHealth struct {
+	Status              string              `json:"status"`
+	LatencyMs           int64               `json:"latency_ms"`
+	CheckedAt           time.Time           `json:"checked_at"`
+	LastError           string              `json:"last_error,omitempty"`
+	ConsecutiveFailures uint32              `json:"consecutive_failures"`
+	Checks              []DriverHealthCheck `json:"checks"`
+}
+
+func NewDriverHealth(checks []DriverHealthCheck, latency time.Duration) *DriverHealth {
+	health := &DriverHealth{
+		Status:    common.DriverHealthStatusHealthy,
+		LatencyMs: latency.Milliseconds(),
+		CheckedAt: time.Now(),
+		Checks:    checks,
+	}
+	for _, check := range checks {
+		if !check.Passed {
+			health.Status = common.DriverHealthStatusUnhealthy
+			health.LastError = check.Name + ": " + check.Error
+			break
+		}
+	}
+	return health
+}
+
+func (h *DriverHealth) IsHealthy() bool {
+	return h.Status != common.DriverHealthStatusUnhealthy
+}
+
+func (h *DriverHealth) ToJSON() common.GotaroMap {
+	return common.GotaroMap{
+		"status":               h.Status,
+		"latency_ms":           h.LatencyMs,
+		"checked_at":           common.DateTimeNullableToString(&h.CheckedAt),
+		"last_error":           h.LastError,
+		"consecutive_failures": h.ConsecutiveFailures,
+		"checks":               h.Checks,
+	}
+}
+
+// HealthMonitor periodically checks every client of a DriverManager and keeps their latest health.
+type HealthMonitor struct {
+	driverManager *DriverManager
+	interval      time.Duration
+	mutex         sync.RWMutex
+	healths       map[string]*DriverHealth
+	stop          chan struct{}
+}
+
+func NewHealthMonitor(driverManager *DriverManager, interval time.Duration) *HealthMonitor {
+	return &HealthMonitor{
+		driverManager: driverManager,
+		interval:      interval,
+		healths:       make(map[string]*DriverHealth),
+	}
+}
+
+func (m *HealthMonitor) Start() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.stop != nil {
+		return
+	}
+	m.stop = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		m.CheckAll()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.CheckAll()
+			}
+		}
+	}(m.stop)
+}
+
+func (m *HealthMonitor) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	m.stop = nil
+}
+
+func (m *HealthMonitor) CheckAll() {
+	for _, driverName := range m.driverManager.GetAllDriverNames() {
+		m.Check(driverName)
+	}
+}
+
+func (m *HealthMonitor) Check(driverName string) *DriverHealth {
+	var health *DriverHealth
+	driverClient, releaseDriverClient := m.driverManager.AcquireDriver(driverName)
+	defer releaseDriverClient()
+	if driverClient == nil {
+		health = NewDriverHealth([]DriverHealthCheck{{
+			Name:  common.DriverHealthCheckClient,
+			Error: common.ErrDriverClientNotFoundMsg,
+		}}, 0)
+	} else {
+		health = driverClient.CheckHealth()
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if previous, isExist := m.healths[driverName]; isExist && !health.IsHealthy() {
+		health.ConsecutiveFailures = previous.ConsecutiveFailures + 1
+	} else if !health.IsHealthy() {
+		health.ConsecutiveFailures = 1
+	}
+	if !health.IsHealthy() {
+		log.Printf("Driver %v is unhealthy: %v", driverName, health.LastError)
+	}
+	m.healths[driverName] = health
+	return health
+}
+
+// GetHealth returns the latest health of a driver, or nil when it has not been checked yet.
+func (m *HealthMonitor) GetHealth(driverName string) *DriverHealth {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.healths[driverName]
+}
+
+// IsHealthy reports whether a driver can be used; drivers not checked yet are considered healthy.
+func (m *HealthMonitor) IsHealthy(driverName string) bool {
+	health := m.GetHealth(driverName)
+	return health == nil || health.IsHealthy()
+}
+
+func (m *HealthMonitor) Remove(driverName string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.healths, driverName)
+}
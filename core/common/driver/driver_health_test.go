@@ -0,0 +1,28 @@
+package driver_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sibeur/gotaro/core/common/driver"
+)
+
+func TestHealthMonitorConcurrentStartStop(t *testing.T) {
+	monitor := driver.NewHealthMonitor(driver.NewDriverManager(), time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				monitor.Start()
+			} else {
+				monitor.Stop()
+			}
+		}(i)
+	}
+	wg.Wait()
+	monitor.Stop()
+}
@@ -2,46 +2,139 @@ package driver
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/gofiber/fiber/v2/log"
 )
 
 type DriverManagerUseCase interface {
 	GetDriver(driverName string) DriverClientUseCase
+	AcquireDriver(driverName string) (DriverClientUseCase, func())
 	AddDriver(driver DriverClientUseCase) error
 	RemoveDriver(driverName string) error
 	GetAllDriverNames() []string
+	CloseAll()
+}
+
+type managedDriverClient struct {
+	client DriverClientUseCase
+	// refs counts the callers using the client, it is closed after the last one once retired
+	refs      int
+	isRetired bool
 }
 
 type DriverManager struct {
-	driverClients map[string]DriverClientUseCase
+	mutex         sync.RWMutex
+	driverClients map[string]*managedDriverClient
 }
 
 func NewDriverManager() *DriverManager {
 	return &DriverManager{
-		driverClients: make(map[string]DriverClientUseCase),
+		driverClients: make(map[string]*managedDriverClient),
 	}
 }
 
+// GetDriver returns the current client of a driver for short lived uses. Use AcquireDriver when the
+// client is used for the length of a transfer.
 func (dm *DriverManager) GetDriver(driverName string) DriverClientUseCase {
-	return dm.driverClients[driverName]
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+	if managedClient, isExist := dm.driverClients[driverName]; isExist {
+		return managedClient.client
+	}
+	return nil
+}
+
+// AcquireDriver returns the current client of a driver and a release function to call once done with
+// it. A client replaced or removed in the meantime is closed after its last release.
+func (dm *DriverManager) AcquireDriver(driverName string) (DriverClientUseCase, func()) {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+	managedClient, isExist := dm.driverClients[driverName]
+	if !isExist {
+		return nil, func() {}
+	}
+	managedClient.refs++
+	return managedClient.client, sync.OnceFunc(func() {
+		dm.release(managedClient)
+	})
 }
 
+// AddDriver registers a driver client, closing the client it replaces once it is not in use.
 func (dm *DriverManager) AddDriver(driver DriverClientUseCase) error {
 	log.Info(fmt.Sprintf("Loading driver %v, type: %v", driver.GetName(), driver.GetTypeString()))
-	dm.driverClients[driver.GetName()] = driver
+	dm.mutex.Lock()
+	previousClient := dm.driverClients[driver.GetName()]
+	if previousClient != nil && previousClient.client == driver {
+		dm.mutex.Unlock()
+		return nil
+	}
+	dm.driverClients[driver.GetName()] = &managedDriverClient{client: driver}
+	isIdle := previousClient != nil && retire(previousClient)
+	dm.mutex.Unlock()
+
+	if isIdle {
+		previousClient.client.Close()
+	}
 	return nil
 }
 
+// RemoveDriver unregisters a driver client, closing it once it is not in use.
 func (dm *DriverManager) RemoveDriver(driverName string) error {
+	dm.mutex.Lock()
+	previousClient := dm.driverClients[driverName]
 	delete(dm.driverClients, driverName)
+	isIdle := previousClient != nil && retire(previousClient)
+	dm.mutex.Unlock()
+
+	if isIdle {
+		previousClient.client.Close()
+	}
 	return nil
 }
 
 func (dm *DriverManager) GetAllDriverNames() []string {
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
 	var driverNames []string
 	for name := range dm.driverClients {
 		driverNames = append(driverNames, name)
 	}
 	return driverNames
 }
+
+// CloseAll unregisters every driver client, closing the idle ones now and the others after their
+// last release.
+func (dm *DriverManager) CloseAll() {
+	dm.mutex.Lock()
+	idleClients := make([]DriverClientUseCase, 0)
+	for name, managedClient := range dm.driverClients {
+		if retire(managedClient) {
+			idleClients = append(idleClients, managedClient.client)
+		}
+		delete(dm.driverClients, name)
+	}
+	dm.mutex.Unlock()
+
+	for _, client := range idleClients {
+		client.Close()
+	}
+}
+
+func (dm *DriverManager) release(managedClient *managedDriverClient) {
+	dm.mutex.Lock()
+	managedClient.refs--
+	isIdle := managedClient.isRetired && managedClient.refs == 0
+	dm.mutex.Unlock()
+
+	if isIdle {
+		managedClient.client.Close()
+	}
+}
+
+// retire marks a client as replaced and reports whether it can be closed right away. The manager
+// mutex must be held.
+func retire(managedClient *managedDriverClient) bool {
+	managedClient.isRetired = true
+	return managedClient.refs == 0
+}
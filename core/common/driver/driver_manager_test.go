@@ -0,0 +1,157 @@
+package driver_test
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sibeur/gotaro/core/common/driver"
+)
+
+type fakeDriverClient struct {
+	driver.DriverClientUseCase
+	name       string
+	closeCount atomic.Int32
+}
+
+func newFakeDriverClient(name string) *fakeDriverClient {
+	return &fakeDriverClient{name: name}
+}
+
+func (f *fakeDriverClient) GetName() string {
+	return f.name
+}
+
+func (f *fakeDriverClient) GetTypeString() string {
+	return "fake"
+}
+
+func (f *fakeDriverClient) Close() {
+	f.closeCount.Add(1)
+}
+
+func TestDriverManagerConcurrentAccess(t *testing.T) {
+	dm := driver.NewDriverManager()
+	clients := make([]*fakeDriverClient, 0)
+	var clientsMutex sync.Mutex
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("driver-%d", i%5)
+			client := newFakeDriverClient(name)
+			clientsMutex.Lock()
+			clients = append(clients, client)
+			clientsMutex.Unlock()
+
+			dm.AddDriver(client)
+			if acquiredClient, release := dm.AcquireDriver(name); acquiredClient != nil {
+				acquiredClient.GetName()
+				release()
+			}
+			dm.GetDriver(name)
+			dm.GetAllDriverNames()
+			if i%7 == 0 {
+				dm.RemoveDriver(name)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	dm.CloseAll()
+
+	for _, client := range clients {
+		if closeCount := client.closeCount.Load(); closeCount != 1 {
+			t.Errorf("Expected client %v to be closed once, got %v", client.name, closeCount)
+		}
+	}
+}
+
+func TestDriverManagerClosesReplacedClientAfterRelease(t *testing.T) {
+	dm := driver.NewDriverManager()
+	oldClient := newFakeDriverClient("gcs")
+	newClient := newFakeDriverClient("gcs")
+
+	dm.AddDriver(oldClient)
+	acquiredClient, release := dm.AcquireDriver("gcs")
+	if acquiredClient != oldClient {
+		t.Fatalf("Expected to acquire the registered client")
+	}
+
+	dm.AddDriver(newClient)
+	if dm.GetDriver("gcs") != newClient {
+		t.Errorf("Expected new client to replace the old one")
+	}
+	if oldClient.closeCount.Load() != 0 {
+		t.Errorf("Expected old client to stay open while in use")
+	}
+
+	release()
+	release()
+	if closeCount := oldClient.closeCount.Load(); closeCount != 1 {
+		t.Errorf("Expected old client to be closed once after release, got %v", closeCount)
+	}
+	if newClient.closeCount.Load() != 0 {
+		t.Errorf("Expected new client to stay open")
+	}
+}
+
+func TestDriverManagerRemoveDriver(t *testing.T) {
+	dm := driver.NewDriverManager()
+	idleClient := newFakeDriverClient("idle")
+	busyClient := newFakeDriverClient("busy")
+	dm.AddDriver(idleClient)
+	dm.AddDriver(busyClient)
+
+	_, release := dm.AcquireDriver("busy")
+	dm.RemoveDriver("idle")
+	dm.RemoveDriver("busy")
+
+	if idleClient.closeCount.Load() != 1 {
+		t.Errorf("Expected idle client to be closed on removal")
+	}
+	if busyClient.closeCount.Load() != 0 {
+		t.Errorf("Expected busy client to stay open until released")
+	}
+	if dm.GetDriver("busy") != nil {
+		t.Errorf("Expected removed client not to be returned")
+	}
+
+	release()
+	if busyClient.closeCount.Load() != 1 {
+		t.Errorf("Expected busy client to be closed after release")
+	}
+
+	if client, _ := dm.AcquireDriver("busy"); client != nil {
+		t.Errorf("Expected removed client not to be acquired")
+	}
+}
+
+func TestDriverManagerCloseAll(t *testing.T) {
+	dm := driver.NewDriverManager()
+	idleClient := newFakeDriverClient("idle")
+	busyClient := newFakeDriverClient("busy")
+	dm.AddDriver(idleClient)
+	dm.AddDriver(busyClient)
+
+	_, release := dm.AcquireDriver("busy")
+	dm.CloseAll()
+
+	if idleClient.closeCount.Load() != 1 {
+		t.Errorf("Expected idle client to be closed")
+	}
+	if busyClient.closeCount.Load() != 0 {
+		t.Errorf("Expected busy client to stay open until released")
+	}
+	if len(dm.GetAllDriverNames()) != 0 {
+		t.Errorf("Expected no driver left")
+	}
+
+	release()
+	if busyClient.closeCount.Load() != 1 {
+		t.Errorf("Expected busy client to be closed after release")
+	}
+}
@@ -0,0 +1,84 @@
+package driver
+
+import (
+	"slices"
+
+	"github.com/sibeur/gotaro/core/common"
+)
+
+type DriverCapabilities struct {
+	SignedURL       bool `json:"signed_url"`
+	PublicACL       bool `json:"public_acl"`
+	PresignedUpload bool `json:"presigned_upload"`
+	Delete          bool `json:"delete"`
+	RangeRead       bool `json:"range_read"`
+}
+
+// DriverTypeInfo describes a storage driver type and the config it expects, so clients can render
+// and validate driver forms without knowing each type.
+type DriverTypeInfo struct {
+	Type         StorageDriverType
+	Name         string
+	DisplayName  string
+	Capabilities DriverCapabilities
+	ConfigSchema common.GotaroMap
+	// SecretFields are encrypted at rest and never returned by the API
+	SecretFields []string
+	// NewConfig turns a config validated against ConfigSchema into the config stored for the driver
+	NewConfig func(config map[string]any) (map[string]any, error)
+}
+
+func (info *DriverTypeInfo) ToJSON() common.GotaroMap {
+	return common.GotaroMap{
+		"type":          info.Type,
+		"name":          info.Name,
+		"display_name":  info.DisplayName,
+		"capabilities":  info.Capabilities,
+		"config_schema": info.ConfigSchema,
+		"secret_fields": info.SecretFields,
+	}
+}
+
+// GetConfigSchema returns the config schema, without requiring the secret fields when they are kept
+// from the stored config.
+func (info *DriverTypeInfo) GetConfigSchema(isSecretOptional bool) common.GotaroMap {
+	if !isSecretOptional {
+		return info.ConfigSchema
+	}
+
+	schema := common.GotaroMap{}
+	for key, value := range info.ConfigSchema {
+		schema[key] = value
+	}
+	required := make([]string, 0)
+	for _, field := range info.ConfigSchema["required"].([]string) {
+		if !slices.Contains(info.SecretFields, field) {
+			required = append(required, field)
+		}
+	}
+	schema["required"] = required
+	return schema
+}
+
+var driverTypes = map[StorageDriverType]*DriverTypeInfo{
+	GCSDriverType: gcsDriverTypeInfo,
+}
+
+func GetDriverType(driverType StorageDriverType) *DriverTypeInfo {
+	return driverTypes[driverType]
+}
+
+func GetAllDriverTypes() []*DriverTypeInfo {
+	infos := make([]*DriverTypeInfo, 0, len(AllowedDrivers))
+	for _, driverType := range AllowedDrivers {
+		infos = append(infos, driverTypes[driverType])
+	}
+	return infos
+}
+
+func GetSecretConfigFields(driverType StorageDriverType) []string {
+	if info := GetDriverType(driverType); info != nil {
+		return info.SecretFields
+	}
+	return nil
+}
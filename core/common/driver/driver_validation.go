@@ -0,0 +1,50 @@
+package driver
+
+import "github.com/sibeur/gotaro/core/common"
+
+type DriverValidationCheck struct {
+	Name               string   `json:"name"`
+	Status             string   `json:"status"`
+	Error              string   `json:"error,omitempty"`
+	MissingPermissions []string `json:"missing_permissions,omitempty"`
+	Value              any      `json:"value,omitempty"`
+}
+
+func (c *DriverValidationCheck) IsFailed() bool {
+	return c.Status == common.DriverValidationStatusFailed
+}
+
+// DriverValidationReport is the result of every check run against a driver config. Warnings are
+// informational and do not make the config invalid.
+type DriverValidationReport struct {
+	IsValid bool                    `json:"is_valid"`
+	Checks  []DriverValidationCheck `json:"checks"`
+}
+
+func NewDriverValidationReport(checks []DriverValidationCheck) *DriverValidationReport {
+	report := &DriverValidationReport{IsValid: true, Checks: checks}
+	for _, check := range checks {
+		if check.IsFailed() {
+			report.IsValid = false
+			break
+		}
+	}
+	return report
+}
+
+// GetFailedCheck returns the first failed check, or nil when the config is valid.
+func (r *DriverValidationReport) GetFailedCheck() *DriverValidationCheck {
+	for i := range r.Checks {
+		if r.Checks[i].IsFailed() {
+			return &r.Checks[i]
+		}
+	}
+	return nil
+}
+
+func (r *DriverValidationReport) ToJSON() common.GotaroMap {
+	return common.GotaroMap{
+		"is_valid": r.IsValid,
+		"checks":   r.Checks,
+	}
+}
@@ -6,11 +6,15 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
+	"slices"
 	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
 	"github.com/sibeur/gotaro/core/common"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
@@ -21,14 +25,31 @@ type GCPDriverClientUseCase interface {
 	GetClient() *storage.Client
 	GetDriverConfig() *GCSDriverConfig
 	GetObjectNames() ([]string, error)
+	ListObjects(prefix string) ([]*ObjectAttrs, error)
+	WalkObjects(prefix string, walkFn func(*ObjectAttrs) error) error
 	UploadFile(filePath string, targetFilePath string, opts ...*UploadFileOpts) (string, error)
+	DownloadFile(filePath string, targetFilePath string) error
+	DeleteFile(filePath string) error
+	CopyFile(filePath string, targetFilePath string) error
+	GetObjectAttrs(filePath string) (*ObjectAttrs, error)
 	GetSignedUrl(filePath string) (string, error)
 	IsStorageAssetPublic() (bool, error)
 	IsStorageBucketExist() (bool, error)
 	IsHasStorageAdminPrivilage() (bool, error)
+	CheckHealth() *DriverHealth
+	RunValidation() *DriverValidationReport
 	Close()
 }
 
+// gcsRequiredPermissions are the bucket permissions gotaro needs to store and serve medias.
+var gcsRequiredPermissions = []string{
+	"storage.buckets.get",
+	"storage.objects.create",
+	"storage.objects.delete",
+	"storage.objects.get",
+	"storage.objects.list",
+}
+
 type GCPDriverClient struct {
 	driverConfig *GCSDriverConfig
 	client       *storage.Client
@@ -50,6 +71,7 @@ func NewGCPDriverClient(driverConfig *GCSDriverConfig) (*GCPDriverClient, error)
 	return &GCPDriverClient{
 		driverConfig: driverConfig,
 		client:       client,
+		ctx:          ctx,
 	}, nil
 }
 
@@ -66,19 +88,44 @@ func (gcp *GCPDriverClient) GetDriverConfig() *GCSDriverConfig {
 }
 
 func (gcp *GCPDriverClient) GetObjectNames() ([]string, error) {
-	objects := []string{}
-	it := gcp.GetBucket().Objects(gcp.ctx, nil)
+	objects, err := gcp.ListObjects("")
+	if err != nil {
+		return nil, err
+	}
+	objectNames := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		objectNames = append(objectNames, obj.Name)
+	}
+	return objectNames, nil
+}
+
+func (gcp *GCPDriverClient) ListObjects(prefix string) ([]*ObjectAttrs, error) {
+	objects := []*ObjectAttrs{}
+	err := gcp.WalkObjects(prefix, func(object *ObjectAttrs) error {
+		objects = append(objects, object)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// WalkObjects goes through the objects as the bucket listing pages them, without holding them all.
+func (gcp *GCPDriverClient) WalkObjects(prefix string, walkFn func(*ObjectAttrs) error) error {
+	it := gcp.GetBucket().Objects(gcp.ctx, &storage.Query{Prefix: prefix})
 	for {
 		obj, err := it.Next()
 		if err == iterator.Done {
-			break
+			return nil
 		}
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := walkFn(newObjectAttrsFromGCS(obj)); err != nil {
+			return err
 		}
-		objects = append(objects, obj.Name)
 	}
-	return objects, nil
 }
 
 func (gcp *GCPDriverClient) UploadFile(filePath string, targetFilePath string, opts ...*UploadFileOpts) (string, error) {
@@ -104,6 +151,12 @@ func (gcp *GCPDriverClient) UploadFile(filePath string, targetFilePath string, o
 	if opt.Mime != "" {
 		wc.ContentType = opt.Mime
 	}
+	wc.CacheControl = opt.CacheControl
+	wc.ContentDisposition = opt.ContentDisposition
+	wc.ContentLanguage = opt.ContentLanguage
+	wc.ContentEncoding = opt.ContentEncoding
+	wc.StorageClass = opt.StorageClass
+	wc.Metadata = opt.Metadata
 	if _, err := io.Copy(wc, file); err != nil {
 		return "", err
 	}
@@ -127,6 +180,48 @@ func (gcp *GCPDriverClient) UploadFile(filePath string, targetFilePath string, o
 	return url, nil
 }
 
+func (gcp *GCPDriverClient) DownloadFile(filePath string, targetFilePath string) error {
+	ctx := context.Background()
+	// read objects stored with a content encoding as is, so they are not decompressed
+	rc, err := gcp.GetBucket().Object(filePath).ReadCompressed(true).NewReader(ctx)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	file, err := os.Create(targetFilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, rc); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (gcp *GCPDriverClient) DeleteFile(filePath string) error {
+	ctx := context.Background()
+	return gcp.GetBucket().Object(filePath).Delete(ctx)
+}
+
+func (gcp *GCPDriverClient) CopyFile(filePath string, targetFilePath string) error {
+	ctx := context.Background()
+	bucket := gcp.GetBucket()
+	_, err := bucket.Object(targetFilePath).CopierFrom(bucket.Object(filePath)).Run(ctx)
+	return err
+}
+
+func (gcp *GCPDriverClient) GetObjectAttrs(filePath string) (*ObjectAttrs, error) {
+	ctx := context.Background()
+	attrs, err := gcp.GetBucket().Object(filePath).Attrs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newObjectAttrsFromGCS(attrs), nil
+}
+
 func (gcp *GCPDriverClient) GetSignedUrl(filePath string) (string, error) {
 	opts := &storage.SignedURLOptions{
 		Scheme:  storage.SigningSchemeV4,
@@ -200,8 +295,174 @@ func (gcp *GCPDriverClient) IsHasStorageAdminPrivilage() (bool, error) {
 	return isHasPrivilage, nil
 }
 
+func (gcp *GCPDriverClient) CheckHealth() *DriverHealth {
+	startedAt := time.Now()
+	checks := make([]DriverHealthCheck, 0)
+
+	credentialsCheck := DriverHealthCheck{Name: common.DriverHealthCheckCredentials, Passed: true}
+	bucketCheck := DriverHealthCheck{Name: common.DriverHealthCheckBucket, Passed: true}
+	_, err := gcp.GetBucket().Attrs(context.Background())
+	if err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && (apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden) {
+			credentialsCheck.Passed = false
+			credentialsCheck.Error = err.Error()
+		}
+		bucketCheck.Passed = false
+		bucketCheck.Error = err.Error()
+		if errors.Is(err, storage.ErrBucketNotExist) {
+			bucketCheck.Error = common.ErrBucketNotExistMsg
+		}
+	}
+	checks = append(checks, credentialsCheck, bucketCheck)
+
+	signingCheck := DriverHealthCheck{Name: common.DriverHealthCheckSigning, Passed: true}
+	if _, err := gcp.GetSignedUrl(common.DriverHealthCheckObjectName); err != nil {
+		signingCheck.Passed = false
+		signingCheck.Error = err.Error()
+	}
+	checks = append(checks, signingCheck)
+
+	return NewDriverHealth(checks, time.Since(startedAt))
+}
+
+// RunValidation runs every check of the driver config, writing and deleting a probe object.
+func (gcp *GCPDriverClient) RunValidation() *DriverValidationReport {
+	ctx := context.Background()
+	bucket := gcp.GetBucket()
+
+	credentialsCheck := DriverValidationCheck{Name: common.DriverValidationCheckCredentials, Status: common.DriverValidationStatusPassed}
+	bucketCheck := DriverValidationCheck{Name: common.DriverValidationCheckBucket, Status: common.DriverValidationStatusPassed}
+	attrs, err := bucket.Attrs(ctx)
+	if err != nil {
+		bucketCheck = newGCSFailedCheck(common.DriverValidationCheckBucket, err, "storage.buckets.get")
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusUnauthorized {
+			credentialsCheck = newGCSFailedCheck(common.DriverValidationCheckCredentials, err, "")
+		}
+	}
+	checks := []DriverValidationCheck{credentialsCheck, bucketCheck}
+
+	// the other checks need the bucket
+	if attrs == nil {
+		for _, name := range []string{
+			common.DriverValidationCheckPermissions,
+			common.DriverValidationCheckWriteDelete,
+			common.DriverValidationCheckSigning,
+			common.DriverValidationCheckPublicACL,
+			common.DriverValidationCheckUniformAccess,
+		} {
+			checks = append(checks, DriverValidationCheck{Name: name, Status: common.DriverValidationStatusSkipped})
+		}
+		return NewDriverValidationReport(checks)
+	}
+
+	permissionsCheck := DriverValidationCheck{Name: common.DriverValidationCheckPermissions, Status: common.DriverValidationStatusPassed}
+	grantedPermissions, err := bucket.IAM().TestPermissions(ctx, gcsRequiredPermissions)
+	if err != nil {
+		permissionsCheck = newGCSFailedCheck(common.DriverValidationCheckPermissions, err, "")
+	} else {
+		for _, permission := range gcsRequiredPermissions {
+			if !slices.Contains(grantedPermissions, permission) {
+				permissionsCheck.MissingPermissions = append(permissionsCheck.MissingPermissions, permission)
+			}
+		}
+		if len(permissionsCheck.MissingPermissions) > 0 {
+			permissionsCheck.Status = common.DriverValidationStatusFailed
+			permissionsCheck.Error = "Missing bucket permissions"
+		}
+	}
+	checks = append(checks, permissionsCheck)
+
+	probeObjectName := common.DriverValidationProbeObjectPrefix + uuid.NewString()
+	checks = append(checks, gcp.runWriteDeleteProbe(ctx, probeObjectName))
+
+	signingCheck := DriverValidationCheck{Name: common.DriverValidationCheckSigning, Status: common.DriverValidationStatusPassed}
+	if _, err := gcp.GetSignedUrl(probeObjectName); err != nil {
+		signingCheck = newGCSFailedCheck(common.DriverValidationCheckSigning, err, "")
+	}
+	checks = append(checks, signingCheck)
+
+	isUniformAccess := attrs.UniformBucketLevelAccess.Enabled
+	publicCheck := DriverValidationCheck{Name: common.DriverValidationCheckPublicACL, Status: common.DriverValidationStatusPassed}
+	if isUniformAccess {
+		// object ACLs are disabled, public access is granted through the bucket IAM policy
+		policy, err := bucket.IAM().Policy(ctx)
+		if err != nil {
+			publicCheck = newGCSFailedCheck(common.DriverValidationCheckPublicACL, err, "storage.buckets.getIamPolicy")
+			publicCheck.Status = common.DriverValidationStatusWarning
+		} else {
+			publicCheck.Value = policy.HasRole("allUsers", "roles/storage.objectViewer")
+		}
+	} else {
+		isPublic := false
+		for _, entity := range attrs.ACL {
+			if entity.Entity == storage.AllUsers && entity.Role == storage.RoleReader {
+				isPublic = true
+				break
+			}
+		}
+		publicCheck.Value = isPublic
+	}
+	checks = append(checks, publicCheck)
+
+	checks = append(checks, DriverValidationCheck{
+		Name:   common.DriverValidationCheckUniformAccess,
+		Status: common.DriverValidationStatusPassed,
+		Value:  isUniformAccess,
+	})
+
+	return NewDriverValidationReport(checks)
+}
+
+func (gcp *GCPDriverClient) runWriteDeleteProbe(ctx context.Context, probeObjectName string) DriverValidationCheck {
+	object := gcp.GetBucket().Object(probeObjectName)
+	writer := object.NewWriter(ctx)
+	if _, err := writer.Write([]byte(probeObjectName)); err != nil {
+		writer.Close()
+		return newGCSFailedCheck(common.DriverValidationCheckWriteDelete, err, "storage.objects.create")
+	}
+	if err := writer.Close(); err != nil {
+		return newGCSFailedCheck(common.DriverValidationCheckWriteDelete, err, "storage.objects.create")
+	}
+	if err := object.Delete(ctx); err != nil {
+		return newGCSFailedCheck(common.DriverValidationCheckWriteDelete, err, "storage.objects.delete")
+	}
+	return DriverValidationCheck{Name: common.DriverValidationCheckWriteDelete, Status: common.DriverValidationStatusPassed}
+}
+
 func (gcp *GCPDriverClient) Close() {
 	if err := gcp.client.Close(); err != nil {
 		log.Printf("Error closing gcp driver client %v", err)
 	}
 }
+
+// newGCSFailedCheck describes a failed GCS call, naming the permission it needs when it was denied.
+func newGCSFailedCheck(name string, err error, permission string) DriverValidationCheck {
+	check := DriverValidationCheck{Name: name, Status: common.DriverValidationStatusFailed, Error: err.Error()}
+	var apiErr *googleapi.Error
+	if errors.Is(err, storage.ErrBucketNotExist) {
+		check.Error = common.ErrBucketNotExistMsg
+	} else if errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden && permission != "" {
+		check.MissingPermissions = []string{permission}
+	}
+	return check
+}
+
+func newObjectAttrsFromGCS(attrs *storage.ObjectAttrs) *ObjectAttrs {
+	return &ObjectAttrs{
+		Name:               attrs.Name,
+		Size:               attrs.Size,
+		ContentType:        attrs.ContentType,
+		CacheControl:       attrs.CacheControl,
+		ContentDisposition: attrs.ContentDisposition,
+		ContentLanguage:    attrs.ContentLanguage,
+		ContentEncoding:    attrs.ContentEncoding,
+		StorageClass:       attrs.StorageClass,
+		MediaLink:          attrs.MediaLink,
+		MD5:                attrs.MD5,
+		CRC32C:             attrs.CRC32C,
+		Metadata:           attrs.Metadata,
+		UpdatedAt:          attrs.Updated,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 
+	"github.com/sibeur/gotaro/core/common"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -61,3 +62,71 @@ func (conf *GCSDriverConfig) ToBSON() (bson.M, error) {
 	}
 	return bsonM, nil
 }
+
+var gcsDriverTypeInfo = &DriverTypeInfo{
+	Type:        GCSDriverType,
+	Name:        "gcs",
+	DisplayName: "Google Cloud Storage",
+	Capabilities: DriverCapabilities{
+		SignedURL:       true,
+		PublicACL:       true,
+		PresignedUpload: false,
+		Delete:          true,
+		RangeRead:       true,
+	},
+	ConfigSchema: common.GotaroMap{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"type":                 "object",
+		"required":             []string{"project_id", "bucket_name", "default_folder", "service_account"},
+		"additionalProperties": false,
+		"properties": common.GotaroMap{
+			"project_id": common.GotaroMap{
+				"type":      "string",
+				"title":     "Project ID",
+				"minLength": 1,
+			},
+			"bucket_name": common.GotaroMap{
+				"type":      "string",
+				"title":     "Bucket name",
+				"minLength": 3,
+				"maxLength": 222,
+				"pattern":   "^[a-z0-9][a-z0-9._-]*[a-z0-9]$",
+			},
+			"default_folder": common.GotaroMap{
+				"type":      "string",
+				"title":     "Default folder",
+				"minLength": 1,
+			},
+			"service_account": common.GotaroMap{
+				"type":        "object",
+				"title":       "Service account key",
+				"description": "JSON key of a service account with access to the bucket",
+				"writeOnly":   true,
+				"required":    []string{"type", "project_id", "private_key", "client_email"},
+				"properties": common.GotaroMap{
+					"type":         common.GotaroMap{"type": "string", "enum": []any{"service_account"}},
+					"project_id":   common.GotaroMap{"type": "string"},
+					"private_key":  common.GotaroMap{"type": "string"},
+					"client_email": common.GotaroMap{"type": "string"},
+				},
+			},
+		},
+	},
+	SecretFields: []string{"service_account"},
+	NewConfig:    newGCSDriverConfigFromMap,
+}
+
+func newGCSDriverConfigFromMap(config map[string]any) (map[string]any, error) {
+	var serviceAccount []byte
+	if config["service_account"] != nil {
+		var err error
+		serviceAccount, err = json.Marshal(config["service_account"])
+		if err != nil {
+			return nil, err
+		}
+	}
+	projectID, _ := config["project_id"].(string)
+	bucketName, _ := config["bucket_name"].(string)
+	defaultFolder, _ := config["default_folder"].(string)
+	return NewGCSDriverConfig(projectID, bucketName, defaultFolder, serviceAccount).ToMap(), nil
+}
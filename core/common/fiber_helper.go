@@ -2,6 +2,7 @@ package common
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -18,6 +19,17 @@ func NewFiberErrorMessage(key, value string) FiberErrorMessage {
 	}
 }
 
+const bearerPrefix = "Bearer "
+
+// GetBearerToken returns the token of a bearer Authorization header.
+func GetBearerToken(c *fiber.Ctx) (string, bool) {
+	authorization := c.Get(fiber.HeaderAuthorization)
+	if len(authorization) <= len(bearerPrefix) || !strings.EqualFold(authorization[:len(bearerPrefix)], bearerPrefix) {
+		return "", false
+	}
+	return authorization[len(bearerPrefix):], true
+}
+
 // Default error handler
 var FiberDefaultErrorHandler = func(c *fiber.Ctx, err error) error {
 	// Status code defaults to 500
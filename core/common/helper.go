@@ -1,9 +1,17 @@
 package common
 
 import (
+	"crypto/md5"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math/big"
 	"math/rand"
+	"mime"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 	"unicode"
@@ -129,6 +137,54 @@ func CreateFolder(path string) error {
 	return nil
 }
 
+func CopyFile(src, dst string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		return err
+	}
+	return nil
+}
+
+func GetFileMD5(fileName string) ([]byte, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// GetFileSHA256 returns the hex encoded SHA-256 checksum of a file.
+func GetFileSHA256(fileName string) (string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 func RandomString(n int) string {
 	var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
 	b := make([]rune, n)
@@ -138,6 +194,60 @@ func RandomString(n int) string {
 	return string(b)
 }
 
+// SecureRandomString returns a random string from a cryptographically secure source, for secrets.
+func SecureRandomString(n int) (string, error) {
+	letters := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, n)
+	for i := range b {
+		index, err := crand.Int(crand.Reader, big.NewInt(int64(len(letters))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = letters[index.Int64()]
+	}
+	return string(b), nil
+}
+
+// GetStringSHA256 returns the hex SHA-256 of a string, to store and look up high entropy secrets.
+func GetStringSHA256(value string) string {
+	hash := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(hash[:])
+}
+
+// inlineContentTypes are the content types safe to serve as they are, scripted types like svg are left out.
+var inlineContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+	"image/avif": true,
+}
+
+// GetDownloadContentType returns the content type to serve a file with, application/octet-stream for a type
+// the browser could run.
+func GetDownloadContentType(fileMime string) string {
+	mediaType, _, err := mime.ParseMediaType(fileMime)
+	if err != nil || !inlineContentTypes[mediaType] {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// IsIssuedBeforeValidAfter checks a token was issued before the time its tokens are valid after. The
+// issued at claim only holds seconds, so validAfter is compared at the second too.
+func IsIssuedBeforeValidAfter(issuedAt time.Time, validAfter time.Time) bool {
+	return !validAfter.IsZero() && issuedAt.Truncate(time.Second).Before(validAfter.Truncate(time.Second))
+}
+
+// IsSameStringSet checks two arrays hold the same strings, whatever their order.
+func IsSameStringSet(array []string, otherArray []string) bool {
+	list := UniqueArrayString(array)
+	slices.Sort(list)
+	otherList := UniqueArrayString(otherArray)
+	slices.Sort(otherList)
+	return slices.Equal(list, otherList)
+}
+
 func UniqueArrayString(array []string) []string {
 	keys := make(map[string]bool)
 	list := []string{}
@@ -0,0 +1,62 @@
+package common_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+)
+
+func TestIsIssuedBeforeValidAfter(t *testing.T) {
+	validAfter := time.Date(2024, 5, 1, 10, 0, 0, int(time.Millisecond*600), time.UTC)
+
+	tests := []struct {
+		issuedAt   time.Time
+		validAfter time.Time
+		isBefore   bool
+	}{
+		{validAfter.Add(-time.Second), validAfter, true},
+		{validAfter.Truncate(time.Second), validAfter, false},
+		{validAfter.Add(time.Second), validAfter, false},
+		{validAfter.Add(-time.Hour), time.Time{}, false},
+	}
+	for _, test := range tests {
+		if isBefore := common.IsIssuedBeforeValidAfter(test.issuedAt, test.validAfter); isBefore != test.isBefore {
+			t.Errorf("Expected %v issued before %v to be %v, got %v", test.issuedAt, test.validAfter, test.isBefore, isBefore)
+		}
+	}
+}
+
+func TestGetDownloadContentType(t *testing.T) {
+	tests := map[string]string{
+		"image/png":                 "image/png",
+		"image/jpeg; charset=utf-8": "image/jpeg",
+		"image/svg+xml":             "application/octet-stream",
+		"text/html":                 "application/octet-stream",
+		"":                          "application/octet-stream",
+	}
+	for fileMime, expected := range tests {
+		if contentType := common.GetDownloadContentType(fileMime); contentType != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, fileMime, contentType)
+		}
+	}
+}
+
+func TestIsSameStringSet(t *testing.T) {
+	tests := []struct {
+		array      []string
+		otherArray []string
+		isSame     bool
+	}{
+		{[]string{"a", "b"}, []string{"b", "a"}, true},
+		{[]string{"a", "a", "b"}, []string{"b", "a"}, true},
+		{nil, []string{}, true},
+		{[]string{"a"}, []string{"a", "b"}, false},
+		{[]string{"a", "c"}, []string{"a", "b"}, false},
+	}
+	for _, test := range tests {
+		if isSame := common.IsSameStringSet(test.array, test.otherArray); isSame != test.isSame {
+			t.Errorf("Expected %v and %v same to be %v, got %v", test.array, test.otherArray, test.isSame, isSame)
+		}
+	}
+}
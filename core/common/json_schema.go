@@ -0,0 +1,155 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+)
+
+// ValidateJSONSchema checks a value decoded from JSON against a schema. Only the keywords used by the
+// driver config schemas are supported: type, properties, required, additionalProperties, items, enum,
+// minLength, maxLength, pattern, minimum and maximum. Errors are keyed by the path of the invalid value.
+func ValidateJSONSchema(schema GotaroMap, value any, path string) []FiberErrorMessage {
+	errs := make([]FiberErrorMessage, 0)
+	validateJSONSchemaValue(schema, value, path, &errs)
+	return errs
+}
+
+func validateJSONSchemaValue(schema map[string]any, value any, path string, errs *[]FiberErrorMessage) {
+	addError := func(format string, args ...any) {
+		*errs = append(*errs, NewFiberErrorMessage(path, fmt.Sprintf("%s "+format, append([]any{path}, args...)...)))
+	}
+
+	if schemaType, isExist := schema["type"].(string); isExist && !isJSONSchemaType(schemaType, value) {
+		addError("must be of type %s", schemaType)
+		return
+	}
+
+	if enum, isExist := schema["enum"].([]any); isExist && !slices.Contains(enum, value) {
+		addError("must be one of %v", enum)
+	}
+
+	switch typedValue := value.(type) {
+	case string:
+		if minLength, isExist := getJSONSchemaNumber(schema, "minLength"); isExist && float64(len(typedValue)) < minLength {
+			addError("must have a minimum length of %v", minLength)
+		}
+		if maxLength, isExist := getJSONSchemaNumber(schema, "maxLength"); isExist && float64(len(typedValue)) > maxLength {
+			addError("must have a maximum length of %v", maxLength)
+		}
+		if pattern, isExist := schema["pattern"].(string); isExist {
+			if isMatch, err := regexp.MatchString(pattern, typedValue); err != nil || !isMatch {
+				addError("must match pattern %s", pattern)
+			}
+		}
+	case float64:
+		if minimum, isExist := getJSONSchemaNumber(schema, "minimum"); isExist && typedValue < minimum {
+			addError("must have a minimum value of %v", minimum)
+		}
+		if maximum, isExist := getJSONSchemaNumber(schema, "maximum"); isExist && typedValue > maximum {
+			addError("must have a maximum value of %v", maximum)
+		}
+	case []any:
+		if itemSchema, isExist := toJSONSchemaMap(schema["items"]); isExist {
+			for i, item := range typedValue {
+				validateJSONSchemaValue(itemSchema, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case map[string]any:
+		validateJSONSchemaObject(schema, typedValue, path, errs)
+	}
+}
+
+func validateJSONSchemaObject(schema map[string]any, value map[string]any, path string, errs *[]FiberErrorMessage) {
+	properties, _ := toJSONSchemaMap(schema["properties"])
+
+	required := make([]string, 0)
+	switch typedRequired := schema["required"].(type) {
+	case []string:
+		required = typedRequired
+	case []any:
+		for _, field := range typedRequired {
+			required = append(required, fmt.Sprint(field))
+		}
+	}
+	for _, field := range required {
+		if _, isExist := value[field]; !isExist {
+			fieldPath := joinJSONSchemaPath(path, field)
+			*errs = append(*errs, NewFiberErrorMessage(fieldPath, fmt.Sprintf("%s is a required field", fieldPath)))
+		}
+	}
+
+	// sort the fields so errors come in a stable order
+	fields := make([]string, 0, len(value))
+	for field := range value {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		fieldPath := joinJSONSchemaPath(path, field)
+		propertySchema, isExist := toJSONSchemaMap(properties[field])
+		if !isExist {
+			if schema["additionalProperties"] == false {
+				*errs = append(*errs, NewFiberErrorMessage(fieldPath, fmt.Sprintf("%s is not allowed", fieldPath)))
+			}
+			continue
+		}
+		validateJSONSchemaValue(propertySchema, value[field], fieldPath, errs)
+	}
+}
+
+func isJSONSchemaType(schemaType string, value any) bool {
+	switch schemaType {
+	case "object":
+		_, isObject := value.(map[string]any)
+		return isObject
+	case "array":
+		_, isArray := value.([]any)
+		return isArray
+	case "string":
+		_, isString := value.(string)
+		return isString
+	case "boolean":
+		_, isBool := value.(bool)
+		return isBool
+	case "number":
+		_, isNumber := value.(float64)
+		return isNumber
+	case "integer":
+		number, isNumber := value.(float64)
+		return isNumber && number == math.Trunc(number)
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func getJSONSchemaNumber(schema map[string]any, keyword string) (float64, bool) {
+	switch number := schema[keyword].(type) {
+	case int:
+		return float64(number), true
+	case float64:
+		return number, true
+	}
+	return 0, false
+}
+
+func toJSONSchemaMap(value any) (map[string]any, bool) {
+	switch schema := value.(type) {
+	case GotaroMap:
+		return schema, true
+	case map[string]any:
+		return schema, true
+	}
+	return nil, false
+}
+
+func joinJSONSchemaPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
@@ -0,0 +1,73 @@
+package common_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sibeur/gotaro/core/common"
+)
+
+var testSchema = common.GotaroMap{
+	"type":                 "object",
+	"required":             []string{"bucket_name", "account"},
+	"additionalProperties": false,
+	"properties": common.GotaroMap{
+		"bucket_name": common.GotaroMap{"type": "string", "minLength": 3, "pattern": "^[a-z0-9._-]+$"},
+		"class":       common.GotaroMap{"type": "string", "enum": []any{"STANDARD", "NEARLINE"}},
+		"retries":     common.GotaroMap{"type": "integer", "minimum": 0, "maximum": 5},
+		"account": common.GotaroMap{
+			"type":     "object",
+			"required": []string{"client_email"},
+			"properties": common.GotaroMap{
+				"client_email": common.GotaroMap{"type": "string"},
+			},
+		},
+	},
+}
+
+func decodeJSON(t *testing.T, data string) any {
+	var value any
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		t.Fatalf("Error decoding %v: %v", data, err)
+	}
+	return value
+}
+
+func TestValidateJSONSchemaValid(t *testing.T) {
+	value := decodeJSON(t, `{"bucket_name": "media-bucket", "class": "NEARLINE", "retries": 3, "account": {"client_email": "a@b.c"}}`)
+
+	if errs := common.ValidateJSONSchema(testSchema, value, "driver_config"); len(errs) > 0 {
+		t.Errorf("Expected no error, got %v", errs)
+	}
+}
+
+func TestValidateJSONSchemaInvalid(t *testing.T) {
+	value := decodeJSON(t, `{"bucket_name": "AB", "class": "COLD", "retries": 1.5, "account": {}, "region": "asia"}`)
+
+	errs := common.ValidateJSONSchema(testSchema, value, "driver_config")
+
+	expectedKeys := []string{
+		"driver_config.account.client_email",
+		"driver_config.bucket_name",
+		"driver_config.bucket_name",
+		"driver_config.class",
+		"driver_config.region",
+		"driver_config.retries",
+	}
+	if len(errs) != len(expectedKeys) {
+		t.Fatalf("Expected %v errors, got %v", len(expectedKeys), errs)
+	}
+	for i, err := range errs {
+		if err.Key != expectedKeys[i] {
+			t.Errorf("Expected error %v on %v, got %v", i, expectedKeys[i], err.Key)
+		}
+	}
+}
+
+func TestValidateJSONSchemaType(t *testing.T) {
+	errs := common.ValidateJSONSchema(testSchema, decodeJSON(t, `"bucket"`), "driver_config")
+
+	if len(errs) != 1 || errs[0].Value != "driver_config must be of type object" {
+		t.Errorf("Expected type error, got %v", errs)
+	}
+}
@@ -0,0 +1,18 @@
+package common
+
+import "time"
+
+// GetLoginLockDuration returns how long a key or IP waits before its next login after a number of
+// consecutive failures. The first failure is free, each next one doubles the delay from a second up
+// to MaxLoginDelay, and reaching maxFailures locks it out.
+func GetLoginLockDuration(failures uint64, maxFailures uint64, lockout time.Duration) time.Duration {
+	if maxFailures > 0 && failures >= maxFailures {
+		return lockout
+	}
+	if failures < 2 {
+		return 0
+	}
+	// the shift is bound so the delay can not overflow
+	delay := time.Second << min(failures-2, 16)
+	return min(delay, MaxLoginDelay)
+}
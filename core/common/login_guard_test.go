@@ -0,0 +1,32 @@
+package common_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+)
+
+func TestGetLoginLockDuration(t *testing.T) {
+	lockout := time.Minute * 15
+	tests := []struct {
+		failures    uint64
+		maxFailures uint64
+		duration    time.Duration
+	}{
+		{0, 5, 0},
+		{1, 5, 0},
+		{2, 5, time.Second},
+		{3, 5, time.Second * 2},
+		{4, 5, time.Second * 4},
+		{5, 5, lockout},
+		{6, 5, lockout},
+		{10, 20, common.MaxLoginDelay},
+		{100, 0, common.MaxLoginDelay},
+	}
+	for _, test := range tests {
+		if duration := common.GetLoginLockDuration(test.failures, test.maxFailures, lockout); duration != test.duration {
+			t.Errorf("Expected %v failures of %v to wait %v, got %v", test.failures, test.maxFailures, test.duration, duration)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package common
+
+import "slices"
+
+// migrationJobTransitions are the statuses a migration job can move to from each status. A running
+// job taken over from an instance whose lease expired stays running, it is not a transition.
+var migrationJobTransitions = map[string][]string{
+	MigrationJobStatusPending: {MigrationJobStatusRunning, MigrationJobStatusPaused, MigrationJobStatusCancelled},
+	MigrationJobStatusRunning: {MigrationJobStatusPaused, MigrationJobStatusCancelled, MigrationJobStatusCompleted, MigrationJobStatusFailed},
+	MigrationJobStatusPaused:  {MigrationJobStatusRunning, MigrationJobStatusCancelled},
+	MigrationJobStatusFailed:  {MigrationJobStatusRunning, MigrationJobStatusCancelled},
+}
+
+// CanMigrationJobMove tells whether a migration job can move from a status to another.
+func CanMigrationJobMove(from string, to string) bool {
+	return slices.Contains(migrationJobTransitions[from], to)
+}
+
+// GetMigrationJobStatusesTo returns the statuses a migration job can move to a status from, for the
+// updates to only apply to the jobs still in one of them.
+func GetMigrationJobStatusesTo(to string) []string {
+	statuses := make([]string, 0)
+	for from, nextStatuses := range migrationJobTransitions {
+		if slices.Contains(nextStatuses, to) {
+			statuses = append(statuses, from)
+		}
+	}
+	slices.Sort(statuses)
+	return statuses
+}
@@ -0,0 +1,53 @@
+package common_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/sibeur/gotaro/core/common"
+)
+
+func TestCanMigrationJobMove(t *testing.T) {
+	tests := []struct {
+		from    string
+		to      string
+		canMove bool
+	}{
+		{common.MigrationJobStatusPending, common.MigrationJobStatusRunning, true},
+		{common.MigrationJobStatusRunning, common.MigrationJobStatusPaused, true},
+		{common.MigrationJobStatusRunning, common.MigrationJobStatusCancelled, true},
+		{common.MigrationJobStatusRunning, common.MigrationJobStatusCompleted, true},
+		{common.MigrationJobStatusRunning, common.MigrationJobStatusFailed, true},
+		{common.MigrationJobStatusPaused, common.MigrationJobStatusRunning, true},
+		{common.MigrationJobStatusFailed, common.MigrationJobStatusRunning, true},
+		{common.MigrationJobStatusPaused, common.MigrationJobStatusCancelled, true},
+		// a paused or cancelled job is never completed by a worker that did not see the change yet
+		{common.MigrationJobStatusPaused, common.MigrationJobStatusCompleted, false},
+		{common.MigrationJobStatusCancelled, common.MigrationJobStatusCompleted, false},
+		{common.MigrationJobStatusPaused, common.MigrationJobStatusPaused, false},
+		{common.MigrationJobStatusRunning, common.MigrationJobStatusRunning, false},
+		{common.MigrationJobStatusCompleted, common.MigrationJobStatusRunning, false},
+		{common.MigrationJobStatusCompleted, common.MigrationJobStatusCancelled, false},
+		{common.MigrationJobStatusCancelled, common.MigrationJobStatusRunning, false},
+		{common.MigrationJobStatusFailed, common.MigrationJobStatusPaused, false},
+	}
+	for _, test := range tests {
+		if canMove := common.CanMigrationJobMove(test.from, test.to); canMove != test.canMove {
+			t.Errorf("Expected %v to %v to be %v, got %v", test.from, test.to, test.canMove, canMove)
+		}
+	}
+}
+
+func TestGetMigrationJobStatusesTo(t *testing.T) {
+	tests := map[string][]string{
+		common.MigrationJobStatusRunning:   {common.MigrationJobStatusFailed, common.MigrationJobStatusPaused, common.MigrationJobStatusPending},
+		common.MigrationJobStatusCompleted: {common.MigrationJobStatusRunning},
+		common.MigrationJobStatusPaused:    {common.MigrationJobStatusPending, common.MigrationJobStatusRunning},
+		common.MigrationJobStatusCancelled: {common.MigrationJobStatusFailed, common.MigrationJobStatusPaused, common.MigrationJobStatusPending, common.MigrationJobStatusRunning},
+	}
+	for to, expectedStatuses := range tests {
+		if statuses := common.GetMigrationJobStatusesTo(to); !slices.Equal(statuses, expectedStatuses) {
+			t.Errorf("Expected statuses to %v to be %v, got %v", to, expectedStatuses, statuses)
+		}
+	}
+}
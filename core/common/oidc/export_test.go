@@ -0,0 +1,8 @@
+package oidc
+
+import "net/http"
+
+// SetHTTPClient lets the tests fetch the keys from an issuer served with a test certificate.
+func SetHTTPClient(v *Verifier, client *http.Client) {
+	v.httpClient = client
+}
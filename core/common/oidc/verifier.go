@@ -0,0 +1,440 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// DefaultJWKSCacheTTL is how long the keys of an issuer are used before being fetched again
+	DefaultJWKSCacheTTL = time.Hour
+	// MinJWKSRefreshInterval bounds how often the keys are fetched again for a token signed by an
+	// unknown key or after a failure, a shorter cache TTL bounds it instead
+	MinJWKSRefreshInterval = time.Minute
+	// ClockSkew is the leeway given to the time claims, the issuer clock is not ours
+	ClockSkew = time.Second * 30
+
+	discoveryPath = "/.well-known/openid-configuration"
+	fetchTimeout  = time.Second * 10
+)
+
+var (
+	ErrIssuerInvalid   = errors.New("oidc issuer must have a name, an issuer url and an audience")
+	ErrIssuerInsecure  = errors.New("oidc issuer url must be an https url")
+	ErrIssuerDuplicate = errors.New("oidc issuer name and url must be unique")
+	ErrIssuerNotFound  = errors.New("oidc issuer not configured")
+	ErrKeyNotFound     = errors.New("oidc signing key not found")
+	ErrDiscovery       = errors.New("oidc discovery document invalid")
+	ErrNoScope         = errors.New("oidc token claims map to no scope")
+)
+
+// validMethods are the algorithms accepted from an issuer, never a shared secret nor none.
+var validMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "EdDSA"}
+
+// ClaimMapping grants scopes to the tokens whose claim holds a value, the claim is a dot separated
+// path, e.g. "realm_access.roles", and may hold a string or a list of strings.
+type ClaimMapping struct {
+	Claim  string   `json:"claim"`
+	Value  string   `json:"value"`
+	Scopes []string `json:"scopes"`
+}
+
+// Limits caps the requests and uploads of each caller of an issuer, the way the limits of an api
+// client cap its own.
+type Limits struct {
+	UploadRequestsPerMinute uint32 `json:"upload_requests_per_minute,omitempty"`
+	ReadRequestsPerMinute   uint32 `json:"read_requests_per_minute,omitempty"`
+	ManageRequestsPerMinute uint32 `json:"manage_requests_per_minute,omitempty"`
+	UploadBytesPerDay       uint64 `json:"upload_bytes_per_day,omitempty"`
+	UploadBytesPerMonth     uint64 `json:"upload_bytes_per_month,omitempty"`
+}
+
+// Issuer is an identity provider whose tokens are accepted when they are meant for the audience.
+// Name prefixes the subjects of its tokens so they never collide with the api client ids.
+type Issuer struct {
+	Name          string         `json:"name"`
+	URL           string         `json:"issuer"`
+	Audience      string         `json:"audience"`
+	ClaimMappings []ClaimMapping `json:"claim_mappings"`
+	// Limits are the default limits of the callers of the issuer, nil for no limit
+	Limits *Limits `json:"limits,omitempty"`
+}
+
+// Identity is the caller of a verified token.
+type Identity struct {
+	Issuer  string
+	Subject string
+	Scopes  []string
+}
+
+// GetClientID returns the id standing for the caller wherever an api client id is expected.
+func (i *Identity) GetClientID() string {
+	return i.Issuer + ":" + i.Subject
+}
+
+// issuerKeys are the keys of an issuer as last fetched from its JWKS.
+type issuerKeys struct {
+	mutex       sync.Mutex
+	keys        map[string]*jwk
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+type jwk struct {
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+	publicKey crypto.PublicKey
+}
+
+// Verifier verifies the tokens of the configured issuers with the keys published by their
+// discovery document. The keys are cached for cacheTTL and fetched again early when a token names
+// an unknown key, so the issuers can rotate them.
+type Verifier struct {
+	issuers         map[string]*Issuer
+	issuerNames     map[string]*Issuer
+	keys            map[string]*issuerKeys
+	cacheTTL        time.Duration
+	refreshInterval time.Duration
+	httpClient      *http.Client
+}
+
+// NewVerifier trusts the issuers, each with a unique name and an https url as their keys are fetched
+// from it.
+func NewVerifier(issuers []*Issuer, cacheTTL time.Duration) (*Verifier, error) {
+	if cacheTTL <= 0 {
+		cacheTTL = DefaultJWKSCacheTTL
+	}
+	verifier := &Verifier{
+		issuers:         make(map[string]*Issuer),
+		issuerNames:     make(map[string]*Issuer),
+		keys:            make(map[string]*issuerKeys),
+		cacheTTL:        cacheTTL,
+		refreshInterval: min(cacheTTL, MinJWKSRefreshInterval),
+		httpClient:      &http.Client{Timeout: fetchTimeout},
+	}
+	for _, issuer := range issuers {
+		if issuer.Name == "" || issuer.URL == "" || issuer.Audience == "" || strings.Contains(issuer.Name, ":") {
+			return nil, ErrIssuerInvalid
+		}
+		issuerURL, err := url.Parse(issuer.URL)
+		if err != nil || issuerURL.Scheme != "https" || issuerURL.Host == "" {
+			return nil, ErrIssuerInsecure
+		}
+		_, isNameExist := verifier.issuerNames[issuer.Name]
+		_, isURLExist := verifier.issuers[issuer.URL]
+		if isNameExist || isURLExist {
+			return nil, ErrIssuerDuplicate
+		}
+		verifier.issuers[issuer.URL] = issuer
+		verifier.issuerNames[issuer.Name] = issuer
+		verifier.keys[issuer.URL] = &issuerKeys{}
+	}
+	return verifier, nil
+}
+
+// NewVerifierFromEnv loads the issuers from OIDC_ISSUERS or OIDC_ISSUERS_FILE, both as a JSON list
+// of issuers. OIDC_JWKS_CACHE_MINUTES overrides how long their keys are cached.
+func NewVerifierFromEnv() (*Verifier, error) {
+	rawIssuers := os.Getenv("OIDC_ISSUERS")
+	if issuersFile := os.Getenv("OIDC_ISSUERS_FILE"); issuersFile != "" {
+		fileContent, err := os.ReadFile(issuersFile)
+		if err != nil {
+			return nil, err
+		}
+		rawIssuers = string(fileContent)
+	}
+
+	issuers := make([]*Issuer, 0)
+	if strings.TrimSpace(rawIssuers) != "" {
+		if err := json.Unmarshal([]byte(rawIssuers), &issuers); err != nil {
+			return nil, fmt.Errorf("oidc issuers invalid: %w", err)
+		}
+	}
+
+	cacheTTL := DefaultJWKSCacheTTL
+	if minutes, err := strconv.Atoi(os.Getenv("OIDC_JWKS_CACHE_MINUTES")); err == nil && minutes > 0 {
+		cacheTTL = time.Minute * time.Duration(minutes)
+	}
+	return NewVerifier(issuers, cacheTTL)
+}
+
+func (v *Verifier) IsEnabled() bool {
+	return v != nil && len(v.issuers) > 0
+}
+
+// GetLimits returns the limits of the issuer of a caller by its client id, nil when the caller is
+// not of an issuer or the issuer has no limits.
+func (v *Verifier) GetLimits(clientID string) *Limits {
+	if !v.IsEnabled() {
+		return nil
+	}
+	issuerName, _, isIssued := strings.Cut(clientID, ":")
+	if !isIssued {
+		return nil
+	}
+	if issuer, isExist := v.issuerNames[issuerName]; isExist {
+		return issuer.Limits
+	}
+	return nil
+}
+
+// IsTrusted tells whether a token claims to come from a configured issuer, without verifying it.
+func (v *Verifier) IsTrusted(tokenString string) bool {
+	if !v.IsEnabled() {
+		return false
+	}
+	_, isExist := v.issuers[getUnverifiedIssuer(tokenString)]
+	return isExist
+}
+
+// Verify checks a token was signed by its issuer for the audience and is not expired, then maps its
+// claims to scopes.
+func (v *Verifier) Verify(tokenString string) (*Identity, error) {
+	if !v.IsEnabled() {
+		return nil, ErrIssuerNotFound
+	}
+	issuer, isExist := v.issuers[getUnverifiedIssuer(tokenString)]
+	if !isExist {
+		return nil, ErrIssuerNotFound
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		keyID, _ := token.Header["kid"].(string)
+		key, err := v.getKey(issuer, keyID)
+		if err != nil {
+			return nil, err
+		}
+		if key.Algorithm != "" && key.Algorithm != token.Method.Alg() {
+			return nil, ErrKeyNotFound
+		}
+		return key.publicKey, nil
+	},
+		jwt.WithValidMethods(validMethods),
+		jwt.WithIssuer(issuer.URL),
+		jwt.WithAudience(issuer.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(ClockSkew),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	scopes := issuer.GetScopes(map[string]any(claims))
+	if len(scopes) == 0 {
+		return nil, ErrNoScope
+	}
+	return &Identity{Issuer: issuer.Name, Subject: subject, Scopes: scopes}, nil
+}
+
+// GetScopes returns the scopes granted by the claim mappings matching the claims.
+func (i *Issuer) GetScopes(claims map[string]any) []string {
+	scopes := make([]string, 0)
+	for _, mapping := range i.ClaimMappings {
+		if !hasClaimValue(claims, mapping.Claim, mapping.Value) {
+			continue
+		}
+		for _, scope := range mapping.Scopes {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+func hasClaimValue(claims map[string]any, claim string, value string) bool {
+	var claimValue any = claims
+	for _, field := range strings.Split(claim, ".") {
+		object, isObject := claimValue.(map[string]any)
+		if !isObject {
+			return false
+		}
+		claimValue = object[field]
+	}
+
+	switch typedValue := claimValue.(type) {
+	case string:
+		return typedValue == value
+	case []any:
+		return slices.Contains(typedValue, any(value))
+	}
+	return false
+}
+
+// getKey returns a key of an issuer, fetching the keys when the cache expired or the key is unknown,
+// once per refresh interval at most. A token without kid uses the only key of the issuer.
+func (v *Verifier) getKey(issuer *Issuer, keyID string) (*jwk, error) {
+	cachedKeys := v.keys[issuer.URL]
+	cachedKeys.mutex.Lock()
+	defer cachedKeys.mutex.Unlock()
+
+	key := cachedKeys.find(keyID)
+	isExpired := time.Since(cachedKeys.fetchedAt) > v.cacheTTL
+	if (isExpired || key == nil) && time.Since(cachedKeys.attemptedAt) > v.refreshInterval {
+		cachedKeys.attemptedAt = time.Now()
+		keys, err := v.fetchKeys(issuer)
+		if err != nil {
+			// the cached keys keep working while the issuer can not be reached
+			log.Printf("Error fetching keys of oidc issuer %v: %v", issuer.Name, err)
+			if key == nil {
+				return nil, err
+			}
+			return key, nil
+		}
+		cachedKeys.keys = keys
+		cachedKeys.fetchedAt = time.Now()
+		key = cachedKeys.find(keyID)
+	}
+
+	if key == nil {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+func (k *issuerKeys) find(keyID string) *jwk {
+	if keyID == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key
+		}
+	}
+	return k.keys[keyID]
+}
+
+// fetchKeys reads the JWKS of an issuer from the location given by its discovery document, the
+// keys of an unsupported type or not meant for signatures are skipped.
+func (v *Verifier) fetchKeys(issuer *Issuer) (map[string]*jwk, error) {
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := v.getJSON(strings.TrimSuffix(issuer.URL, "/")+discoveryPath, &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.Issuer != issuer.URL || discovery.JWKSURI == "" {
+		return nil, ErrDiscovery
+	}
+
+	var jwks struct {
+		Keys []*jwk `json:"keys"`
+	}
+	if err := v.getJSON(discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*jwk)
+	for _, key := range jwks.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		publicKey, err := key.parsePublicKey()
+		if err != nil {
+			log.Printf("Skipping key %v of oidc issuer %v: %v", key.KeyID, issuer.Name, err)
+			continue
+		}
+		key.publicKey = publicKey
+		keys[key.KeyID] = key
+	}
+	return keys, nil
+}
+
+func (v *Verifier) getJSON(url string, target any) error {
+	response, err := v.httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc request to %v failed with status %v", url, response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(target)
+}
+
+func (key *jwk) parsePublicKey() (crypto.PublicKey, error) {
+	switch key.KeyType {
+	case "RSA":
+		n, err := decode(key.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(key.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("curve %v not supported", key.Curve)
+		}
+		x, err := decode(key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(key.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		x, err := decode(key.X)
+		if err != nil {
+			return nil, err
+		}
+		if key.Curve != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("curve %v not supported", key.Curve)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("key type %v not supported", key.KeyType)
+}
+
+func getUnverifiedIssuer(tokenString string) string {
+	claims := jwt.RegisteredClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, &claims); err != nil {
+		return ""
+	}
+	return claims.Issuer
+}
+
+func decode(data string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(data)
+}
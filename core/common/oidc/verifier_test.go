@@ -0,0 +1,301 @@
+package oidc_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sibeur/gotaro/core/common/oidc"
+)
+
+// stubIssuer serves a discovery document and the JWKS of its keys, counting the JWKS requests.
+type stubIssuer struct {
+	server    *httptest.Server
+	mutex     sync.Mutex
+	keys      map[string]*rsa.PrivateKey
+	jwksCalls atomic.Int32
+	isFailing atomic.Bool
+}
+
+func newStubIssuer(t *testing.T) *stubIssuer {
+	issuer := &stubIssuer{keys: make(map[string]*rsa.PrivateKey)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"issuer": issuer.server.URL, "jwks_uri": issuer.server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		issuer.jwksCalls.Add(1)
+		if issuer.isFailing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		issuer.mutex.Lock()
+		defer issuer.mutex.Unlock()
+		keys := make([]map[string]any, 0)
+		for keyID, key := range issuer.keys {
+			keys = append(keys, map[string]any{
+				"kid": keyID,
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		writeJSON(w, map[string]any{"keys": keys})
+	})
+	issuer.server = httptest.NewTLSServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (s *stubIssuer) addKey(t *testing.T, keyID string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generate key: %v", err)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys[keyID] = key
+}
+
+func (s *stubIssuer) sign(t *testing.T, keyID string, claims jwt.MapClaims) string {
+	s.mutex.Lock()
+	key := s.keys[keyID]
+	s.mutex.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	tokenString, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Error sign token: %v", err)
+	}
+	return tokenString
+}
+
+func (s *stubIssuer) newClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":          s.server.URL,
+		"aud":          "gotaro",
+		"sub":          "billing-service",
+		"exp":          time.Now().Add(time.Minute).Unix(),
+		"groups":       []string{"media-admins", "staff"},
+		"realm_access": map[string]any{"roles": []string{"uploader"}},
+	}
+}
+
+func newTestVerifier(t *testing.T, issuer *stubIssuer, cacheTTL time.Duration) *oidc.Verifier {
+	verifier, err := oidc.NewVerifier([]*oidc.Issuer{{
+		Name:     "corp",
+		URL:      issuer.server.URL,
+		Audience: "gotaro",
+		ClaimMappings: []oidc.ClaimMapping{
+			{Claim: "groups", Value: "media-admins", Scopes: []string{"super-admin"}},
+			{Claim: "realm_access.roles", Value: "uploader", Scopes: []string{"uploader", "super-admin"}},
+			{Claim: "groups", Value: "contractors", Scopes: []string{"reader"}},
+		},
+	}}, cacheTTL)
+	if err != nil {
+		t.Fatalf("Error create verifier: %v", err)
+	}
+	oidc.SetHTTPClient(verifier, issuer.server.Client())
+	return verifier
+}
+
+func writeJSON(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+func TestVerifierVerify(t *testing.T) {
+	issuer := newStubIssuer(t)
+	issuer.addKey(t, "key-1")
+	verifier := newTestVerifier(t, issuer, 0)
+
+	tokenString := issuer.sign(t, "key-1", issuer.newClaims())
+	if !verifier.IsTrusted(tokenString) {
+		t.Fatalf("Expected token of the issuer to be trusted")
+	}
+	identity, err := verifier.Verify(tokenString)
+	if err != nil {
+		t.Fatalf("Error verify token: %v", err)
+	}
+	if identity.GetClientID() != "corp:billing-service" {
+		t.Errorf("Expected client id corp:billing-service, got %v", identity.GetClientID())
+	}
+	if !slices.Equal(identity.Scopes, []string{"super-admin", "uploader"}) {
+		t.Errorf("Expected scopes [super-admin uploader], got %v", identity.Scopes)
+	}
+}
+
+func TestVerifierRejectsInvalidTokens(t *testing.T) {
+	issuer := newStubIssuer(t)
+	issuer.addKey(t, "key-1")
+	verifier := newTestVerifier(t, issuer, 0)
+
+	otherIssuer := newStubIssuer(t)
+	otherIssuer.addKey(t, "key-1")
+
+	wrongAudience := issuer.newClaims()
+	wrongAudience["aud"] = "other-app"
+	expired := issuer.newClaims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	noExpiry := issuer.newClaims()
+	delete(noExpiry, "exp")
+	noScope := issuer.newClaims()
+	noScope["groups"] = []string{"staff"}
+	delete(noScope, "realm_access")
+
+	hmacToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, issuer.newClaims()).SignedString([]byte("secret"))
+	forgedToken := otherIssuer.sign(t, "key-1", issuer.newClaims())
+
+	tests := map[string]string{
+		"wrong audience": issuer.sign(t, "key-1", wrongAudience),
+		"expired":        issuer.sign(t, "key-1", expired),
+		"no expiry":      issuer.sign(t, "key-1", noExpiry),
+		"no scope":       issuer.sign(t, "key-1", noScope),
+		"hmac":           hmacToken,
+		"forged":         forgedToken,
+	}
+	for name, tokenString := range tests {
+		if _, err := verifier.Verify(tokenString); err == nil {
+			t.Errorf("Expected %v token to be invalid", name)
+		}
+	}
+
+	untrustedToken := otherIssuer.sign(t, "key-1", otherIssuer.newClaims())
+	if verifier.IsTrusted(untrustedToken) {
+		t.Errorf("Expected token of an other issuer not to be trusted")
+	}
+	if _, err := verifier.Verify(untrustedToken); err != oidc.ErrIssuerNotFound {
+		t.Errorf("Expected %v, got %v", oidc.ErrIssuerNotFound, err)
+	}
+}
+
+func TestVerifierCachesKeys(t *testing.T) {
+	issuer := newStubIssuer(t)
+	issuer.addKey(t, "key-1")
+	verifier := newTestVerifier(t, issuer, 0)
+
+	for i := 0; i < 3; i++ {
+		if _, err := verifier.Verify(issuer.sign(t, "key-1", issuer.newClaims())); err != nil {
+			t.Fatalf("Error verify token: %v", err)
+		}
+	}
+	if calls := issuer.jwksCalls.Load(); calls != 1 {
+		t.Errorf("Expected keys fetched once, got %v", calls)
+	}
+
+	// an unknown key does not fetch the keys again right away
+	issuer.addKey(t, "key-2")
+	if _, err := verifier.Verify(issuer.sign(t, "key-2", issuer.newClaims())); err == nil {
+		t.Errorf("Expected token of a key not fetched yet to be invalid")
+	}
+	if calls := issuer.jwksCalls.Load(); calls != 1 {
+		t.Errorf("Expected keys fetched once, got %v", calls)
+	}
+}
+
+func TestVerifierKeyRotation(t *testing.T) {
+	issuer := newStubIssuer(t)
+	issuer.addKey(t, "key-1")
+	verifier := newTestVerifier(t, issuer, time.Millisecond*50)
+
+	if _, err := verifier.Verify(issuer.sign(t, "key-1", issuer.newClaims())); err != nil {
+		t.Fatalf("Error verify token: %v", err)
+	}
+
+	issuer.addKey(t, "key-2")
+	time.Sleep(time.Millisecond * 100)
+	if _, err := verifier.Verify(issuer.sign(t, "key-2", issuer.newClaims())); err != nil {
+		t.Errorf("Expected token of the rotated key to be valid, got %v", err)
+	}
+
+	// the cached keys keep working while the issuer is down
+	issuer.isFailing.Store(true)
+	time.Sleep(time.Millisecond * 100)
+	if _, err := verifier.Verify(issuer.sign(t, "key-1", issuer.newClaims())); err != nil {
+		t.Errorf("Expected token of a cached key to be valid, got %v", err)
+	}
+	if calls := issuer.jwksCalls.Load(); calls != 3 {
+		t.Errorf("Expected keys fetched 3 times, got %v", calls)
+	}
+}
+
+func TestNewVerifierInvalidIssuer(t *testing.T) {
+	tests := []struct {
+		name     string
+		issuers  []*oidc.Issuer
+		expected error
+	}{
+		{
+			name:     "no audience",
+			issuers:  []*oidc.Issuer{{Name: "corp", URL: "https://idp.example.com"}},
+			expected: oidc.ErrIssuerInvalid,
+		},
+		{
+			name:     "http url",
+			issuers:  []*oidc.Issuer{{Name: "corp", URL: "http://idp.example.com", Audience: "gotaro"}},
+			expected: oidc.ErrIssuerInsecure,
+		},
+		{
+			name: "duplicate name",
+			issuers: []*oidc.Issuer{
+				{Name: "corp", URL: "https://idp.example.com", Audience: "gotaro"},
+				{Name: "corp", URL: "https://other-idp.example.com", Audience: "gotaro"},
+			},
+			expected: oidc.ErrIssuerDuplicate,
+		},
+		{
+			name: "duplicate url",
+			issuers: []*oidc.Issuer{
+				{Name: "corp", URL: "https://idp.example.com", Audience: "gotaro"},
+				{Name: "partner", URL: "https://idp.example.com", Audience: "gotaro"},
+			},
+			expected: oidc.ErrIssuerDuplicate,
+		},
+	}
+	for _, test := range tests {
+		if _, err := oidc.NewVerifier(test.issuers, 0); err != test.expected {
+			t.Errorf("%v: Expected %v, got %v", test.name, test.expected, err)
+		}
+	}
+
+	var disabledVerifier *oidc.Verifier
+	if disabledVerifier.IsTrusted("token") {
+		t.Errorf("Expected no token trusted without issuers")
+	}
+}
+
+func TestVerifierGetLimits(t *testing.T) {
+	limits := &oidc.Limits{UploadRequestsPerMinute: 10, UploadBytesPerDay: 1024}
+	verifier, err := oidc.NewVerifier([]*oidc.Issuer{
+		{Name: "corp", URL: "https://idp.example.com", Audience: "gotaro", Limits: limits},
+		{Name: "partner", URL: "https://partner.example.com", Audience: "gotaro"},
+	}, 0)
+	if err != nil {
+		t.Fatalf("Error create verifier: %v", err)
+	}
+
+	tests := map[string]*oidc.Limits{
+		"corp:billing-service":                 limits,
+		"partner:billing-service":              nil,
+		"other:billing-service":                nil,
+		"0b6f5c1e-7d0a-4a4e-9a57-8f0b8f3c2d11": nil,
+	}
+	for clientID, expected := range tests {
+		if got := verifier.GetLimits(clientID); got != expected {
+			t.Errorf("%v: Expected %v, got %v", clientID, expected, got)
+		}
+	}
+}
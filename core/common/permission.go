@@ -0,0 +1,76 @@
+package common
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// Permissions are the permission grants of an api client, collected from its roles.
+type Permissions []string
+
+// BuiltinRolePermissions are the grants of the roles every gotaro instance has, they can not be
+// changed through the roles API.
+var BuiltinRolePermissions = map[string]Permissions{
+	APIClientSuperAdminScope: {PermissionAll},
+	APIClientUploaderScope:   {PermissionMediaUpload, PermissionMediaRead},
+}
+
+var permissionList = []string{
+	PermissionMediaUpload,
+	PermissionMediaRead,
+	PermissionMediaDelete,
+	PermissionRuleManage,
+	PermissionDriverManage,
+	PermissionAuditRead,
+}
+
+// SplitPermissionGrant returns the permission of a grant and its rule slug glob, empty when the
+// grant applies to every rule.
+func SplitPermissionGrant(grant string) (string, string) {
+	parts := strings.SplitN(grant, ":", 3)
+	if len(parts) < 3 {
+		return grant, ""
+	}
+	return parts[0] + ":" + parts[1], parts[2]
+}
+
+// IsPermissionGrantValid checks a grant names a known permission and, when restricted, a valid glob.
+func IsPermissionGrantValid(grant string) bool {
+	if grant == PermissionAll {
+		return true
+	}
+	permission, rulePattern := SplitPermissionGrant(grant)
+	if !slices.Contains(permissionList, permission) {
+		return false
+	}
+	if strings.HasSuffix(grant, ":") {
+		return false
+	}
+	_, err := path.Match(rulePattern, "")
+	return err == nil
+}
+
+// Allows checks a permission is granted on a rule. A grant restricted to some rules never allows
+// a request not bound to a rule, ruleSlug is empty for those.
+func (p Permissions) Allows(permission string, ruleSlug string) bool {
+	for _, grant := range p {
+		if grant == PermissionAll {
+			return true
+		}
+		grantedPermission, rulePattern := SplitPermissionGrant(grant)
+		if grantedPermission != permission {
+			continue
+		}
+		if rulePattern == "" {
+			return true
+		}
+		if ruleSlug == "" {
+			continue
+		}
+		if isMatch, _ := path.Match(rulePattern, ruleSlug); isMatch {
+			return true
+		}
+	}
+	return false
+}
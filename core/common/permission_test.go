@@ -0,0 +1,57 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/sibeur/gotaro/core/common"
+)
+
+func TestPermissionsAllows(t *testing.T) {
+	permissions := common.Permissions{"media:upload:partner-*", "media:read:partner-a", common.PermissionRuleManage}
+
+	tests := []struct {
+		permission string
+		ruleSlug   string
+		isAllowed  bool
+	}{
+		{common.PermissionMediaUpload, "partner-a", true},
+		{common.PermissionMediaUpload, "partner-b", true},
+		{common.PermissionMediaUpload, "avatar", false},
+		{common.PermissionMediaUpload, "", false},
+		{common.PermissionMediaRead, "partner-a", true},
+		{common.PermissionMediaRead, "partner-b", false},
+		{common.PermissionMediaDelete, "partner-a", false},
+		{common.PermissionRuleManage, "", true},
+		{common.PermissionRuleManage, "avatar", true},
+		{common.PermissionDriverManage, "", false},
+	}
+	for _, test := range tests {
+		if isAllowed := permissions.Allows(test.permission, test.ruleSlug); isAllowed != test.isAllowed {
+			t.Errorf("Expected %v on %q to be %v, got %v", test.permission, test.ruleSlug, test.isAllowed, isAllowed)
+		}
+	}
+
+	superAdmin := common.BuiltinRolePermissions[common.APIClientSuperAdminScope]
+	if !superAdmin.Allows(common.PermissionDriverManage, "") {
+		t.Errorf("Expected super admin to be allowed everything")
+	}
+}
+
+func TestIsPermissionGrantValid(t *testing.T) {
+	tests := map[string]bool{
+		"*":                      true,
+		"media:upload":           true,
+		"media:upload:partner-*": true,
+		"rule:manage:[a-c]*":     true,
+		"media:upload:":          false,
+		"media:upload:[":         false,
+		"media:write":            false,
+		"media":                  false,
+		"":                       false,
+	}
+	for grant, isValid := range tests {
+		if common.IsPermissionGrantValid(grant) != isValid {
+			t.Errorf("Expected %q validity to be %v", grant, isValid)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package secret
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+)
+
+// DataCipherOverhead is the number of bytes EncryptData adds to the plaintext, the GCM tag. EncryptStream
+// adds it to every chunk.
+const DataCipherOverhead = 16
+
+// DataChunkSize is the size of the plaintext chunks EncryptStream seals one by one, so a file is never
+// held whole in memory.
+const DataChunkSize = 64 * 1024
+
+// NewDataKey returns a random AES-256 key and GCM nonce to encrypt one object. The key has to be
+// wrapped with Keyring.WrapKey before being stored.
+func NewDataKey() ([]byte, []byte, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, err
+	}
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return dataKey, nonce, nil
+}
+
+// EncryptData encrypts plaintext with AES-256-GCM. A data key and nonce pair must not be reused.
+func EncryptData(dataKey, nonce, plaintext []byte) ([]byte, error) {
+	gcm, err := newDataGCM(dataKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nil, nonce, plaintext, nil), nil
+}
+
+// DecryptData decrypts and authenticates data encrypted by EncryptData.
+func DecryptData(dataKey, nonce, ciphertext []byte) ([]byte, error) {
+	gcm, err := newDataGCM(dataKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+// EncryptStream encrypts src into dst with AES-256-GCM, chunkSize bytes at a time. Every chunk is
+// sealed with the nonce xored with its index and marked when it is the last one, so chunks can be
+// neither reordered nor dropped. A data key and nonce pair must not be reused.
+func EncryptStream(dataKey, nonce []byte, chunkSize int, dst io.Writer, src io.Reader) error {
+	gcm, err := newDataGCM(dataKey, nonce)
+	if err != nil {
+		return err
+	}
+	if chunkSize <= 0 {
+		return ErrCiphertextInvalid
+	}
+	return transformChunks(src, chunkSize, func(index uint64, chunk []byte, isLast bool) error {
+		_, err := dst.Write(gcm.Seal(nil, getChunkNonce(nonce, index), chunk, getChunkData(isLast)))
+		return err
+	})
+}
+
+// DecryptStream decrypts and authenticates src encrypted by EncryptStream into dst. A chunk size of
+// zero reads data encrypted whole by EncryptData, held in memory.
+func DecryptStream(dataKey, nonce []byte, chunkSize int, dst io.Writer, src io.Reader) error {
+	if chunkSize <= 0 {
+		ciphertext, err := io.ReadAll(src)
+		if err != nil {
+			return err
+		}
+		plaintext, err := DecryptData(dataKey, nonce, ciphertext)
+		if err != nil {
+			return err
+		}
+		_, err = dst.Write(plaintext)
+		return err
+	}
+
+	gcm, err := newDataGCM(dataKey, nonce)
+	if err != nil {
+		return err
+	}
+	return transformChunks(src, chunkSize+DataCipherOverhead, func(index uint64, chunk []byte, isLast bool) error {
+		plaintext, err := gcm.Open(nil, getChunkNonce(nonce, index), chunk, getChunkData(isLast))
+		if err != nil {
+			return err
+		}
+		_, err = dst.Write(plaintext)
+		return err
+	})
+}
+
+// GetEncryptedSize returns the size of size bytes once encrypted by EncryptStream, or by EncryptData
+// for a chunk size of zero.
+func GetEncryptedSize(size uint64, chunkSize int) uint64 {
+	if chunkSize <= 0 {
+		return size + DataCipherOverhead
+	}
+	chunks := (size + uint64(chunkSize) - 1) / uint64(chunkSize)
+	if chunks == 0 {
+		chunks = 1
+	}
+	return size + chunks*DataCipherOverhead
+}
+
+// GetPlaintextSize returns the size of data encrypted by EncryptStream, or by EncryptData for a chunk
+// size of zero. It fails when the encrypted size can not be produced by them, e.g. a cut object.
+func GetPlaintextSize(encryptedSize uint64, chunkSize int) (uint64, error) {
+	if encryptedSize < DataCipherOverhead {
+		return 0, ErrCiphertextInvalid
+	}
+	if chunkSize <= 0 {
+		return encryptedSize - DataCipherOverhead, nil
+	}
+	encryptedChunkSize := uint64(chunkSize) + DataCipherOverhead
+	chunks := (encryptedSize + encryptedChunkSize - 1) / encryptedChunkSize
+	lastChunkSize := encryptedSize - (chunks-1)*encryptedChunkSize
+	if lastChunkSize < DataCipherOverhead || (lastChunkSize == DataCipherOverhead && chunks > 1) {
+		return 0, ErrCiphertextInvalid
+	}
+	return encryptedSize - chunks*DataCipherOverhead, nil
+}
+
+// transformChunks calls transform with every chunkSize bytes of src, telling which chunk is the last
+// one. An empty src is one empty last chunk.
+func transformChunks(src io.Reader, chunkSize int, transform func(index uint64, chunk []byte, isLast bool) error) error {
+	reader := bufio.NewReader(src)
+	chunk := make([]byte, chunkSize)
+	for index := uint64(0); ; index++ {
+		n, err := io.ReadFull(reader, chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		isLast := err != nil
+		if !isLast {
+			if _, err := reader.Peek(1); err == io.EOF {
+				isLast = true
+			} else if err != nil {
+				return err
+			}
+		}
+		if err := transform(index, chunk[:n], isLast); err != nil {
+			return err
+		}
+		if isLast {
+			return nil
+		}
+	}
+}
+
+func newDataGCM(dataKey, nonce []byte) (cipher.AEAD, error) {
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, ErrCiphertextInvalid
+	}
+	return gcm, nil
+}
+
+func getChunkNonce(nonce []byte, index uint64) []byte {
+	chunkNonce := make([]byte, len(nonce))
+	copy(chunkNonce, nonce)
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, index)
+	for i := range counter {
+		chunkNonce[len(chunkNonce)-len(counter)+i] ^= counter[i]
+	}
+	return chunkNonce
+}
+
+func getChunkData(isLast bool) []byte {
+	if isLast {
+		return []byte{1}
+	}
+	return []byte{0}
+}
@@ -0,0 +1,235 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+)
+
+const (
+	// EncryptedPrefix marks a value produced by Keyring.Encrypt.
+	EncryptedPrefix = "gotaro:enc:v1:"
+	// MaskValue replaces secret values in API responses.
+	MaskValue = "********"
+
+	keySize = 32
+)
+
+var (
+	ErrKeyringDisabled   = errors.New("master key not configured")
+	ErrKeyringRequired   = errors.New("master key not configured, set GOTARO_MASTER_KEYS or ALLOW_PLAINTEXT_SECRETS=true to store secrets unencrypted")
+	ErrKeyInvalid        = errors.New("master key must be 32 bytes encoded in base64")
+	ErrKeyNotFound       = errors.New("master key not found")
+	ErrCiphertextInvalid = errors.New("encrypted value invalid")
+)
+
+// Keyring holds the master keys used for envelope encryption. Values are encrypted with a random
+// data key and the data key is wrapped by the active master key, so rotating the master key only
+// requires re-wrapping.
+type Keyring struct {
+	activeKeyID string
+	keys        map[string][]byte
+}
+
+func NewKeyring(activeKeyID string, keys map[string][]byte) (*Keyring, error) {
+	for _, key := range keys {
+		if len(key) != keySize {
+			return nil, ErrKeyInvalid
+		}
+	}
+	if len(keys) > 0 {
+		if _, isExist := keys[activeKeyID]; !isExist {
+			return nil, ErrKeyNotFound
+		}
+	}
+	return &Keyring{activeKeyID: activeKeyID, keys: keys}, nil
+}
+
+// NewKeyringFromEnv loads the master keys from GOTARO_MASTER_KEYS or GOTARO_MASTER_KEYS_FILE,
+// both as "key-id=base64-key" pairs separated by commas or new lines. GOTARO_MASTER_KEY_ID selects
+// the key used for new values and defaults to the last one listed. Without any master key the secrets
+// are stored unencrypted, which has to be allowed explicitly with ALLOW_PLAINTEXT_SECRETS=true.
+func NewKeyringFromEnv() (*Keyring, error) {
+	rawKeys := os.Getenv("GOTARO_MASTER_KEYS")
+	if keysFile := os.Getenv("GOTARO_MASTER_KEYS_FILE"); keysFile != "" {
+		fileContent, err := os.ReadFile(keysFile)
+		if err != nil {
+			return nil, err
+		}
+		rawKeys = string(fileContent)
+	}
+
+	keys := make(map[string][]byte)
+	activeKeyID := ""
+	for _, rawKey := range strings.FieldsFunc(rawKeys, func(r rune) bool { return r == ',' || r == '\n' }) {
+		rawKey = strings.TrimSpace(rawKey)
+		if rawKey == "" {
+			continue
+		}
+		keyID, encodedKey, isFound := strings.Cut(rawKey, "=")
+		if !isFound || keyID == "" {
+			return nil, ErrKeyInvalid
+		}
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, ErrKeyInvalid
+		}
+		keys[keyID] = key
+		activeKeyID = keyID
+	}
+
+	if len(keys) == 0 && os.Getenv("ALLOW_PLAINTEXT_SECRETS") != "true" {
+		return nil, ErrKeyringRequired
+	}
+
+	if os.Getenv("GOTARO_MASTER_KEY_ID") != "" {
+		activeKeyID = os.Getenv("GOTARO_MASTER_KEY_ID")
+	}
+	return NewKeyring(activeKeyID, keys)
+}
+
+func (k *Keyring) IsEnabled() bool {
+	return k != nil && len(k.keys) > 0
+}
+
+func (k *Keyring) GetActiveKeyID() string {
+	return k.activeKeyID
+}
+
+// Encrypt returns the envelope encrypted form of plaintext.
+func (k *Keyring) Encrypt(plaintext []byte) (string, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	keyID, wrappedKey, err := k.WrapKey(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := seal(dataKey, plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	return EncryptedPrefix + keyID + ":" + encode(wrappedKey) + ":" + encode(ciphertext), nil
+}
+
+func (k *Keyring) Decrypt(value string) ([]byte, error) {
+	if !IsEncrypted(value) {
+		return nil, ErrCiphertextInvalid
+	}
+	parts := strings.Split(strings.TrimPrefix(value, EncryptedPrefix), ":")
+	if len(parts) != 3 {
+		return nil, ErrCiphertextInvalid
+	}
+
+	wrappedKey, err := decode(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := decode(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := k.UnwrapKey(parts[0], wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(dataKey, ciphertext)
+}
+
+// Reencrypt encrypts value again with the active master key. Plain values are encrypted as is.
+func (k *Keyring) Reencrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return k.Encrypt([]byte(value))
+	}
+	plaintext, err := k.Decrypt(value)
+	if err != nil {
+		return "", err
+	}
+	return k.Encrypt(plaintext)
+}
+
+// NeedsRotation reports whether value is plain or wrapped by a key other than the active one.
+func (k *Keyring) NeedsRotation(value string) bool {
+	if !IsEncrypted(value) {
+		return true
+	}
+	keyID, _, _ := strings.Cut(strings.TrimPrefix(value, EncryptedPrefix), ":")
+	return keyID != k.activeKeyID
+}
+
+// WrapKey encrypts a data key with the active master key.
+func (k *Keyring) WrapKey(dataKey []byte) (string, []byte, error) {
+	if !k.IsEnabled() {
+		return "", nil, ErrKeyringDisabled
+	}
+	wrappedKey, err := seal(k.keys[k.activeKeyID], dataKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return k.activeKeyID, wrappedKey, nil
+}
+
+// UnwrapKey decrypts a data key wrapped by the master key keyID.
+func (k *Keyring) UnwrapKey(keyID string, wrappedKey []byte) ([]byte, error) {
+	if !k.IsEnabled() {
+		return nil, ErrKeyringDisabled
+	}
+	masterKey, isExist := k.keys[keyID]
+	if !isExist {
+		return nil, ErrKeyNotFound
+	}
+	return open(masterKey, wrappedKey)
+}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, EncryptedPrefix)
+}
+
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrCiphertextInvalid
+	}
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decode(data string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(data)
+}
@@ -0,0 +1,213 @@
+package secret_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/sibeur/gotaro/core/common/secret"
+)
+
+func newTestKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestKeyringEncryptDecrypt(t *testing.T) {
+	keyring, err := secret.NewKeyring("v1", map[string][]byte{"v1": newTestKey(1)})
+	if err != nil {
+		t.Fatalf("NewKeyring() returned an error: %v", err)
+	}
+
+	encrypted, err := keyring.Encrypt([]byte("service-account"))
+	if err != nil {
+		t.Fatalf("Encrypt() returned an error: %v", err)
+	}
+
+	if !secret.IsEncrypted(encrypted) {
+		t.Errorf("Expected encrypted value to have prefix %v, got %v", secret.EncryptedPrefix, encrypted)
+	}
+
+	decrypted, err := keyring.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decrypt() returned an error: %v", err)
+	}
+
+	if string(decrypted) != "service-account" {
+		t.Errorf("Expected %v, got %v", "service-account", string(decrypted))
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKeyring, _ := secret.NewKeyring("v1", map[string][]byte{"v1": newTestKey(1)})
+	encrypted, _ := oldKeyring.Encrypt([]byte("service-account"))
+
+	keyring, err := secret.NewKeyring("v2", map[string][]byte{"v1": newTestKey(1), "v2": newTestKey(2)})
+	if err != nil {
+		t.Fatalf("NewKeyring() returned an error: %v", err)
+	}
+
+	if !keyring.NeedsRotation(encrypted) {
+		t.Error("Expected value wrapped by v1 to need rotation")
+	}
+
+	rotated, err := keyring.Reencrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Reencrypt() returned an error: %v", err)
+	}
+
+	if keyring.NeedsRotation(rotated) {
+		t.Error("Expected rotated value not to need rotation")
+	}
+
+	if _, err := oldKeyring.Decrypt(rotated); err == nil {
+		t.Error("Expected old keyring to fail decrypting rotated value")
+	}
+
+	decrypted, err := keyring.Decrypt(rotated)
+	if err != nil || string(decrypted) != "service-account" {
+		t.Errorf("Expected rotated value to decrypt, got %v, %v", string(decrypted), err)
+	}
+}
+
+func TestKeyringDecryptTampered(t *testing.T) {
+	keyring, _ := secret.NewKeyring("v1", map[string][]byte{"v1": newTestKey(1)})
+	encrypted, _ := keyring.Encrypt([]byte("service-account"))
+
+	tampered := encrypted[:len(encrypted)-2] + "AA"
+	if _, err := keyring.Decrypt(tampered); err == nil {
+		t.Error("Expected tampered value to fail decrypting")
+	}
+}
+
+func TestNewKeyringFromEnv(t *testing.T) {
+	os.Setenv("GOTARO_MASTER_KEYS", "v1="+base64.StdEncoding.EncodeToString(newTestKey(1))+",v2="+base64.StdEncoding.EncodeToString(newTestKey(2)))
+	defer os.Unsetenv("GOTARO_MASTER_KEYS")
+
+	keyring, err := secret.NewKeyringFromEnv()
+	if err != nil {
+		t.Fatalf("NewKeyringFromEnv() returned an error: %v", err)
+	}
+
+	if keyring.GetActiveKeyID() != "v2" {
+		t.Errorf("Expected active key %v, got %v", "v2", keyring.GetActiveKeyID())
+	}
+
+	os.Setenv("GOTARO_MASTER_KEYS", "v1=c2hvcnQ=")
+	if _, err := secret.NewKeyringFromEnv(); err == nil {
+		t.Error("Expected short key to be rejected")
+	}
+}
+
+func TestNewKeyringFromEnvWithoutKey(t *testing.T) {
+	os.Unsetenv("GOTARO_MASTER_KEYS")
+	if _, err := secret.NewKeyringFromEnv(); err != secret.ErrKeyringRequired {
+		t.Errorf("Expected %v, got %v", secret.ErrKeyringRequired, err)
+	}
+
+	os.Setenv("ALLOW_PLAINTEXT_SECRETS", "true")
+	defer os.Unsetenv("ALLOW_PLAINTEXT_SECRETS")
+	keyring, err := secret.NewKeyringFromEnv()
+	if err != nil {
+		t.Fatalf("NewKeyringFromEnv() returned an error: %v", err)
+	}
+	if keyring.IsEnabled() {
+		t.Error("Expected keyring without key to be disabled")
+	}
+}
+
+func TestEncryptDecryptData(t *testing.T) {
+	dataKey, nonce, err := secret.NewDataKey()
+	if err != nil {
+		t.Fatalf("NewDataKey() returned an error: %v", err)
+	}
+
+	ciphertext, err := secret.EncryptData(dataKey, nonce, []byte("attachment"))
+	if err != nil {
+		t.Fatalf("EncryptData() returned an error: %v", err)
+	}
+
+	if len(ciphertext) != len("attachment")+secret.DataCipherOverhead {
+		t.Errorf("Expected ciphertext of %v bytes, got %v", len("attachment")+secret.DataCipherOverhead, len(ciphertext))
+	}
+
+	plaintext, err := secret.DecryptData(dataKey, nonce, ciphertext)
+	if err != nil || string(plaintext) != "attachment" {
+		t.Errorf("Expected ciphertext to decrypt, got %v, %v", string(plaintext), err)
+	}
+
+	ciphertext[0] ^= 1
+	if _, err := secret.DecryptData(dataKey, nonce, ciphertext); err == nil {
+		t.Error("Expected tampered ciphertext to fail decrypting")
+	}
+}
+
+func TestEncryptDecryptStream(t *testing.T) {
+	dataKey, nonce, _ := secret.NewDataKey()
+
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "empty", size: 0},
+		{name: "one partial chunk", size: 10},
+		{name: "one full chunk", size: 16},
+		{name: "chunks and a partial one", size: 50},
+		{name: "full chunks", size: 48},
+	}
+	for _, test := range tests {
+		plaintext := bytes.Repeat([]byte("a"), test.size)
+		var ciphertext bytes.Buffer
+		if err := secret.EncryptStream(dataKey, nonce, 16, &ciphertext, bytes.NewReader(plaintext)); err != nil {
+			t.Fatalf("%v: EncryptStream() returned an error: %v", test.name, err)
+		}
+		if size := secret.GetEncryptedSize(uint64(test.size), 16); uint64(ciphertext.Len()) != size {
+			t.Errorf("%v: Expected ciphertext of %v bytes, got %v", test.name, size, ciphertext.Len())
+		}
+		if size, err := secret.GetPlaintextSize(uint64(ciphertext.Len()), 16); err != nil || size != uint64(test.size) {
+			t.Errorf("%v: Expected plaintext of %v bytes, got %v, %v", test.name, test.size, size, err)
+		}
+
+		var decrypted bytes.Buffer
+		if err := secret.DecryptStream(dataKey, nonce, 16, &decrypted, bytes.NewReader(ciphertext.Bytes())); err != nil {
+			t.Fatalf("%v: DecryptStream() returned an error: %v", test.name, err)
+		}
+		if !bytes.Equal(decrypted.Bytes(), plaintext) {
+			t.Errorf("%v: Expected %v, got %v", test.name, plaintext, decrypted.Bytes())
+		}
+	}
+}
+
+func TestDecryptStreamTampered(t *testing.T) {
+	dataKey, nonce, _ := secret.NewDataKey()
+	var ciphertext bytes.Buffer
+	if err := secret.EncryptStream(dataKey, nonce, 16, &ciphertext, bytes.NewReader(bytes.Repeat([]byte("a"), 40))); err != nil {
+		t.Fatalf("EncryptStream() returned an error: %v", err)
+	}
+	encrypted := ciphertext.Bytes()
+	chunkSize := 16 + secret.DataCipherOverhead
+
+	flipped := bytes.Clone(encrypted)
+	flipped[0] ^= 1
+	reordered := append(append(bytes.Clone(encrypted[chunkSize:2*chunkSize]), encrypted[:chunkSize]...), encrypted[2*chunkSize:]...)
+
+	tests := map[string][]byte{
+		"flipped":   flipped,
+		"reordered": reordered,
+		"truncated": encrypted[:2*chunkSize],
+		"empty":     {},
+	}
+	for name, ciphertext := range tests {
+		if err := secret.DecryptStream(dataKey, nonce, 16, io.Discard, bytes.NewReader(ciphertext)); err == nil {
+			t.Errorf("Expected %v ciphertext to fail decrypting", name)
+		}
+	}
+
+	// data encrypted whole before chunking still decrypts
+	legacy, _ := secret.EncryptData(dataKey, nonce, []byte("attachment"))
+	var decrypted bytes.Buffer
+	if err := secret.DecryptStream(dataKey, nonce, 0, &decrypted, bytes.NewReader(legacy)); err != nil || decrypted.String() != "attachment" {
+		t.Errorf("Expected legacy ciphertext to decrypt, got %v, %v", decrypted.String(), err)
+	}
+}
@@ -0,0 +1,238 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sibeur/gotaro/core/common"
+)
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmHS256 = "HS256"
+)
+
+var (
+	ErrKeySetDisabled   = errors.New("no jwt signing key nor secret configured")
+	ErrKeyInvalid       = errors.New("jwt signing key must be a PEM encoded RSA, P-256 or Ed25519 key")
+	ErrKeyNotFound      = errors.New("jwt signing key not found")
+	ErrKeyNotSigningKey = errors.New("jwt signing key has no private key")
+)
+
+// SigningKey is an asymmetric key verifying tokens, and signing them when its private key is known.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	privateKey crypto.Signer
+	publicKey  crypto.PublicKey
+}
+
+// KeySet holds the keys signing and verifying the gotaro tokens. Tokens are signed by the active
+// key and carry its id in the kid header. The other keys still verify the tokens they signed, so
+// a key can be rotated by adding a new active key and removing the old one once its tokens expired.
+// Without asymmetric keys tokens are signed with HS256 and the shared secret.
+type KeySet struct {
+	activeKeyID string
+	keys        map[string]*SigningKey
+	hmacSecret  []byte
+}
+
+func NewKeySet(activeKeyID string, keys []*SigningKey, hmacSecret []byte) (*KeySet, error) {
+	keySet := &KeySet{activeKeyID: activeKeyID, keys: make(map[string]*SigningKey), hmacSecret: hmacSecret}
+	for _, key := range keys {
+		keySet.keys[key.ID] = key
+	}
+	if len(keys) > 0 {
+		activeKey, isExist := keySet.keys[activeKeyID]
+		if !isExist {
+			return nil, ErrKeyNotFound
+		}
+		if activeKey.privateKey == nil {
+			return nil, ErrKeyNotSigningKey
+		}
+	}
+	return keySet, nil
+}
+
+// NewKeySetFromEnv loads the keys listed in JWT_SIGNING_KEYS as "key-id=path-to-pem" pairs
+// separated by commas. JWT_SIGNING_KEY_ID selects the key signing new tokens and defaults to the
+// last one listed, the others may be public keys only. JWT_SECRET keeps verifying HS256 tokens.
+func NewKeySetFromEnv() (*KeySet, error) {
+	keys := make([]*SigningKey, 0)
+	activeKeyID := ""
+	for _, rawKey := range strings.Split(os.Getenv("JWT_SIGNING_KEYS"), ",") {
+		rawKey = strings.TrimSpace(rawKey)
+		if rawKey == "" {
+			continue
+		}
+		keyID, keyFile, isFound := strings.Cut(rawKey, "=")
+		if !isFound || keyID == "" {
+			return nil, ErrKeyInvalid
+		}
+		pemData, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseSigningKey(keyID, pemData)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		activeKeyID = keyID
+	}
+
+	if os.Getenv("JWT_SIGNING_KEY_ID") != "" {
+		activeKeyID = os.Getenv("JWT_SIGNING_KEY_ID")
+	}
+	return NewKeySet(activeKeyID, keys, []byte(os.Getenv("JWT_SECRET")))
+}
+
+// ParseSigningKey reads a PKCS#8, PKCS#1 or SEC 1 private key, or a PKIX public key, from PEM data.
+// The algorithm follows the key type.
+func ParseSigningKey(keyID string, pemData []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, ErrKeyInvalid
+	}
+
+	var parsedKey any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsedKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsedKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsedKey, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsedKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, ErrKeyInvalid
+	}
+	if err != nil {
+		return nil, ErrKeyInvalid
+	}
+
+	key := &SigningKey{ID: keyID}
+	if signer, isSigner := parsedKey.(crypto.Signer); isSigner {
+		key.privateKey = signer
+		key.publicKey = signer.Public()
+	} else {
+		key.publicKey = parsedKey
+	}
+
+	switch publicKey := key.publicKey.(type) {
+	case *rsa.PublicKey:
+		key.Algorithm = AlgorithmRS256
+	case *ecdsa.PublicKey:
+		if publicKey.Curve != elliptic.P256() {
+			return nil, ErrKeyInvalid
+		}
+		key.Algorithm = AlgorithmES256
+	case ed25519.PublicKey:
+		key.Algorithm = AlgorithmEdDSA
+	default:
+		return nil, ErrKeyInvalid
+	}
+	return key, nil
+}
+
+func (k *KeySet) IsEnabled() bool {
+	return k != nil && (len(k.keys) > 0 || len(k.hmacSecret) > 0)
+}
+
+// Sign signs claims with the active key, or with the shared secret when there is no asymmetric key.
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if !k.IsEnabled() {
+		return "", ErrKeySetDisabled
+	}
+
+	if len(k.keys) == 0 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.hmacSecret)
+	}
+
+	activeKey := k.keys[k.activeKeyID]
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(activeKey.Algorithm), claims)
+	token.Header["kid"] = activeKey.ID
+	return token.SignedString(activeKey.privateKey)
+}
+
+// Parse verifies a token with the key named by its kid header, or with the shared secret for the
+// HS256 tokens without kid.
+func (k *KeySet) Parse(tokenString string) (*jwt.Token, error) {
+	if !k.IsEnabled() {
+		return nil, ErrKeySetDisabled
+	}
+
+	return jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
+		keyID, _ := token.Header["kid"].(string)
+		if keyID == "" {
+			if len(k.hmacSecret) == 0 || token.Method.Alg() != AlgorithmHS256 {
+				return nil, ErrKeyNotFound
+			}
+			return k.hmacSecret, nil
+		}
+
+		key, isExist := k.keys[keyID]
+		if !isExist || token.Method.Alg() != key.Algorithm {
+			return nil, ErrKeyNotFound
+		}
+		return key.publicKey, nil
+	}, jwt.WithValidMethods([]string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA, AlgorithmHS256}))
+}
+
+// GetJWKS returns the public keys as a JSON Web Key Set, letting other services verify the tokens
+// offline. The shared secret is never published.
+func (k *KeySet) GetJWKS() common.GotaroMap {
+	jwks := make([]common.GotaroMap, 0)
+	if k != nil {
+		for _, key := range k.keys {
+			jwks = append(jwks, key.ToJWK())
+		}
+	}
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i]["kid"].(string) < jwks[j]["kid"].(string) })
+	return common.GotaroMap{"keys": jwks}
+}
+
+// ToJWK describes the public key as a JSON Web Key.
+func (key *SigningKey) ToJWK() common.GotaroMap {
+	jwk := common.GotaroMap{
+		"kid": key.ID,
+		"alg": key.Algorithm,
+		"use": "sig",
+	}
+	switch publicKey := key.publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk["kty"] = "RSA"
+		jwk["n"] = encode(publicKey.N.Bytes())
+		jwk["e"] = encode(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		jwk["kty"] = "EC"
+		jwk["crv"] = "P-256"
+		jwk["x"] = encode(publicKey.X.FillBytes(make([]byte, 32)))
+		jwk["y"] = encode(publicKey.Y.FillBytes(make([]byte, 32)))
+	case ed25519.PublicKey:
+		jwk["kty"] = "OKP"
+		jwk["crv"] = "Ed25519"
+		jwk["x"] = encode(publicKey)
+	}
+	return jwk
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,166 @@
+package signing_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/common/signing"
+)
+
+func newTestSigningKey(t *testing.T, keyID string, privateKey crypto.Signer) *signing.SigningKey {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("Error marshal key: %v", err)
+	}
+	key, err := signing.ParseSigningKey(keyID, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("Error parse key: %v", err)
+	}
+	return key
+}
+
+func newTestClaims() jwt.Claims {
+	return jwt.RegisteredClaims{Subject: "client", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+}
+
+func TestKeySetSignParse(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		key       *signing.SigningKey
+		algorithm string
+	}{
+		{newTestSigningKey(t, "rsa", rsaKey), signing.AlgorithmRS256},
+		{newTestSigningKey(t, "ec", ecKey), signing.AlgorithmES256},
+		{newTestSigningKey(t, "ed", edKey), signing.AlgorithmEdDSA},
+	}
+	for _, test := range tests {
+		if test.key.Algorithm != test.algorithm {
+			t.Errorf("Expected algorithm %v, got %v", test.algorithm, test.key.Algorithm)
+		}
+
+		keySet, err := signing.NewKeySet(test.key.ID, []*signing.SigningKey{test.key}, nil)
+		if err != nil {
+			t.Fatalf("Error create key set: %v", err)
+		}
+		tokenString, err := keySet.Sign(newTestClaims())
+		if err != nil {
+			t.Fatalf("Error sign %v: %v", test.algorithm, err)
+		}
+		token, err := keySet.Parse(tokenString)
+		if err != nil {
+			t.Fatalf("Error parse %v: %v", test.algorithm, err)
+		}
+		if token.Header["kid"] != test.key.ID || token.Method.Alg() != test.algorithm {
+			t.Errorf("Expected %v signed by %v, got %v", test.algorithm, test.key.ID, token.Header)
+		}
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	keys := []*signing.SigningKey{newTestSigningKey(t, "old", oldKey), newTestSigningKey(t, "new", newKey)}
+
+	oldKeySet, _ := signing.NewKeySet("old", keys[:1], nil)
+	oldToken, _ := oldKeySet.Sign(newTestClaims())
+
+	keySet, err := signing.NewKeySet("new", keys, nil)
+	if err != nil {
+		t.Fatalf("Error create key set: %v", err)
+	}
+	if _, err := keySet.Parse(oldToken); err != nil {
+		t.Errorf("Expected token of the old key to be valid, got %v", err)
+	}
+
+	newKeySet, _ := signing.NewKeySet("new", keys[1:], nil)
+	if _, err := newKeySet.Parse(oldToken); err == nil {
+		t.Errorf("Expected token of a removed key to be invalid")
+	}
+}
+
+func TestKeySetHMAC(t *testing.T) {
+	hmacKeySet, _ := signing.NewKeySet("", nil, []byte("secret"))
+	hmacToken, err := hmacKeySet.Sign(newTestClaims())
+	if err != nil {
+		t.Fatalf("Error sign: %v", err)
+	}
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	keys := []*signing.SigningKey{newTestSigningKey(t, "rsa", rsaKey)}
+
+	keySet, _ := signing.NewKeySet("rsa", keys, []byte("secret"))
+	if _, err := keySet.Parse(hmacToken); err != nil {
+		t.Errorf("Expected token of the secret to be valid, got %v", err)
+	}
+
+	keySet, _ = signing.NewKeySet("rsa", keys, nil)
+	if _, err := keySet.Parse(hmacToken); err == nil {
+		t.Errorf("Expected token of the secret to be invalid once the secret is removed")
+	}
+
+	if _, err := signing.NewKeySet("", nil, nil); err != nil {
+		t.Fatalf("Error create key set: %v", err)
+	}
+	var disabledKeySet *signing.KeySet
+	if _, err := disabledKeySet.Sign(newTestClaims()); err != signing.ErrKeySetDisabled {
+		t.Errorf("Expected %v, got %v", signing.ErrKeySetDisabled, err)
+	}
+}
+
+func TestKeySetPublicKeyOnly(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(ecKey.Public())
+	key, err := signing.ParseSigningKey("ec", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("Error parse key: %v", err)
+	}
+
+	if _, err := signing.NewKeySet("ec", []*signing.SigningKey{key}, nil); err != signing.ErrKeyNotSigningKey {
+		t.Errorf("Expected %v, got %v", signing.ErrKeyNotSigningKey, err)
+	}
+	if _, err := signing.NewKeySet("unknown", []*signing.SigningKey{key}, nil); err != signing.ErrKeyNotFound {
+		t.Errorf("Expected %v, got %v", signing.ErrKeyNotFound, err)
+	}
+}
+
+func TestKeySetJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	keys := []*signing.SigningKey{newTestSigningKey(t, "rsa", rsaKey), newTestSigningKey(t, "ed", edKey)}
+
+	keySet, _ := signing.NewKeySet("ed", keys, []byte("secret"))
+	jwks := keySet.GetJWKS()["keys"].([]common.GotaroMap)
+	if len(jwks) != 2 {
+		t.Fatalf("Expected 2 keys, got %v", jwks)
+	}
+	for _, jwk := range jwks {
+		switch jwk["kid"] {
+		case "rsa":
+			if jwk["kty"] != "RSA" || jwk["alg"] != signing.AlgorithmRS256 || jwk["e"] != "AQAB" || jwk["n"] == "" {
+				t.Errorf("Unexpected RSA key %v", jwk)
+			}
+		case "ed":
+			if jwk["kty"] != "OKP" || jwk["crv"] != "Ed25519" || jwk["alg"] != signing.AlgorithmEdDSA || jwk["x"] == "" {
+				t.Errorf("Unexpected Ed25519 key %v", jwk)
+			}
+		default:
+			t.Errorf("Unexpected key %v", jwk)
+		}
+		if _, isExist := jwk["d"]; isExist {
+			t.Errorf("Expected no private part, got %v", jwk)
+		}
+	}
+}
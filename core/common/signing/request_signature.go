@@ -0,0 +1,77 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// RequestSignatureAlgorithm is the authorization scheme of the signed requests:
+//
+//	Authorization: GOTARO-HMAC-SHA256 Key=<api key>, Signature=<hex HMAC-SHA256>
+//
+// The signature covers the string returned by GetRequestStringToSign, so the signing secret itself
+// never travels with the request.
+const RequestSignatureAlgorithm = "GOTARO-HMAC-SHA256"
+
+// RequestAuthorization is the parsed authorization header of a signed request.
+type RequestAuthorization struct {
+	Key       string
+	Signature string
+}
+
+// ParseRequestAuthorization reads the key and signature of a signed request authorization header.
+func ParseRequestAuthorization(authorization string) (*RequestAuthorization, bool) {
+	params, isFound := strings.CutPrefix(authorization, RequestSignatureAlgorithm+" ")
+	if !isFound {
+		return nil, false
+	}
+
+	requestAuthorization := &RequestAuthorization{}
+	for _, param := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch name {
+		case "Key":
+			requestAuthorization.Key = value
+		case "Signature":
+			requestAuthorization.Signature = value
+		}
+	}
+	if requestAuthorization.Key == "" || requestAuthorization.Signature == "" {
+		return nil, false
+	}
+	return requestAuthorization, true
+}
+
+func FormatRequestAuthorization(key string, signature string) string {
+	return RequestSignatureAlgorithm + " Key=" + key + ", Signature=" + signature
+}
+
+// GetRequestStringToSign returns the canonical form of a request, its lines are the algorithm, the
+// upper case method, the path with its query as sent, the unix timestamp, the nonce and the hex
+// SHA-256 of the body.
+func GetRequestStringToSign(method, path, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		RequestSignatureAlgorithm,
+		strings.ToUpper(method),
+		path,
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+// SignRequest returns the hex HMAC-SHA256 of the canonical form of a request.
+func SignRequest(secret []byte, method, path, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(GetRequestStringToSign(method, path, timestamp, nonce, body)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsRequestSignatureValid compares a request signature with the expected one in constant time.
+func IsRequestSignatureValid(secret []byte, signature string, method, path, timestamp, nonce string, body []byte) bool {
+	expectedSignature := SignRequest(secret, method, path, timestamp, nonce, body)
+	return hmac.Equal([]byte(expectedSignature), []byte(strings.ToLower(signature)))
+}
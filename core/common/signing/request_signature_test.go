@@ -0,0 +1,48 @@
+package signing_test
+
+import (
+	"testing"
+
+	"github.com/sibeur/gotaro/core/common/signing"
+)
+
+func TestRequestSignature(t *testing.T) {
+	secret := []byte("signing-secret")
+	body := []byte(`{"files": ["gotaro://avatar/a.png"]}`)
+	signature := signing.SignRequest(secret, "post", "/v1/medias/get-batch", "1760000000", "nonce-1", body)
+
+	if !signing.IsRequestSignatureValid(secret, signature, "POST", "/v1/medias/get-batch", "1760000000", "nonce-1", body) {
+		t.Fatalf("Expected signature to be valid")
+	}
+
+	tests := []struct {
+		name                                 string
+		secret                               []byte
+		method, path, timestamp, nonce, body string
+	}{
+		{"secret", []byte("other-secret"), "POST", "/v1/medias/get-batch", "1760000000", "nonce-1", string(body)},
+		{"method", secret, "PUT", "/v1/medias/get-batch", "1760000000", "nonce-1", string(body)},
+		{"path", secret, "POST", "/v1/medias/get-batch?x=1", "1760000000", "nonce-1", string(body)},
+		{"timestamp", secret, "POST", "/v1/medias/get-batch", "1760000001", "nonce-1", string(body)},
+		{"nonce", secret, "POST", "/v1/medias/get-batch", "1760000000", "nonce-2", string(body)},
+		{"body", secret, "POST", "/v1/medias/get-batch", "1760000000", "nonce-1", `{"files": []}`},
+	}
+	for _, test := range tests {
+		if signing.IsRequestSignatureValid(test.secret, signature, test.method, test.path, test.timestamp, test.nonce, []byte(test.body)) {
+			t.Errorf("Expected signature to be invalid with another %v", test.name)
+		}
+	}
+}
+
+func TestParseRequestAuthorization(t *testing.T) {
+	authorization, isValid := signing.ParseRequestAuthorization(signing.FormatRequestAuthorization("client-key", "abc123"))
+	if !isValid || authorization.Key != "client-key" || authorization.Signature != "abc123" {
+		t.Errorf("Expected key and signature, got %v", authorization)
+	}
+
+	for _, header := range []string{"Bearer token", "GOTARO-HMAC-SHA256 Key=client-key", "GOTARO-HMAC-SHA256 Signature=abc123", ""} {
+		if _, isValid := signing.ParseRequestAuthorization(header); isValid {
+			t.Errorf("Expected %q to be invalid", header)
+		}
+	}
+}
@@ -4,37 +4,140 @@ import (
 	"time"
 
 	"github.com/sibeur/gotaro/core/common"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type APIClient struct {
-	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
+	ID              string    `bson:"_id,omitempty" json:"id,omitempty"`
+	CreatedAt       time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt       time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	DeletedAt       time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	Name            string    `bson:"name,omitempty" json:"name,omitempty"`
+	Key             string    `bson:"key,omitempty" json:"key,omitempty"`
+	Secret          string    `bson:"secret,omitempty" json:"-"`
+	Scopes          []string  `bson:"scopes,omitempty" json:"scopes,omitempty"`
+	IsDisabled      bool      `bson:"is_disabled,omitempty" json:"is_disabled,omitempty"`
+	SecretCreatedAt time.Time `bson:"secret_created_at,omitempty" json:"secret_created_at,omitempty"`
+	// TokensValidAfter revokes the tokens of the client issued up to this time
+	TokensValidAfter time.Time `bson:"tokens_valid_after,omitempty" json:"tokens_valid_after,omitempty"`
+	// SecretHistory keeps the hashes of the rotated secrets, still accepted until they expire
+	SecretHistory []APIClientSecret `bson:"secret_history,omitempty" json:"-"`
+	// Limits caps the requests and uploads of the client, nil for no limit
+	Limits *APIClientLimits `bson:"limits,omitempty" json:"limits,omitempty"`
+	// SigningSecret signs the requests of the client, encrypted with the master key since it has to
+	// be read back to verify a signature, plain when plaintext secrets are allowed
+	SigningSecret          string    `bson:"signing_secret,omitempty" json:"-"`
+	SigningSecretCreatedAt time.Time `bson:"signing_secret_created_at,omitempty" json:"signing_secret_created_at,omitempty"`
+	// LastUsedAt is the last login or request authenticated with the key, updated once a minute at most
+	LastUsedAt time.Time `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+}
+
+// APIClientLimits are the rate limits and upload quotas of a client, a zero value is no limit.
+type APIClientLimits struct {
+	UploadRequestsPerMinute uint32 `bson:"upload_requests_per_minute,omitempty" json:"upload_requests_per_minute,omitempty"`
+	ReadRequestsPerMinute   uint32 `bson:"read_requests_per_minute,omitempty" json:"read_requests_per_minute,omitempty"`
+	ManageRequestsPerMinute uint32 `bson:"manage_requests_per_minute,omitempty" json:"manage_requests_per_minute,omitempty"`
+	UploadBytesPerDay       uint64 `bson:"upload_bytes_per_day,omitempty" json:"upload_bytes_per_day,omitempty"`
+	UploadBytesPerMonth     uint64 `bson:"upload_bytes_per_month,omitempty" json:"upload_bytes_per_month,omitempty"`
+}
+
+// APIClientSecret is a rotated secret hash.
+type APIClientSecret struct {
+	Secret    string    `bson:"secret,omitempty" json:"-"`
 	CreatedAt time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
-	UpdatedAt time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
-	Name      string    `bson:"name,omitempty" json:"name,omitempty"`
-	Key       string    `bson:"key,omitempty" json:"key,omitempty"`
-	Secret    string    `bson:"secret,omitempty" json:"secret,omitempty"`
-	Scopes    []string  `bson:"scopes,omitempty" json:"scopes,omitempty"`
+	RotatedAt time.Time `bson:"rotated_at,omitempty" json:"rotated_at,omitempty"`
+	ExpiredAt time.Time `bson:"expired_at,omitempty" json:"expired_at,omitempty"`
 }
 
+// ToJSON describes the client without its hashed secret, which is never exposed.
 func (a *APIClient) ToJSON() common.GotaroMap {
 	return common.GotaroMap{
-		"id":         a.ID,
-		"name":       a.Name,
-		"key":        a.Key,
-		"secret":     a.Secret,
-		"scopes":     a.Scopes,
-		"created_at": a.CreatedAt,
-		"updated_at": a.UpdatedAt,
+		"id":                        a.ID,
+		"name":                      a.Name,
+		"key":                       a.Key,
+		"scopes":                    a.Scopes,
+		"is_disabled":               a.IsDisabled,
+		"secret_created_at":         a.GetSecretCreatedAt(),
+		"secret_history":            a.getSecretHistoryJSON(),
+		"tokens_valid_after":        common.DateTimeNullableToString(&a.TokensValidAfter),
+		"limits":                    a.Limits,
+		"last_used_at":              common.DateTimeNullableToString(&a.LastUsedAt),
+		"signing_secret_created_at": common.DateTimeNullableToString(&a.SigningSecretCreatedAt),
+		"created_at":                a.CreatedAt,
+		"updated_at":                a.UpdatedAt,
+	}
+}
+
+// IsSecretValid checks a secret key against the current secret and the rotated ones not expired yet.
+func (a *APIClient) IsSecretValid(secretKey string) bool {
+	if bcrypt.CompareHashAndPassword([]byte(a.Secret), []byte(secretKey)) == nil {
+		return true
+	}
+	for _, rotatedSecret := range a.GetActiveSecretHistory() {
+		if bcrypt.CompareHashAndPassword([]byte(rotatedSecret.Secret), []byte(secretKey)) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSecretCreatedAt returns when the current secret was issued, clients created before rotation
+// support got theirs on creation.
+func (a *APIClient) GetSecretCreatedAt() time.Time {
+	if a.SecretCreatedAt.IsZero() {
+		return a.CreatedAt
 	}
+	return a.SecretCreatedAt
+}
+
+// GetActiveSecretHistory returns the rotated secrets not expired yet.
+func (a *APIClient) GetActiveSecretHistory() []APIClientSecret {
+	secrets := make([]APIClientSecret, 0)
+	for _, rotatedSecret := range a.SecretHistory {
+		if time.Now().Before(rotatedSecret.ExpiredAt) {
+			secrets = append(secrets, rotatedSecret)
+		}
+	}
+	return secrets
 }
 
 func (a *APIClient) ToJSONSimple() common.GotaroMap {
 	return common.GotaroMap{
-		"id":         a.ID,
-		"name":       a.Name,
-		"created_at": a.CreatedAt,
-		"updated_at": a.UpdatedAt,
+		"id":          a.ID,
+		"name":        a.Name,
+		"is_disabled": a.IsDisabled,
+		"created_at":  a.CreatedAt,
+		"updated_at":  a.UpdatedAt,
+	}
+}
+
+// getSecretHistoryJSON describes the rotated secrets still accepted, without their hashes.
+func (a *APIClient) getSecretHistoryJSON() []common.GotaroMap {
+	history := make([]common.GotaroMap, 0)
+	for _, rotatedSecret := range a.GetActiveSecretHistory() {
+		history = append(history, common.GotaroMap{
+			"created_at": rotatedSecret.CreatedAt,
+			"rotated_at": rotatedSecret.RotatedAt,
+			"expired_at": rotatedSecret.ExpiredAt,
+		})
+	}
+	return history
+}
+
+// GetRequestsPerMinute returns the requests a client can make each minute to an endpoint class.
+func (l *APIClientLimits) GetRequestsPerMinute(endpointClass string) uint32 {
+	if l == nil {
+		return 0
+	}
+	switch endpointClass {
+	case common.EndpointClassUpload:
+		return l.UploadRequestsPerMinute
+	case common.EndpointClassRead:
+		return l.ReadRequestsPerMinute
+	case common.EndpointClassManage:
+		return l.ManageRequestsPerMinute
 	}
+	return 0
 }
 
 func (a APIClient) GetCollName() string {
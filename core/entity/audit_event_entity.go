@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+)
+
+// AuditEvent records an administrative or data action, who did it from where and what it changed.
+// The audit events are never updated nor deleted.
+type AuditEvent struct {
+	ID            string                        `bson:"_id,omitempty" json:"id,omitempty"`
+	CreatedAt     time.Time                     `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Action        string                        `bson:"action,omitempty" json:"action,omitempty"`
+	ResourceType  string                        `bson:"resource_type,omitempty" json:"resource_type,omitempty"`
+	ResourceID    string                        `bson:"resource_id,omitempty" json:"resource_id,omitempty"`
+	ActorClientID string                        `bson:"actor_client_id,omitempty" json:"actor_client_id,omitempty"`
+	ActorIP       string                        `bson:"actor_ip,omitempty" json:"actor_ip,omitempty"`
+	RequestID     string                        `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	Changes       map[string]common.AuditChange `bson:"changes,omitempty" json:"changes,omitempty"`
+	// Details tells about an action without a resource change, e.g. the key of a failed login
+	Details common.GotaroMap `bson:"details,omitempty" json:"details,omitempty"`
+}
+
+// AuditEventFilter selects audit events, the empty fields match any event. From is inclusive and To
+// exclusive so the created_at of the last event of a page is the To of the next one.
+type AuditEventFilter struct {
+	Action        string
+	ResourceType  string
+	ResourceID    string
+	ActorClientID string
+	RequestID     string
+	From          time.Time
+	To            time.Time
+}
+
+// ToJSON keeps the sub-second part of the creation time, needed to page with it.
+func (col *AuditEvent) ToJSON() common.GotaroMap {
+	return common.GotaroMap{
+		"id":              col.ID,
+		"created_at":      col.CreatedAt.Format(time.RFC3339Nano),
+		"action":          col.Action,
+		"resource_type":   col.ResourceType,
+		"resource_id":     col.ResourceID,
+		"actor_client_id": col.ActorClientID,
+		"actor_ip":        col.ActorIP,
+		"request_id":      col.RequestID,
+		"changes":         col.Changes,
+		"details":         col.Details,
+	}
+}
+
+func (col AuditEvent) GetCollName() string {
+	return "audit_events"
+}
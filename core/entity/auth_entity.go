@@ -1,5 +1,7 @@
 package entity
 
+import "time"
+
 type AccessToken struct {
 	Token     string `json:"token"`
 	IssuedAt  int64  `json:"iat"`
@@ -16,3 +18,32 @@ type Auth struct {
 	AccessToken  *AccessToken  `json:"access_token"`
 	RefreshToken *RefreshToken `json:"refresh_token"`
 }
+
+// TokenFamily is the tokens issued from one login, only its current refresh token can be used. It
+// expires with the last refresh token issued in it.
+type TokenFamily struct {
+	ID             string    `bson:"_id,omitempty" json:"id,omitempty"`
+	CreatedAt      time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt      time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	ClientID       string    `bson:"client_id,omitempty" json:"client_id,omitempty"`
+	RefreshTokenID string    `bson:"refresh_token_id,omitempty" json:"-"`
+	IsRevoked      bool      `bson:"is_revoked,omitempty" json:"is_revoked,omitempty"`
+	ExpiredAt      time.Time `bson:"expired_at,omitempty" json:"expired_at,omitempty"`
+}
+
+func (f TokenFamily) GetCollName() string {
+	return "token_families"
+}
+
+// RequestNonce is a nonce a client used to sign a request, kept as long as a request signed with it
+// could be replayed.
+type RequestNonce struct {
+	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
+	ClientID  string    `bson:"client_id,omitempty" json:"client_id,omitempty"`
+	Nonce     string    `bson:"nonce,omitempty" json:"nonce,omitempty"`
+	ExpiredAt time.Time `bson:"expired_at,omitempty" json:"expired_at,omitempty"`
+}
+
+func (n RequestNonce) GetCollName() string {
+	return "request_nonces"
+}
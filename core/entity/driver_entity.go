@@ -6,6 +6,7 @@ import (
 
 	"github.com/sibeur/gotaro/core/common"
 	"github.com/sibeur/gotaro/core/common/driver"
+	"github.com/sibeur/gotaro/core/common/secret"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -22,6 +23,12 @@ type Driver struct {
 }
 
 func (col *Driver) ToJSON() common.GotaroMap {
+	driverConfig := common.DToMap(col.DriverConfig.(primitive.D))
+	for _, field := range driver.GetSecretConfigFields(driver.StorageDriverType(col.Type)) {
+		if value, isExist := driverConfig[field]; isExist && value != "" {
+			driverConfig[field] = secret.MaskValue
+		}
+	}
 
 	return common.GotaroMap{
 		"id":            col.ID,
@@ -32,8 +39,22 @@ func (col *Driver) ToJSON() common.GotaroMap {
 		"name":          col.Name,
 		"type":          col.Type,
 		"is_public":     col.IsPublic,
-		"driver_config": common.DToMap(col.DriverConfig.(primitive.D)),
+		"driver_config": driverConfig,
+	}
+}
+
+// GetChangedSecretFields returns the secret config fields whose stored value differs from the one of
+// before, the values are masked in ToJSON so a diff of it can not tell.
+func (col *Driver) GetChangedSecretFields(before *Driver) []string {
+	driverConfig := common.DToMap(col.DriverConfig.(primitive.D))
+	beforeDriverConfig := common.DToMap(before.DriverConfig.(primitive.D))
+	fields := make([]string, 0)
+	for _, field := range driver.GetSecretConfigFields(driver.StorageDriverType(col.Type)) {
+		if !reflect.DeepEqual(driverConfig[field], beforeDriverConfig[field]) {
+			fields = append(fields, field)
+		}
 	}
+	return fields
 }
 
 func (col *Driver) ToJSONSimple() common.GotaroMap {
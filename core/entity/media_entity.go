@@ -1,35 +1,66 @@
 package entity
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/common/secret"
 )
 
 type Media struct {
-	ID                 string    `bson:"_id,omitempty" json:"id,omitempty"`
-	CreatedAt          time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
-	UpdatedAt          time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
-	DeletedAt          time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
-	RuleSlug           string    `bson:"rule_slug,omitempty" json:"rule_slug,omitempty"`
+	ID                 string           `bson:"_id,omitempty" json:"id,omitempty"`
+	CreatedAt          time.Time        `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt          time.Time        `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	DeletedAt          time.Time        `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	RuleSlug           string           `bson:"rule_slug,omitempty" json:"rule_slug,omitempty"`
+	DriverSlug         string           `bson:"driver_slug,omitempty" json:"driver_slug,omitempty"`
+	FilePath           string           `bson:"file_path,omitempty" json:"file_path,omitempty"`
+	FilePathFromDriver string           `bson:"file_path_from_driver,omitempty" json:"file_path_from_driver,omitempty"`
+	FileOriginalName   string           `bson:"file_original_name,omitempty" json:"file_original_name,omitempty"`
+	FileAliasName      string           `bson:"file_alias_name,omitempty" json:"file_alias_name,omitempty"`
+	FileDirectory      string           `bson:"file_directory,omitempty" json:"file_directory,omitempty"`
+	FileSize           uint64           `bson:"file_size,omitempty" json:"file_size,omitempty"`
+	FileMime           string           `bson:"file_mime,omitempty" json:"file_mime,omitempty"`
+	FileExt            string           `bson:"file_ext,omitempty" json:"file_ext,omitempty"`
+	IsCommit           bool             `bson:"is_commit,omitempty" json:"is_commit,omitempty"`
+	IsPublic           bool             `bson:"is_public,omitempty" json:"is_public,omitempty"`
+	Checksum           string           `bson:"checksum,omitempty" json:"checksum,omitempty"`
+	Locations          []MediaLocation  `bson:"locations,omitempty" json:"locations,omitempty"`
+	Encryption         *MediaEncryption `bson:"encryption,omitempty" json:"encryption,omitempty"`
+	// Metadata is set on the media objects besides the rule metadata
+	Metadata map[string]string `bson:"metadata,omitempty" json:"metadata,omitempty"`
+}
+
+// MediaEncryption holds what is needed to decrypt the objects of a media uploaded through an
+// encrypting rule. The data key is wrapped by the master key KeyID.
+type MediaEncryption struct {
+	KeyID      string `bson:"key_id,omitempty" json:"key_id,omitempty"`
+	WrappedKey []byte `bson:"wrapped_key,omitempty" json:"wrapped_key,omitempty"`
+	Nonce      []byte `bson:"nonce,omitempty" json:"nonce,omitempty"`
+	// ChunkSize is the size of the chunks the objects are encrypted by, zero when encrypted whole
+	ChunkSize int `bson:"chunk_size,omitempty" json:"chunk_size,omitempty"`
+}
+
+// MediaLocation is the place of a media object inside one of the rule drivers.
+type MediaLocation struct {
 	DriverSlug         string    `bson:"driver_slug,omitempty" json:"driver_slug,omitempty"`
+	ObjectPath         string    `bson:"object_path,omitempty" json:"object_path,omitempty"`
 	FilePath           string    `bson:"file_path,omitempty" json:"file_path,omitempty"`
 	FilePathFromDriver string    `bson:"file_path_from_driver,omitempty" json:"file_path_from_driver,omitempty"`
-	FileOriginalName   string    `bson:"file_original_name,omitempty" json:"file_original_name,omitempty"`
-	FileAliasName      string    `bson:"file_alias_name,omitempty" json:"file_alias_name,omitempty"`
-	FileDirectory      string    `bson:"file_directory,omitempty" json:"file_directory,omitempty"`
-	FileSize           uint64    `bson:"file_size,omitempty" json:"file_size,omitempty"`
-	FileMime           string    `bson:"file_mime,omitempty" json:"file_mime,omitempty"`
-	FileExt            string    `bson:"file_ext,omitempty" json:"file_ext,omitempty"`
-	IsCommit           bool      `bson:"is_commit,omitempty" json:"is_commit,omitempty"`
 	IsPublic           bool      `bson:"is_public,omitempty" json:"is_public,omitempty"`
+	Status             string    `bson:"status,omitempty" json:"status,omitempty"`
+	Error              string    `bson:"error,omitempty" json:"error,omitempty"`
+	UpdatedAt          time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
 }
 
 type MediaUploadOpts struct {
 	IsCommit  bool
 	Directory string
+	Metadata  map[string]string
 }
 
 func (col *Media) ToJSON() common.GotaroMap {
@@ -47,7 +78,52 @@ func (col *Media) ToJSON() common.GotaroMap {
 		"file_mime":          col.FileMime,
 		"file_ext":           col.FileExt,
 		"is_commit":          col.IsCommit,
+		"checksum":           col.Checksum,
+		"is_encrypted":       col.IsEncrypted(),
+		"locations":          col.Locations,
+		"metadata":           col.Metadata,
+	}
+}
+
+// GetObjectMetadata returns the metadata stored with the media objects, enough to rebuild the media
+// record from a bucket. The original name and checksum of an encrypted media are left out as they
+// would tell about its content.
+func (col *Media) GetObjectMetadata() map[string]string {
+	metadata := map[string]string{
+		common.ObjectMetadataMediaID:  col.ID,
+		common.ObjectMetadataRuleSlug: col.RuleSlug,
+		common.ObjectMetadataAlias:    col.FileAliasName,
+		common.ObjectMetadataCommit:   strconv.FormatBool(col.IsCommit),
+	}
+	if col.IsEncrypted() {
+		metadata[common.ObjectMetadataKeyID] = col.Encryption.KeyID
+		metadata[common.ObjectMetadataWrappedKey] = base64.StdEncoding.EncodeToString(col.Encryption.WrappedKey)
+		metadata[common.ObjectMetadataNonce] = base64.StdEncoding.EncodeToString(col.Encryption.Nonce)
+		if col.Encryption.ChunkSize > 0 {
+			metadata[common.ObjectMetadataChunkSize] = strconv.Itoa(col.Encryption.ChunkSize)
+		}
+		return metadata
 	}
+	metadata[common.ObjectMetadataOriginalName] = col.FileOriginalName
+	metadata[common.ObjectMetadataChecksum] = col.Checksum
+	return metadata
+}
+
+func (col *Media) IsEncrypted() bool {
+	return col.Encryption != nil
+}
+
+// GetObjectSize returns the size of the media objects, larger than the file when encrypted.
+func (col *Media) GetObjectSize() uint64 {
+	if col.IsEncrypted() {
+		return secret.GetEncryptedSize(col.FileSize, col.Encryption.ChunkSize)
+	}
+	return col.FileSize
+}
+
+// GetDownloadPath returns the path of the proxy download endpoint serving the media.
+func (col *Media) GetDownloadPath() string {
+	return fmt.Sprintf("/v1/medias/%s/download/%s", col.RuleSlug, col.FileAliasName)
 }
 
 func (col *Media) ToJSONSimple() common.GotaroMap {
@@ -76,6 +152,21 @@ func (col *Media) ToMediaResult() common.GotaroMap {
 	}
 }
 
+// GetReplicaLocations returns the synced locations of the media other than the primary driver.
+func (col *Media) GetReplicaLocations() []MediaLocation {
+	locations := make([]MediaLocation, 0)
+	for _, location := range col.Locations {
+		if location.DriverSlug == col.DriverSlug {
+			continue
+		}
+		if location.Status != common.MediaLocationStatusSynced {
+			continue
+		}
+		locations = append(locations, location)
+	}
+	return locations
+}
+
 func (col *Media) GetGotaroFilePath() string {
 	return fmt.Sprintf("gotaro://%s/%s", col.RuleSlug, col.FileAliasName)
 }
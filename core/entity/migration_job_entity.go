@@ -0,0 +1,107 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+)
+
+type MigrationJob struct {
+	ID               string                `bson:"_id,omitempty" json:"id,omitempty"`
+	CreatedAt        time.Time             `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt        time.Time             `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	StartedAt        time.Time             `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt       time.Time             `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	RuleSlug         string                `bson:"rule_slug,omitempty" json:"rule_slug,omitempty"`
+	SourceDriverSlug string                `bson:"source_driver_slug,omitempty" json:"source_driver_slug,omitempty"`
+	TargetDriverSlug string                `bson:"target_driver_slug,omitempty" json:"target_driver_slug,omitempty"`
+	DeleteSource     bool                  `bson:"delete_source,omitempty" json:"delete_source,omitempty"`
+	RateLimit        uint32                `bson:"rate_limit,omitempty" json:"rate_limit,omitempty"`
+	Status           string                `bson:"status,omitempty" json:"status,omitempty"`
+	Total            uint64                `bson:"total" json:"total"`
+	Processed        uint64                `bson:"processed" json:"processed"`
+	Migrated         uint64                `bson:"migrated" json:"migrated"`
+	Failed           uint64                `bson:"failed" json:"failed"`
+	LastMediaID      string                `bson:"last_media_id,omitempty" json:"last_media_id,omitempty"`
+	LastError        string                `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	Failures         []MigrationJobFailure `bson:"failures,omitempty" json:"failures,omitempty"`
+	// Owner is the instance running the job until LeaseExpiredAt, it renews the lease while running
+	Owner          string    `bson:"owner,omitempty" json:"-"`
+	LeaseExpiredAt time.Time `bson:"lease_expired_at,omitempty" json:"-"`
+}
+
+type MigrationJobFailure struct {
+	MediaID string    `bson:"media_id" json:"media_id"`
+	Error   string    `bson:"error" json:"error"`
+	At      time.Time `bson:"at" json:"at"`
+}
+
+func (col *MigrationJob) ToJSON() common.GotaroMap {
+	return common.GotaroMap{
+		"id":                 col.ID,
+		"created_at":         common.DateTimeNullableToString(&col.CreatedAt),
+		"updated_at":         common.DateTimeNullableToString(&col.UpdatedAt),
+		"started_at":         common.DateTimeNullableToString(&col.StartedAt),
+		"finished_at":        common.DateTimeNullableToString(&col.FinishedAt),
+		"rule_slug":          col.RuleSlug,
+		"source_driver_slug": col.SourceDriverSlug,
+		"target_driver_slug": col.TargetDriverSlug,
+		"delete_source":      col.DeleteSource,
+		"rate_limit":         col.GetRateLimit(),
+		"status":             col.Status,
+		"total":              col.Total,
+		"processed":          col.Processed,
+		"migrated":           col.Migrated,
+		"failed":             col.Failed,
+		"progress":           col.GetProgress(),
+		"last_error":         col.LastError,
+		"failures":           col.Failures,
+	}
+}
+
+func (col *MigrationJob) ToJSONSimple() common.GotaroMap {
+	return common.GotaroMap{
+		"id":                 col.ID,
+		"rule_slug":          col.RuleSlug,
+		"source_driver_slug": col.SourceDriverSlug,
+		"target_driver_slug": col.TargetDriverSlug,
+		"status":             col.Status,
+		"progress":           col.GetProgress(),
+	}
+}
+
+// GetRateLimit returns the maximum number of medias migrated per second.
+func (col *MigrationJob) GetRateLimit() uint32 {
+	if col.RateLimit == 0 {
+		return common.DefaultMigrationRateLimit
+	}
+	return col.RateLimit
+}
+
+// GetProgress returns the migration progress in percent.
+func (col *MigrationJob) GetProgress() float64 {
+	if col.Total == 0 {
+		if col.Status == common.MigrationJobStatusCompleted {
+			return 100
+		}
+		return 0
+	}
+	return float64(col.Processed) * 100 / float64(col.Total)
+}
+
+func (col *MigrationJob) AddFailure(mediaID string, err error) {
+	col.Failed++
+	col.LastError = err.Error()
+	if len(col.Failures) >= common.MaxMigrationFailuresTracked {
+		return
+	}
+	col.Failures = append(col.Failures, MigrationJobFailure{
+		MediaID: mediaID,
+		Error:   err.Error(),
+		At:      time.Now(),
+	})
+}
+
+func (col MigrationJob) GetCollName() string {
+	return "migration_jobs"
+}
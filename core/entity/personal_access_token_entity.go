@@ -0,0 +1,62 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+)
+
+// PersonalAccessToken is a long-lived token of an api client, authenticating requests on its own.
+// Only the SHA-256 of the token is stored, its prefix is kept in clear to look it up.
+type PersonalAccessToken struct {
+	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
+	CreatedAt time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	DeletedAt time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	ClientID  string    `bson:"client_id,omitempty" json:"client_id,omitempty"`
+	Name      string    `bson:"name,omitempty" json:"name,omitempty"`
+	Prefix    string    `bson:"prefix,omitempty" json:"prefix,omitempty"`
+	TokenHash string    `bson:"token_hash,omitempty" json:"-"`
+	// Scopes narrows the scopes of the client, all of them when empty
+	Scopes     []string  `bson:"scopes,omitempty" json:"scopes,omitempty"`
+	ExpiredAt  time.Time `bson:"expired_at,omitempty" json:"expired_at,omitempty"`
+	LastUsedAt time.Time `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+}
+
+func (p *PersonalAccessToken) ToJSON() common.GotaroMap {
+	return common.GotaroMap{
+		"id":           p.ID,
+		"client_id":    p.ClientID,
+		"name":         p.Name,
+		"prefix":       common.PersonalAccessTokenPrefix + p.Prefix,
+		"scopes":       p.Scopes,
+		"expired_at":   common.DateTimeNullableToString(&p.ExpiredAt),
+		"last_used_at": common.DateTimeNullableToString(&p.LastUsedAt),
+		"created_at":   common.DateTimeNullableToString(&p.CreatedAt),
+	}
+}
+
+func (p *PersonalAccessToken) IsExpired() bool {
+	return !p.ExpiredAt.IsZero() && time.Now().After(p.ExpiredAt)
+}
+
+// GetScopes returns the scopes the token grants among the current scopes of its client, so a scope
+// removed from the client is removed from its tokens too.
+func (p *PersonalAccessToken) GetScopes(client *APIClient) []string {
+	if len(p.Scopes) == 0 {
+		return client.Scopes
+	}
+	scopes := make([]string, 0)
+	for _, scope := range p.Scopes {
+		for _, clientScope := range client.Scopes {
+			if scope == clientScope {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+func (p PersonalAccessToken) GetCollName() string {
+	return "personal_access_tokens"
+}
@@ -0,0 +1,15 @@
+package entity
+
+import "time"
+
+// RateLimitCounter counts the requests, failed logins or uploaded bytes of one subject in one period,
+// the database drops it once expired.
+type RateLimitCounter struct {
+	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
+	Count     int64     `bson:"count" json:"count"`
+	ExpiredAt time.Time `bson:"expired_at,omitempty" json:"expired_at,omitempty"`
+}
+
+func (c RateLimitCounter) GetCollName() string {
+	return "rate_limit_counters"
+}
@@ -0,0 +1,107 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+)
+
+type ReconciliationJob struct {
+	ID                   string    `bson:"_id,omitempty" json:"id,omitempty"`
+	CreatedAt            time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt            time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	FinishedAt           time.Time `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	DriverSlug           string    `bson:"driver_slug,omitempty" json:"driver_slug,omitempty"`
+	Prefix               string    `bson:"prefix,omitempty" json:"prefix,omitempty"`
+	UntrackedAction      string    `bson:"untracked_action,omitempty" json:"untracked_action,omitempty"`
+	ImportRuleSlug       string    `bson:"import_rule_slug,omitempty" json:"import_rule_slug,omitempty"`
+	Status               string    `bson:"status,omitempty" json:"status,omitempty"`
+	ObjectCount          uint64    `bson:"object_count" json:"object_count"`
+	MediaCount           uint64    `bson:"media_count" json:"media_count"`
+	UntrackedObjectCount uint64    `bson:"untracked_object_count" json:"untracked_object_count"`
+	MissingObjectCount   uint64    `bson:"missing_object_count" json:"missing_object_count"`
+	SizeMismatchCount    uint64    `bson:"size_mismatch_count" json:"size_mismatch_count"`
+	ImportedCount        uint64    `bson:"imported_count" json:"imported_count"`
+	QuarantinedCount     uint64    `bson:"quarantined_count" json:"quarantined_count"`
+	DeletedCount         uint64    `bson:"deleted_count" json:"deleted_count"`
+	// DeletedMediaObjectCount counts the objects of soft deleted medias, left alone as they are not untracked
+	DeletedMediaObjectCount uint64               `bson:"deleted_media_object_count" json:"deleted_media_object_count"`
+	UntrackedObjects        []ReconciliationItem `bson:"untracked_objects,omitempty" json:"untracked_objects,omitempty"`
+	MissingObjects          []ReconciliationItem `bson:"missing_objects,omitempty" json:"missing_objects,omitempty"`
+	SizeMismatches          []ReconciliationItem `bson:"size_mismatches,omitempty" json:"size_mismatches,omitempty"`
+	LastError               string               `bson:"last_error,omitempty" json:"last_error,omitempty"`
+}
+
+type ReconciliationItem struct {
+	ObjectPath string `bson:"object_path,omitempty" json:"object_path,omitempty"`
+	ObjectSize int64  `bson:"object_size,omitempty" json:"object_size,omitempty"`
+	MediaID    string `bson:"media_id,omitempty" json:"media_id,omitempty"`
+	RuleSlug   string `bson:"rule_slug,omitempty" json:"rule_slug,omitempty"`
+	MediaSize  uint64 `bson:"media_size,omitempty" json:"media_size,omitempty"`
+	Action     string `bson:"action,omitempty" json:"action,omitempty"`
+	Error      string `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+func (col *ReconciliationJob) ToJSON() common.GotaroMap {
+	return common.GotaroMap{
+		"id":                         col.ID,
+		"created_at":                 common.DateTimeNullableToString(&col.CreatedAt),
+		"updated_at":                 common.DateTimeNullableToString(&col.UpdatedAt),
+		"finished_at":                common.DateTimeNullableToString(&col.FinishedAt),
+		"driver_slug":                col.DriverSlug,
+		"prefix":                     col.Prefix,
+		"untracked_action":           col.UntrackedAction,
+		"import_rule_slug":           col.ImportRuleSlug,
+		"status":                     col.Status,
+		"object_count":               col.ObjectCount,
+		"media_count":                col.MediaCount,
+		"untracked_object_count":     col.UntrackedObjectCount,
+		"missing_object_count":       col.MissingObjectCount,
+		"size_mismatch_count":        col.SizeMismatchCount,
+		"imported_count":             col.ImportedCount,
+		"quarantined_count":          col.QuarantinedCount,
+		"deleted_media_object_count": col.DeletedMediaObjectCount,
+		"deleted_count":              col.DeletedCount,
+		"untracked_objects":          col.UntrackedObjects,
+		"missing_objects":            col.MissingObjects,
+		"size_mismatches":            col.SizeMismatches,
+		"last_error":                 col.LastError,
+	}
+}
+
+func (col *ReconciliationJob) ToJSONSimple() common.GotaroMap {
+	return common.GotaroMap{
+		"id":                     col.ID,
+		"created_at":             common.DateTimeNullableToString(&col.CreatedAt),
+		"driver_slug":            col.DriverSlug,
+		"status":                 col.Status,
+		"untracked_object_count": col.UntrackedObjectCount,
+		"missing_object_count":   col.MissingObjectCount,
+		"size_mismatch_count":    col.SizeMismatchCount,
+	}
+}
+
+func (col *ReconciliationJob) AddUntrackedObject(item ReconciliationItem) {
+	col.UntrackedObjectCount++
+	if len(col.UntrackedObjects) < common.MaxReconciliationItemsTracked {
+		col.UntrackedObjects = append(col.UntrackedObjects, item)
+	}
+}
+
+func (col *ReconciliationJob) AddMissingObject(item ReconciliationItem) {
+	col.MissingObjectCount++
+	if len(col.MissingObjects) < common.MaxReconciliationItemsTracked {
+		col.MissingObjects = append(col.MissingObjects, item)
+	}
+}
+
+func (col *ReconciliationJob) AddSizeMismatch(item ReconciliationItem) {
+	col.SizeMismatchCount++
+	if len(col.SizeMismatches) < common.MaxReconciliationItemsTracked {
+		col.SizeMismatches = append(col.SizeMismatches, item)
+	}
+}
+
+func (col ReconciliationJob) GetCollName() string {
+	return "reconciliation_jobs"
+}
@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+)
+
+// Role bundles permission grants, an api client gets them by having the role slug in its scopes.
+type Role struct {
+	ID          string    `bson:"_id,omitempty" json:"id,omitempty"`
+	CreatedAt   time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt   time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	DeletedAt   time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	Slug        string    `bson:"slug,omitempty" json:"slug,omitempty"`
+	Name        string    `bson:"name,omitempty" json:"name,omitempty"`
+	Permissions []string  `bson:"permissions,omitempty" json:"permissions,omitempty"`
+	// IsBuiltin marks the roles defined in code, which are not stored
+	IsBuiltin bool `bson:"-" json:"is_builtin,omitempty"`
+}
+
+func (r *Role) ToJSON() common.GotaroMap {
+	return common.GotaroMap{
+		"id":          r.ID,
+		"slug":        r.Slug,
+		"name":        r.Name,
+		"permissions": r.Permissions,
+		"is_builtin":  r.IsBuiltin,
+		"created_at":  common.DateTimeNullableToString(&r.CreatedAt),
+		"updated_at":  common.DateTimeNullableToString(&r.UpdatedAt),
+	}
+}
+
+func (r Role) GetCollName() string {
+	return "roles"
+}
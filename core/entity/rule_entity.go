@@ -7,40 +7,83 @@ import (
 )
 
 type Rule struct {
-	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
-	CreatedAt time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
-	UpdatedAt time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
-	DeletedAt time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
-	Slug      string    `bson:"slug,omitempty" json:"slug,omitempty"`
-	Name      string    `bson:"name,omitempty" json:"name,omitempty"`
-	MaxSize   uint64    `bson:"max_size,omitempty" json:"max_size,omitempty"`
-	Mimes     []string  `bson:"mimes,omitempty" json:"mimes,omitempty"`
-	DriverID  string    `bson:"driver_id,omitempty" json:"driver_id,omitempty"`
+	ID                string             `bson:"_id,omitempty" json:"id,omitempty"`
+	CreatedAt         time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt         time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	DeletedAt         time.Time          `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	Slug              string             `bson:"slug,omitempty" json:"slug,omitempty"`
+	Name              string             `bson:"name,omitempty" json:"name,omitempty"`
+	MaxSize           uint64             `bson:"max_size,omitempty" json:"max_size,omitempty"`
+	Mimes             []string           `bson:"mimes,omitempty" json:"mimes,omitempty"`
+	DriverID          string             `bson:"driver_id,omitempty" json:"driver_id,omitempty"`
+	DriverIDs         []string           `bson:"driver_ids,omitempty" json:"driver_ids,omitempty"`
+	ReplicationPolicy string             `bson:"replication_policy,omitempty" json:"replication_policy,omitempty"`
+	ObjectOptions     *RuleObjectOptions `bson:"object_options,omitempty" json:"object_options,omitempty"`
+	// IsEncrypted encrypts the uploaded files before they reach the driver, always set so an update
+	// can turn it off
+	IsEncrypted bool `bson:"is_encrypted" json:"is_encrypted"`
+}
+
+// RuleObjectOptions are the headers and metadata set on the objects uploaded through a rule.
+type RuleObjectOptions struct {
+	CacheControl string `bson:"cache_control,omitempty" json:"cache_control,omitempty"`
+	// ContentDisposition is inline or attachment, the original file name is added on upload
+	ContentDisposition string            `bson:"content_disposition,omitempty" json:"content_disposition,omitempty"`
+	ContentLanguage    string            `bson:"content_language,omitempty" json:"content_language,omitempty"`
+	ContentEncoding    string            `bson:"content_encoding,omitempty" json:"content_encoding,omitempty"`
+	StorageClass       string            `bson:"storage_class,omitempty" json:"storage_class,omitempty"`
+	Metadata           map[string]string `bson:"metadata,omitempty" json:"metadata,omitempty"`
 }
 
 func (col *Rule) ToJSON() common.GotaroMap {
 	return common.GotaroMap{
-		"id":         col.ID,
-		"created_at": common.DateTimeNullableToString(&col.CreatedAt),
-		"updated_at": common.DateTimeNullableToString(&col.UpdatedAt),
-		"deleted_at": common.DateTimeNullableToString(&col.DeletedAt),
-		"slug":       col.Slug,
-		"name":       col.Name,
-		"max_size":   col.MaxSize,
-		"mimes":      col.Mimes,
-		"driver_id":  col.DriverID,
+		"id":                 col.ID,
+		"created_at":         common.DateTimeNullableToString(&col.CreatedAt),
+		"updated_at":         common.DateTimeNullableToString(&col.UpdatedAt),
+		"deleted_at":         common.DateTimeNullableToString(&col.DeletedAt),
+		"slug":               col.Slug,
+		"name":               col.Name,
+		"max_size":           col.MaxSize,
+		"mimes":              col.Mimes,
+		"driver_id":          col.DriverID,
+		"driver_ids":         col.GetDriverIDs(),
+		"replication_policy": col.GetReplicationPolicy(),
+		"object_options":     col.ObjectOptions,
+		"is_encrypted":       col.IsEncrypted,
 	}
 }
 
 func (col *Rule) ToJSONSimple() common.GotaroMap {
 	return common.GotaroMap{
-		"id":        col.ID,
-		"slug":      col.Slug,
-		"name":      col.Name,
-		"max_size":  col.MaxSize,
-		"mimes":     col.Mimes,
-		"driver_id": col.DriverID,
+		"id":                 col.ID,
+		"slug":               col.Slug,
+		"name":               col.Name,
+		"max_size":           col.MaxSize,
+		"mimes":              col.Mimes,
+		"driver_id":          col.DriverID,
+		"driver_ids":         col.GetDriverIDs(),
+		"replication_policy": col.GetReplicationPolicy(),
+		"is_encrypted":       col.IsEncrypted,
+	}
+}
+
+// GetDriverIDs returns the ordered driver ids of the rule, the first one is the primary driver.
+// Rules created before replication support only have DriverID.
+func (col *Rule) GetDriverIDs() []string {
+	if len(col.DriverIDs) > 0 {
+		return col.DriverIDs
+	}
+	if col.DriverID != "" {
+		return []string{col.DriverID}
+	}
+	return []string{}
+}
+
+func (col *Rule) GetReplicationPolicy() string {
+	if col.ReplicationPolicy == "" {
+		return common.RuleReplicationPolicySync
 	}
+	return col.ReplicationPolicy
 }
 
 func (col Rule) GetCollName() string {
@@ -0,0 +1,67 @@
+package entity
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+)
+
+// UploadToken is a short-lived token a client mints so a browser can upload to one of its rules
+// without the client credentials. It is a signed JWT, nothing is stored.
+type UploadToken struct {
+	ID       string `json:"id,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	// RoleIDs are the roles of the request that minted the token, the permissions it uploads with
+	RoleIDs  []string `json:"-"`
+	RuleSlug string   `json:"rule_slug,omitempty"`
+	// Directory is the prefix of the directories the token uploads to, any directory when empty
+	Directory string `json:"directory,omitempty"`
+	// MaxSize in KB narrows the max size of the rule, the rule one when zero
+	MaxSize uint64 `json:"max_size,omitempty"`
+	// MaxFiles is the number of files the token uploads, unlimited when zero
+	MaxFiles uint32 `json:"max_files,omitempty"`
+	// Metadata is stored with the objects uploaded with the token
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	IssuedAt  time.Time         `json:"issued_at,omitempty"`
+	ExpiredAt time.Time         `json:"expired_at,omitempty"`
+}
+
+func (t *UploadToken) ToJSON() common.GotaroMap {
+	return common.GotaroMap{
+		"id":         t.ID,
+		"client_id":  t.ClientID,
+		"rule_slug":  t.RuleSlug,
+		"directory":  t.Directory,
+		"max_size":   t.MaxSize,
+		"max_files":  t.MaxFiles,
+		"metadata":   t.Metadata,
+		"issued_at":  common.DateTimeNullableToString(&t.IssuedAt),
+		"expired_at": common.DateTimeNullableToString(&t.ExpiredAt),
+	}
+}
+
+// GetDirectory returns the directory to upload to for the one requested, the token directory when
+// none is, and false when the requested one is outside the token directory.
+func (t *UploadToken) GetDirectory(directory string) (string, bool) {
+	prefix := cleanDirectory(t.Directory)
+	if prefix == "" {
+		return directory, true
+	}
+	if strings.TrimSpace(directory) == "" {
+		return prefix, true
+	}
+
+	directory = cleanDirectory(directory)
+	if directory != prefix && !strings.HasPrefix(directory, prefix+"/") {
+		return "", false
+	}
+	return directory, true
+}
+
+// cleanDirectory resolves the dot segments of a directory and trims its slashes, so a directory can
+// not climb out of a prefix.
+func cleanDirectory(directory string) string {
+	return strings.Trim(path.Clean("/"+strings.TrimSpace(directory)), "/")
+}
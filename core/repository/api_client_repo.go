@@ -2,15 +2,39 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 	go_cache "github.com/sibeur/go-cache"
+	"github.com/sibeur/gotaro/core/common"
 	"github.com/sibeur/gotaro/core/entity"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+type ApiClientRepositoryUseCase interface {
+	FindAll() ([]*entity.APIClient, error)
+	FindByKey(key string) (*entity.APIClient, error)
+	FindByScope(scope string) (*entity.APIClient, error)
+	CountActiveByScope(scope string, exceptID string) (int64, error)
+	FindByID(id string) (*entity.APIClient, error)
+	Create(apiClient *entity.APIClient) error
+	Update(apiClient *entity.APIClient) error
+	UpdateSecret(apiClient *entity.APIClient, previousSecret string) (bool, error)
+	UpdateSigningSecret(apiClient *entity.APIClient) error
+	UpdateLastUsedAt(id string, lastUsedAt time.Time) error
+	IsKeyVerified(apiClient *entity.APIClient, apiKey string, secretKey string) bool
+	SetKeyVerified(apiClient *entity.APIClient, apiKey string, secretKey string)
+	Delete(id string) error
+	FindTokensValidAfter(id string) (time.Time, bool, error)
+	FindLimits(id string) (*entity.APIClientLimits, error)
+}
+
 type ApiClientRepository struct {
 	db    *mongo.Database
 	cache go_cache.Cache
@@ -20,11 +44,33 @@ func NewApiClientRepository(db *mongo.Database, cache go_cache.Cache) *ApiClient
 	return &ApiClientRepository{db: db, cache: cache}
 }
 
+func (r *ApiClientRepository) FindAll() ([]*entity.APIClient, error) {
+	ctx := context.TODO()
+	apiClients := make([]*entity.APIClient, 0)
+	opts := options.Find().SetSort(bson.M{"created_at": 1})
+	cur, err := r.db.Collection(entity.APIClient{}.GetCollName()).Find(ctx, bson.M{"deleted_at": nil}, opts)
+	if err != nil {
+		log.Printf("Error finding api clients: %v", err)
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var apiClient entity.APIClient
+		err := cur.Decode(&apiClient)
+		if err != nil {
+			log.Printf("Error decoding api client: %v", err)
+			return nil, err
+		}
+		apiClients = append(apiClients, &apiClient)
+	}
+	return apiClients, nil
+}
+
 func (r *ApiClientRepository) FindByKey(key string) (*entity.APIClient, error) {
 	ctx := context.TODO()
 
 	var apiClient entity.APIClient
-	err := r.db.Collection(apiClient.GetCollName()).FindOne(ctx, bson.M{"key": key}).Decode(&apiClient)
+	err := r.db.Collection(apiClient.GetCollName()).FindOne(ctx, bson.M{"key": key, "deleted_at": nil}).Decode(&apiClient)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, nil
@@ -38,7 +84,7 @@ func (r *ApiClientRepository) FindByScope(scope string) (*entity.APIClient, erro
 	ctx := context.TODO()
 	var apiClient entity.APIClient
 
-	err := r.db.Collection(apiClient.GetCollName()).FindOne(ctx, bson.M{"scopes": scope}).Decode(&apiClient)
+	err := r.db.Collection(apiClient.GetCollName()).FindOne(ctx, bson.M{"scopes": scope, "deleted_at": nil}).Decode(&apiClient)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, nil
@@ -48,10 +94,16 @@ func (r *ApiClientRepository) FindByScope(scope string) (*entity.APIClient, erro
 	return &apiClient, nil
 }
 
+// CountActiveByScope counts the enabled clients with a scope, other than exceptID.
+func (r *ApiClientRepository) CountActiveByScope(scope string, exceptID string) (int64, error) {
+	filter := bson.M{"scopes": scope, "is_disabled": bson.M{"$ne": true}, "deleted_at": nil, "_id": bson.M{"$ne": exceptID}}
+	return r.db.Collection(entity.APIClient{}.GetCollName()).CountDocuments(context.TODO(), filter)
+}
+
 func (r *ApiClientRepository) FindByID(id string) (*entity.APIClient, error) {
 	ctx := context.TODO()
 	var apiClient entity.APIClient
-	err := r.db.Collection(apiClient.GetCollName()).FindOne(ctx, bson.M{"_id": id}).Decode(&apiClient)
+	err := r.db.Collection(apiClient.GetCollName()).FindOne(ctx, bson.M{"_id": id, "deleted_at": nil}).Decode(&apiClient)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, nil
@@ -72,3 +124,154 @@ func (r *ApiClientRepository) Create(apiClient *entity.APIClient) error {
 	}
 	return nil
 }
+
+// Update stores the name, scopes, limits and disabled flag of a client.
+func (r *ApiClientRepository) Update(apiClient *entity.APIClient) error {
+	apiClient.UpdatedAt = time.Now()
+	filter := bson.M{"_id": apiClient.ID, "deleted_at": nil}
+	data := bson.M{"$set": bson.M{
+		"updated_at":         apiClient.UpdatedAt,
+		"name":               apiClient.Name,
+		"scopes":             apiClient.Scopes,
+		"is_disabled":        apiClient.IsDisabled,
+		"tokens_valid_after": apiClient.TokensValidAfter,
+		"limits":             apiClient.Limits,
+	}}
+	_, err := r.db.Collection(entity.APIClient{}.GetCollName()).UpdateOne(context.TODO(), filter, data)
+	if err != nil {
+		return err
+	}
+	r.DeleteCachedLimits(apiClient.ID)
+	r.DeleteCachedKeyVerified(apiClient.ID)
+	return nil
+}
+
+// UpdateSecret stores the current secret of a client and its rotated secrets, only if its secret is
+// still previousSecret so a concurrent rotation is not overwritten. It returns whether it was stored.
+func (r *ApiClientRepository) UpdateSecret(apiClient *entity.APIClient, previousSecret string) (bool, error) {
+	apiClient.UpdatedAt = time.Now()
+	filter := bson.M{"_id": apiClient.ID, "secret": previousSecret, "deleted_at": nil}
+	data := bson.M{"$set": bson.M{
+		"updated_at":        apiClient.UpdatedAt,
+		"secret":            apiClient.Secret,
+		"secret_created_at": apiClient.SecretCreatedAt,
+		"secret_history":    apiClient.SecretHistory,
+	}}
+	result, err := r.db.Collection(entity.APIClient{}.GetCollName()).UpdateOne(context.TODO(), filter, data)
+	if err != nil {
+		return false, err
+	}
+	r.DeleteCachedKeyVerified(apiClient.ID)
+	return result.MatchedCount > 0, nil
+}
+
+// UpdateSigningSecret stores the request signing secret of a client.
+func (r *ApiClientRepository) UpdateSigningSecret(apiClient *entity.APIClient) error {
+	apiClient.UpdatedAt = time.Now()
+	filter := bson.M{"_id": apiClient.ID, "deleted_at": nil}
+	data := bson.M{"$set": bson.M{
+		"updated_at":                apiClient.UpdatedAt,
+		"signing_secret":            apiClient.SigningSecret,
+		"signing_secret_created_at": apiClient.SigningSecretCreatedAt,
+	}}
+	_, err := r.db.Collection(entity.APIClient{}.GetCollName()).UpdateOne(context.TODO(), filter, data)
+	return err
+}
+
+func (r *ApiClientRepository) UpdateLastUsedAt(id string, lastUsedAt time.Time) error {
+	filter := bson.M{"_id": id, "deleted_at": nil}
+	data := bson.M{"$set": bson.M{"last_used_at": lastUsedAt}}
+	_, err := r.db.Collection(entity.APIClient{}.GetCollName()).UpdateOne(context.TODO(), filter, data)
+	return err
+}
+
+// IsKeyVerified checks a key and secret pair of a client was verified recently, sparing a bcrypt
+// comparison on every request authenticated with them. The pair is bound to the current secret of
+// the client, so it stops matching once the secret is rotated.
+func (r *ApiClientRepository) IsKeyVerified(apiClient *entity.APIClient, apiKey string, secretKey string) bool {
+	verifiedKey, _ := r.cache.Get(fmt.Sprintf(common.CacheAPIKeyVerifiedKey, apiClient.ID))
+	return verifiedKey != "" && verifiedKey == getVerifiedKeyHash(apiClient, apiKey, secretKey)
+}
+
+func (r *ApiClientRepository) SetKeyVerified(apiClient *entity.APIClient, apiKey string, secretKey string) {
+	key := fmt.Sprintf(common.CacheAPIKeyVerifiedKey, apiClient.ID)
+	if err := r.cache.SetWithExpire(key, getVerifiedKeyHash(apiClient, apiKey, secretKey), common.DefaultAPIKeyVerifiedCacheTTL); err != nil {
+		log.Printf("Error set api client verified key to cache: %v", err)
+	}
+}
+
+func (r *ApiClientRepository) DeleteCachedKeyVerified(id string) {
+	if err := r.cache.Delete(fmt.Sprintf(common.CacheAPIKeyVerifiedKey, id)); err != nil {
+		log.Printf("Error delete api client verified key from cache: %v", err)
+	}
+}
+
+func getVerifiedKeyHash(apiClient *entity.APIClient, apiKey string, secretKey string) string {
+	secretVersion := strconv.FormatInt(apiClient.GetSecretCreatedAt().UnixNano(), 10)
+	return common.GetStringSHA256(apiKey + ":" + secretKey + ":" + secretVersion)
+}
+
+func (r *ApiClientRepository) Delete(id string) error {
+	filter := bson.M{"_id": id, "deleted_at": nil}
+	data := bson.M{"$set": bson.M{"deleted_at": time.Now()}}
+	_, err := r.db.Collection(entity.APIClient{}.GetCollName()).UpdateOne(context.TODO(), filter, data)
+	if err != nil {
+		return err
+	}
+	r.DeleteCachedLimits(id)
+	r.DeleteCachedKeyVerified(id)
+	return nil
+}
+
+// FindTokensValidAfter returns the time up to which the tokens of a client are revoked and whether
+// the client can still use tokens, false once it is disabled or deleted. It is read from the
+// database on every check, so a revocation applies at once on every instance.
+func (r *ApiClientRepository) FindTokensValidAfter(id string) (time.Time, bool, error) {
+	var apiClient entity.APIClient
+	opts := options.FindOne().SetProjection(bson.M{"is_disabled": 1, "tokens_valid_after": 1})
+	err := r.db.Collection(apiClient.GetCollName()).FindOne(context.TODO(), bson.M{"_id": id, "deleted_at": nil}, opts).Decode(&apiClient)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, err
+	}
+	if apiClient.IsDisabled {
+		return time.Time{}, false, nil
+	}
+	return apiClient.TokensValidAfter, true, nil
+}
+
+// FindLimits returns the limits of a client, nil when it has none. The limits are checked on every
+// request, they are cached briefly and dropped whenever the client changes.
+func (r *ApiClientRepository) FindLimits(id string) (*entity.APIClientLimits, error) {
+	key := fmt.Sprintf(common.CacheAPIClientLimitsKey, id)
+	cachedValue, _ := r.cache.Get(key)
+	if cachedValue != "" {
+		var limits *entity.APIClientLimits
+		if err := json.Unmarshal([]byte(cachedValue), &limits); err == nil {
+			return limits, nil
+		}
+	}
+
+	apiClient, err := r.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var limits *entity.APIClientLimits
+	if apiClient != nil {
+		limits = apiClient.Limits
+	}
+	encodedLimits, _ := json.Marshal(limits)
+	if err := r.cache.SetWithExpire(key, string(encodedLimits), common.DefaultAPIClientLimitsCacheTTL); err != nil {
+		log.Printf("Error set api client limits to cache: %v", err)
+	}
+	return limits, nil
+}
+
+func (r *ApiClientRepository) DeleteCachedLimits(id string) {
+	if err := r.cache.Delete(fmt.Sprintf(common.CacheAPIClientLimitsKey, id)); err != nil {
+		log.Printf("Error delete api client limits from cache: %v", err)
+	}
+}
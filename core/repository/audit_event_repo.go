@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sibeur/gotaro/core/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditEventRepository appends the audit events, there is no way to update or delete one.
+type AuditEventRepository struct {
+	db *mongo.Database
+}
+
+func NewAuditEventRepository(db *mongo.Database) *AuditEventRepository {
+	return &AuditEventRepository{db: db}
+}
+
+func (r *AuditEventRepository) Create(event *entity.AuditEvent) error {
+	event.ID = uuid.NewString()
+	event.CreatedAt = time.Now()
+	_, err := r.db.Collection(event.GetCollName()).InsertOne(context.TODO(), event)
+	return err
+}
+
+// FindAll returns the latest events matching a filter, up to limit.
+func (r *AuditEventRepository) FindAll(filter *entity.AuditEventFilter, limit int64) ([]*entity.AuditEvent, error) {
+	events := make([]*entity.AuditEvent, 0)
+	err := r.find(filter, options.Find().SetLimit(limit), func(event *entity.AuditEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ForEach calls fn with every event matching a filter, latest first, without loading them all.
+func (r *AuditEventRepository) ForEach(filter *entity.AuditEventFilter, fn func(event *entity.AuditEvent) error) error {
+	return r.find(filter, options.Find(), fn)
+}
+
+func (r *AuditEventRepository) find(filter *entity.AuditEventFilter, opts *options.FindOptions, fn func(event *entity.AuditEvent) error) error {
+	ctx := context.TODO()
+	opts.SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+	cur, err := r.db.Collection(entity.AuditEvent{}.GetCollName()).Find(ctx, getAuditEventQuery(filter), opts)
+	if err != nil {
+		log.Printf("Error finding audit events: %v", err)
+		return err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var event entity.AuditEvent
+		err := cur.Decode(&event)
+		if err != nil {
+			log.Printf("Error decoding audit event: %v", err)
+			return err
+		}
+		if err := fn(&event); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+func getAuditEventQuery(filter *entity.AuditEventFilter) bson.M {
+	query := bson.M{}
+	fields := map[string]string{
+		"action":          filter.Action,
+		"resource_type":   filter.ResourceType,
+		"resource_id":     filter.ResourceID,
+		"actor_client_id": filter.ActorClientID,
+		"request_id":      filter.RequestID,
+	}
+	for field, value := range fields {
+		if value != "" {
+			query[field] = value
+		}
+	}
+
+	createdAt := bson.M{}
+	if !filter.From.IsZero() {
+		createdAt["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		createdAt["$lt"] = filter.To
+	}
+	if len(createdAt) > 0 {
+		query["created_at"] = createdAt
+	}
+	return query
+}
@@ -1,67 +1,392 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	go_cache "github.com/sibeur/go-cache"
 	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/common/oidc"
+	"github.com/sibeur/gotaro/core/common/signing"
 	"github.com/sibeur/gotaro/core/entity"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+type AuthRepositoryUseCase interface {
+	CreateIndexes() error
+	CreateToken(subject string, audiences []string) (*entity.Auth, error)
+	RotateToken(subject string, audiences []string, familyID string, refreshTokenID string) (*entity.Auth, error)
+	FindTokenFamily(familyID string) (*entity.TokenFamily, error)
+	ValidateToken(token string) (*jwt.Token, error)
+	CreateUploadToken(uploadToken *entity.UploadToken) (string, error)
+	ParseUploadToken(token string) (*entity.UploadToken, error)
+	GetJWKS() common.GotaroMap
+	IsOIDCToken(token string) bool
+	VerifyOIDCToken(token string) (*oidc.Identity, error)
+	FindOIDCLimits(clientID string) *entity.APIClientLimits
+	RevokeToken(token *jwt.Token) error
+	UseNonce(clientID string, nonce string, ttl uint64) (bool, error)
+	GetLoginLock(subjectType string, subject string) time.Time
+	SetLoginLock(subjectType string, subject string, lockedUntil time.Time) error
+	DeleteLoginLock(subjectType string, subject string) error
+	RevokeTokenFamily(familyID string) error
+	IsTokenFamilyRevoked(familyID string) bool
+	IsTokenRevoked(token *jwt.Token) bool
+}
+
 type AuthRepository struct {
-	cache go_cache.Cache
+	db           *mongo.Database
+	cache        go_cache.Cache
+	keySet       *signing.KeySet
+	oidcVerifier *oidc.Verifier
+}
+
+// tokenClaims are the claims of the gotaro tokens. The tokens issued from one login share a family,
+// carried along every refresh.
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	FamilyID string `json:"fam,omitempty"`
+}
+
+// uploadTokenClaims are the claims of an upload token, its roles and constraints besides the client.
+type uploadTokenClaims struct {
+	jwt.RegisteredClaims
+	RoleIDs   []string          `json:"role_ids,omitempty"`
+	RuleSlug  string            `json:"rule"`
+	Directory string            `json:"dir,omitempty"`
+	MaxSize   uint64            `json:"max_size,omitempty"`
+	MaxFiles  uint32            `json:"max_files,omitempty"`
+	Metadata  map[string]string `json:"meta,omitempty"`
+}
+
+func NewAuthRepository(db *mongo.Database, cache go_cache.Cache, keySet *signing.KeySet, oidcVerifier *oidc.Verifier) *AuthRepository {
+	return &AuthRepository{db: db, cache: cache, keySet: keySet, oidcVerifier: oidcVerifier}
 }
 
-func NewAuthRepository(cache go_cache.Cache) *AuthRepository {
-	return &AuthRepository{cache: cache}
+// CreateIndexes creates the indexes the token families and request nonces rely on, the expired ones
+// are dropped by the database.
+func (u *AuthRepository) CreateIndexes() error {
+	ctx := context.TODO()
+	expiryIndex := mongo.IndexModel{
+		Keys:    bson.M{"expired_at": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	if _, err := u.db.Collection(entity.TokenFamily{}.GetCollName()).Indexes().CreateOne(ctx, expiryIndex); err != nil {
+		return err
+	}
+
+	nonceIndexes := []mongo.IndexModel{
+		expiryIndex,
+		{Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "nonce", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+	_, err := u.db.Collection(entity.RequestNonce{}.GetCollName()).Indexes().CreateMany(ctx, nonceIndexes)
+	return err
 }
 
+// CreateToken issues an access and refresh token pair in a new token family. The refresh token is
+// the only one of the family that can be used.
 func (u *AuthRepository) CreateToken(subject string, audiences []string) (*entity.Auth, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return nil, errors.New(common.ErrJWTSecretNotFoundMsg)
+	familyID := uuid.NewString()
+	auth, refreshTokenID, err := u.createTokenPair(subject, audiences, familyID)
+	if err != nil {
+		return nil, err
 	}
 
-	accessToken, err := u.createAccessToken(subject, audiences, secret)
+	now := time.Now()
+	family := &entity.TokenFamily{
+		ID:             familyID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		ClientID:       subject,
+		RefreshTokenID: refreshTokenID,
+		ExpiredAt:      time.Unix(auth.RefreshToken.ExpiredAt, 0),
+	}
+	if _, err := u.db.Collection(family.GetCollName()).InsertOne(context.TODO(), family); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+// RotateToken issues a new token pair in a family in exchange for its current refresh token. The
+// exchange is a single conditional update, so among concurrent refreshes with the same token on any
+// instance only one gets a pair, the others get nil.
+func (u *AuthRepository) RotateToken(subject string, audiences []string, familyID string, refreshTokenID string) (*entity.Auth, error) {
+	auth, nextRefreshTokenID, err := u.createTokenPair(subject, audiences, familyID)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := u.createRefreshToken(subject, audiences, secret)
+	filter := bson.M{"_id": familyID, "client_id": subject, "refresh_token_id": refreshTokenID, "is_revoked": bson.M{"$ne": true}}
+	update := bson.M{"$set": bson.M{
+		"refresh_token_id": nextRefreshTokenID,
+		"expired_at":       time.Unix(auth.RefreshToken.ExpiredAt, 0),
+		"updated_at":       time.Now(),
+	}}
+	err = u.db.Collection(entity.TokenFamily{}.GetCollName()).FindOneAndUpdate(context.TODO(), filter, update).Err()
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
 		return nil, err
 	}
+	return auth, nil
+}
+
+// FindTokenFamily returns a token family, nil once it expired.
+func (u *AuthRepository) FindTokenFamily(familyID string) (*entity.TokenFamily, error) {
+	var family entity.TokenFamily
+	err := u.db.Collection(family.GetCollName()).FindOne(context.TODO(), bson.M{"_id": familyID}).Decode(&family)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &family, nil
+}
+
+func (u *AuthRepository) createTokenPair(subject string, audiences []string, familyID string) (*entity.Auth, string, error) {
+	if !u.keySet.IsEnabled() {
+		return nil, "", errors.New(common.ErrJWTSecretNotFoundMsg)
+	}
+
+	accessToken, err := u.createAccessToken(subject, audiences, familyID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	refreshToken, refreshTokenID, err := u.createRefreshToken(subject, audiences, familyID)
+	if err != nil {
+		return nil, "", err
+	}
 	return &entity.Auth{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
-	}, nil
+	}, refreshTokenID, nil
 }
 
 func (u *AuthRepository) ValidateToken(token string) (*jwt.Token, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
+	if !u.keySet.IsEnabled() {
 		return nil, errors.New(common.ErrJWTSecretNotFoundMsg)
 	}
 
-	t, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+	t, err := u.keySet.Parse(token)
 	if err != nil {
-		if errors.Is(err, jwt.ErrTokenExpired) {
-			return nil, errors.New(common.ErrJWTTokenInvalidMsg)
-		}
-		return nil, err
+		log.Printf("Error parsing token: %v", err)
+		return nil, errors.New(common.ErrJWTTokenInvalidMsg)
 	}
 	return t, nil
 }
 
-func (u *AuthRepository) createAccessToken(subject string, audiences []string, secret string) (*entity.AccessToken, error) {
+// CreateUploadToken signs an upload token, its id and issue time are set here.
+func (u *AuthRepository) CreateUploadToken(uploadToken *entity.UploadToken) (string, error) {
+	if !u.keySet.IsEnabled() {
+		return "", errors.New(common.ErrJWTSecretNotFoundMsg)
+	}
+
+	uploadToken.ID = uuid.NewString()
+	uploadToken.IssuedAt = time.Now()
+	claims := &uploadTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   uploadToken.ClientID,
+			Issuer:    common.JWTIssuerUploadToken,
+			ExpiresAt: jwt.NewNumericDate(uploadToken.ExpiredAt),
+			IssuedAt:  jwt.NewNumericDate(uploadToken.IssuedAt),
+			ID:        uploadToken.ID,
+		},
+		RoleIDs:   uploadToken.RoleIDs,
+		RuleSlug:  uploadToken.RuleSlug,
+		Directory: uploadToken.Directory,
+		MaxSize:   uploadToken.MaxSize,
+		MaxFiles:  uploadToken.MaxFiles,
+		Metadata:  uploadToken.Metadata,
+	}
+	ss, err := u.keySet.Sign(claims)
+	if err != nil {
+		return "", err
+	}
+	return common.UploadTokenPrefix + ss, nil
+}
+
+// ParseUploadToken verifies an upload token and returns its constraints.
+func (u *AuthRepository) ParseUploadToken(token string) (*entity.UploadToken, error) {
+	if !u.keySet.IsEnabled() {
+		return nil, errors.New(common.ErrJWTSecretNotFoundMsg)
+	}
+
+	t, err := u.keySet.Parse(strings.TrimPrefix(token, common.UploadTokenPrefix))
+	if err != nil {
+		log.Printf("Error parsing upload token: %v", err)
+		return nil, errors.New(common.ErrJWTTokenInvalidMsg)
+	}
+
+	// the claims are read again into their own type, the signature is already verified
+	claims := &uploadTokenClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(t.Raw, claims); err != nil {
+		return nil, errors.New(common.ErrJWTTokenInvalidMsg)
+	}
+	if claims.Issuer != common.JWTIssuerUploadToken || claims.Subject == "" || claims.RuleSlug == "" ||
+		claims.IssuedAt == nil || claims.ExpiresAt == nil {
+		return nil, errors.New(common.ErrJWTTokenInvalidMsg)
+	}
+
+	return &entity.UploadToken{
+		ID:        claims.ID,
+		ClientID:  claims.Subject,
+		RoleIDs:   claims.RoleIDs,
+		RuleSlug:  claims.RuleSlug,
+		Directory: claims.Directory,
+		MaxSize:   claims.MaxSize,
+		MaxFiles:  claims.MaxFiles,
+		Metadata:  claims.Metadata,
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiredAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// GetJWKS returns the public keys verifying the tokens.
+func (u *AuthRepository) GetJWKS() common.GotaroMap {
+	return u.keySet.GetJWKS()
+}
+
+// IsOIDCToken tells whether a token claims to be issued by a trusted OIDC issuer.
+func (u *AuthRepository) IsOIDCToken(token string) bool {
+	return u.oidcVerifier.IsTrusted(token)
+}
+
+// VerifyOIDCToken verifies a token of a trusted OIDC issuer and maps its claims to scopes.
+func (u *AuthRepository) VerifyOIDCToken(token string) (*oidc.Identity, error) {
+	return u.oidcVerifier.Verify(token)
+}
+
+// FindOIDCLimits returns the default limits of the OIDC issuer of a caller, nil when the caller is
+// not of an issuer or the issuer has no limits.
+func (u *AuthRepository) FindOIDCLimits(clientID string) *entity.APIClientLimits {
+	limits := u.oidcVerifier.GetLimits(clientID)
+	if limits == nil {
+		return nil
+	}
+	return &entity.APIClientLimits{
+		UploadRequestsPerMinute: limits.UploadRequestsPerMinute,
+		ReadRequestsPerMinute:   limits.ReadRequestsPerMinute,
+		ManageRequestsPerMinute: limits.ManageRequestsPerMinute,
+		UploadBytesPerDay:       limits.UploadBytesPerDay,
+		UploadBytesPerMonth:     limits.UploadBytesPerMonth,
+	}
+}
+
+// RevokeToken denies a token until it expires. The denylist lives in the cache, which has to be
+// shared by the instances for a revocation to apply to all of them.
+func (u *AuthRepository) RevokeToken(token *jwt.Token) error {
+	tokenID := GetTokenID(token)
+	if tokenID == "" {
+		return errors.New(common.ErrJWTTokenInvalidMsg)
+	}
+
+	expiredAt, err := token.Claims.GetExpirationTime()
+	if err != nil || expiredAt == nil {
+		return errors.New(common.ErrJWTTokenInvalidMsg)
+	}
+
+	ttl := time.Until(expiredAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+	return u.cache.SetWithExpire(fmt.Sprintf(common.CacheRevokedTokenKey, tokenID), "1", uint64(ttl.Seconds())+1)
+}
+
+// UseNonce records the nonce of a signed request until ttl seconds, false when it was already used.
+// The unique index on the nonces makes a single instance accept it, whichever gets it first.
+func (u *AuthRepository) UseNonce(clientID string, nonce string, ttl uint64) (bool, error) {
+	requestNonce := &entity.RequestNonce{
+		ID:        uuid.NewString(),
+		ClientID:  clientID,
+		Nonce:     nonce,
+		ExpiredAt: time.Now().Add(time.Second * time.Duration(ttl)),
+	}
+	if _, err := u.db.Collection(requestNonce.GetCollName()).InsertOne(context.TODO(), requestNonce); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// GetLoginLock returns until when the logins of a key or IP are refused, zero when they are not.
+func (u *AuthRepository) GetLoginLock(subjectType string, subject string) time.Time {
+	cachedValue, _ := u.cache.Get(fmt.Sprintf(common.CacheLoginLockKey, subjectType, subject))
+	lockedUntil, err := strconv.ParseInt(cachedValue, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(lockedUntil, 0)
+}
+
+// SetLoginLock refuses the logins of a key or IP until a time.
+func (u *AuthRepository) SetLoginLock(subjectType string, subject string, lockedUntil time.Time) error {
+	ttl := uint64(time.Until(lockedUntil).Seconds()) + 1
+	return u.cache.SetWithExpire(fmt.Sprintf(common.CacheLoginLockKey, subjectType, subject), strconv.FormatInt(lockedUntil.Unix(), 10), ttl)
+}
+
+func (u *AuthRepository) DeleteLoginLock(subjectType string, subject string) error {
+	return u.cache.Delete(fmt.Sprintf(common.CacheLoginLockKey, subjectType, subject))
+}
+
+// RevokeTokenFamily denies every token issued in a family until the last of them expires.
+func (u *AuthRepository) RevokeTokenFamily(familyID string) error {
+	filter := bson.M{"_id": familyID}
+	update := bson.M{"$set": bson.M{"is_revoked": true, "updated_at": time.Now()}}
+	_, err := u.db.Collection(entity.TokenFamily{}.GetCollName()).UpdateOne(context.TODO(), filter, update)
+	return err
+}
+
+// IsTokenFamilyRevoked checks a family was revoked, a family that can not be read counts as revoked.
+func (u *AuthRepository) IsTokenFamilyRevoked(familyID string) bool {
+	family, err := u.FindTokenFamily(familyID)
+	if err != nil {
+		log.Printf("Error finding token family %v: %v", familyID, err)
+		return true
+	}
+	return family != nil && family.IsRevoked
+}
+
+func (u *AuthRepository) IsTokenRevoked(token *jwt.Token) bool {
+	isRevoked, _ := u.cache.Get(fmt.Sprintf(common.CacheRevokedTokenKey, GetTokenID(token)))
+	return isRevoked != ""
+}
+
+// GetTokenID returns the jti claim of a token.
+func GetTokenID(token *jwt.Token) string {
+	return getStringClaim(token, "jti")
+}
+
+// GetTokenFamilyID returns the family of a token, empty for the tokens issued before families.
+func GetTokenFamilyID(token *jwt.Token) string {
+	return getStringClaim(token, "fam")
+}
+
+func getStringClaim(token *jwt.Token, name string) string {
+	claims, isMapClaims := token.Claims.(jwt.MapClaims)
+	if !isMapClaims {
+		return ""
+	}
+	value, _ := claims[name].(string)
+	return value
+}
+
+func (u *AuthRepository) createAccessToken(subject string, audiences []string, familyID string) (*entity.AccessToken, error) {
 	expMinutes := 5
 	if os.Getenv("ACCESS_TOKEN_EXPIRY_MINUTES") != "" {
 		expMinutes, _ = strconv.Atoi(os.Getenv("ACCESS_TOKEN_EXPIRY_MINUTES"))
@@ -70,16 +395,18 @@ func (u *AuthRepository) createAccessToken(subject string, audiences []string, s
 	iat := jwt.NewNumericDate(now)
 	// Set the expiration time to 5 minutes from now
 	exp := jwt.NewNumericDate(now.Add(time.Minute * time.Duration(expMinutes)))
-	claims := &jwt.RegisteredClaims{
-		Subject:   subject,
-		Issuer:    common.JWTIssuerAccessToken,
-		ExpiresAt: exp,
-		Audience:  audiences,
-		IssuedAt:  iat,
-		ID:        uuid.NewString(),
+	claims := &tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    common.JWTIssuerAccessToken,
+			ExpiresAt: exp,
+			Audience:  audiences,
+			IssuedAt:  iat,
+			ID:        uuid.NewString(),
+		},
+		FamilyID: familyID,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	ss, err := token.SignedString([]byte(secret))
+	ss, err := u.keySet.Sign(claims)
 	if err != nil {
 		return nil, err
 	}
@@ -90,32 +417,38 @@ func (u *AuthRepository) createAccessToken(subject string, audiences []string, s
 	}, nil
 }
 
-func (u *AuthRepository) createRefreshToken(subject string, audiences []string, secret string) (*entity.RefreshToken, error) {
-	// expMinutes in 7 days
-	expMinutes := 60 * 24 * 7
-	if os.Getenv("REFRESH_TOKEN_EXPIRY_MINUTES") != "" {
-		expMinutes, _ = strconv.Atoi(os.Getenv("REFRESH_TOKEN_EXPIRY_MINUTES"))
-	}
+func (u *AuthRepository) createRefreshToken(subject string, audiences []string, familyID string) (*entity.RefreshToken, string, error) {
 	now := time.Now()
 	iat := jwt.NewNumericDate(now)
 	// Set the expiration time to 7 days from now
-	exp := jwt.NewNumericDate(now.Add(time.Minute * time.Duration(expMinutes)))
-	claims := &jwt.RegisteredClaims{
-		Subject:   subject,
-		Issuer:    common.JWTIssuerRefreshToken,
-		ExpiresAt: exp,
-		Audience:  audiences,
-		IssuedAt:  iat,
-		ID:        uuid.NewString(),
+	exp := jwt.NewNumericDate(now.Add(getRefreshTokenExpiry()))
+	claims := &tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    common.JWTIssuerRefreshToken,
+			ExpiresAt: exp,
+			Audience:  audiences,
+			IssuedAt:  iat,
+			ID:        uuid.NewString(),
+		},
+		FamilyID: familyID,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	ss, err := token.SignedString([]byte(secret))
+	ss, err := u.keySet.Sign(claims)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	return &entity.RefreshToken{
 		Token:     ss,
 		IssuedAt:  iat.Unix(),
 		ExpiredAt: exp.Unix(),
-	}, nil
+	}, claims.ID, nil
+}
+
+func getRefreshTokenExpiry() time.Duration {
+	// expMinutes in 7 days
+	expMinutes := 60 * 24 * 7
+	if os.Getenv("REFRESH_TOKEN_EXPIRY_MINUTES") != "" {
+		expMinutes, _ = strconv.Atoi(os.Getenv("REFRESH_TOKEN_EXPIRY_MINUTES"))
+	}
+	return time.Minute * time.Duration(expMinutes)
 }
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	errCodeChangeStreamNotSupported = 40573
+	errCodeChangeStreamHistoryLost  = 286
+	errCodeUnknownField             = 40415
+)
+
+// ChangeEvent is a change of a document made by any gotaro instance sharing the database.
+type ChangeEvent struct {
+	Collection    string
+	OperationType string
+	DocumentID    string
+	Slug          string
+	RuleSlug      string
+	FileAliasName string
+}
+
+type changeEventDocument struct {
+	Slug          string `bson:"slug"`
+	RuleSlug      string `bson:"rule_slug"`
+	FileAliasName string `bson:"file_alias_name"`
+}
+
+type ChangeStreamRepository struct {
+	db          *mongo.Database
+	resumeToken bson.Raw
+	// isPreImageUnsupported is set once the server rejected the pre-images, before MongoDB 6.0, the
+	// hard deleted documents then come without their slug
+	isPreImageUnsupported bool
+}
+
+func NewChangeStreamRepository(db *mongo.Database) *ChangeStreamRepository {
+	return &ChangeStreamRepository{db: db}
+}
+
+// Watch streams the changes of collNames to handler until ctx is done or the stream fails. A new
+// call resumes after the last event handled.
+func (u *ChangeStreamRepository) Watch(ctx context.Context, collNames []string, handler func(*ChangeEvent)) error {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"ns.coll": bson.M{"$in": collNames}}}}}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if !u.isPreImageUnsupported {
+		opts.SetFullDocumentBeforeChange(options.WhenAvailable)
+	}
+	if u.resumeToken != nil {
+		opts.SetResumeAfter(u.resumeToken)
+	}
+
+	stream, err := u.db.Watch(ctx, pipeline, opts)
+	if err != nil {
+		if !u.isPreImageUnsupported && isPreImageRejected(err) {
+			log.Printf("Change stream pre-images are not supported, watching without them: %v", err)
+			u.isPreImageUnsupported = true
+			return u.Watch(ctx, collNames, handler)
+		}
+		u.resetResumeTokenOnHistoryLost(err)
+		return err
+	}
+	defer stream.Close(ctx)
+
+	for stream.Next(ctx) {
+		var change struct {
+			OperationType string `bson:"operationType"`
+			Ns            struct {
+				Coll string `bson:"coll"`
+			} `bson:"ns"`
+			DocumentKey struct {
+				ID string `bson:"_id"`
+			} `bson:"documentKey"`
+			FullDocument             *changeEventDocument `bson:"fullDocument"`
+			FullDocumentBeforeChange *changeEventDocument `bson:"fullDocumentBeforeChange"`
+		}
+		if err := stream.Decode(&change); err != nil {
+			return err
+		}
+
+		event := &ChangeEvent{
+			Collection:    change.Ns.Coll,
+			OperationType: change.OperationType,
+			DocumentID:    change.DocumentKey.ID,
+		}
+		document := change.FullDocument
+		if document == nil {
+			document = change.FullDocumentBeforeChange
+		}
+		if document != nil {
+			event.Slug = document.Slug
+			event.RuleSlug = document.RuleSlug
+			event.FileAliasName = document.FileAliasName
+		}
+
+		handler(event)
+		u.resumeToken = stream.ResumeToken()
+	}
+
+	u.resetResumeTokenOnHistoryLost(stream.Err())
+	return stream.Err()
+}
+
+func (u *ChangeStreamRepository) resetResumeTokenOnHistoryLost(err error) {
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(errCodeChangeStreamHistoryLost) {
+		u.resumeToken = nil
+	}
+}
+
+// isPreImageRejected reports whether err comes from a server not knowing the
+// fullDocumentBeforeChange option, added in MongoDB 6.0.
+func isPreImageRejected(err error) bool {
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(errCodeUnknownField) {
+		return true
+	}
+	return err != nil && strings.Contains(err.Error(), "fullDocumentBeforeChange")
+}
+
+// IsChangeStreamUnsupported reports whether err comes from a deployment without change streams,
+// such as a standalone server.
+func IsChangeStreamUnsupported(err error) bool {
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(errCodeChangeStreamNotSupported) {
+		return true
+	}
+	return err != nil && strings.Contains(err.Error(), "only supported on replica sets")
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/sibeur/gotaro/core/entity"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MediaRepository struct {
@@ -45,7 +46,9 @@ func (u *MediaRepository) FindAll() ([]*entity.Media, error) {
 }
 
 func (u *MediaRepository) Create(media *entity.Media) error {
-	media.ID = uuid.NewString()
+	if media.ID == "" {
+		media.ID = uuid.NewString()
+	}
 	media.CreatedAt = time.Now()
 	media.UpdatedAt = time.Now()
 	_, err := u.db.Collection(entity.Media{}.GetCollName()).InsertOne(context.TODO(), media)
@@ -62,6 +65,8 @@ func (u *MediaRepository) Delete(ruleSlug, fileAliasName string) error {
 	if err != nil {
 		return err
 	}
+	u.DeleteCachedMedia(ruleSlug, fileAliasName)
+	u.DeleteCachedSignedUrl(ruleSlug, fileAliasName)
 	return nil
 }
 
@@ -98,6 +103,19 @@ func (u *MediaRepository) FindMedia(ruleSlug, fileAliasName string) (*entity.Med
 	return &media, nil
 }
 
+// FindByID returns a media by id, including a deleted one.
+func (u *MediaRepository) FindByID(id string) (*entity.Media, error) {
+	var media entity.Media
+	err := u.db.Collection(media.GetCollName()).FindOne(context.TODO(), bson.M{"_id": id}).Decode(&media)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &media, nil
+}
+
 func (u *MediaRepository) SetSignedUrl(ruleSlug, fileAliasName, signedUrl string) error {
 	filter := bson.M{"rule_slug": ruleSlug, "file_alias_name": fileAliasName, "deleted_at": nil}
 	data := bson.M{"$set": bson.M{"file_path": signedUrl}}
@@ -124,3 +142,166 @@ func (u *MediaRepository) SetCachedSignedUrl(ruleSlug, fileAliasName, signedUrl
 		log.Printf("Error set media signed url to cache: %v", err)
 	}
 }
+
+func (u *MediaRepository) UpdateLocation(media *entity.Media, location entity.MediaLocation) error {
+	location.UpdatedAt = time.Now()
+	filter := bson.M{"_id": media.ID, "locations.driver_slug": location.DriverSlug}
+	data := bson.M{"$set": bson.M{"locations.$": location, "updated_at": time.Now()}}
+	_, err := u.db.Collection(entity.Media{}.GetCollName()).UpdateOne(context.TODO(), filter, data)
+	if err != nil {
+		return err
+	}
+	u.DeleteCachedMedia(media.RuleSlug, media.FileAliasName)
+	return nil
+}
+
+func (u *MediaRepository) DeleteCachedMedia(ruleSlug, fileAliasName string) {
+	if err := u.cache.Delete(fmt.Sprintf(common.CacheGetMediaKey, ruleSlug, fileAliasName)); err != nil {
+		log.Printf("Error delete media from cache: %v", err)
+	}
+}
+
+func (u *MediaRepository) migrationFilter(ruleSlug, sourceDriverSlug, targetDriverSlug string) bson.M {
+	filter := bson.M{"rule_slug": ruleSlug, "deleted_at": nil, "driver_slug": bson.M{"$ne": targetDriverSlug}}
+	if sourceDriverSlug != "" {
+		filter["driver_slug"] = sourceDriverSlug
+	}
+	return filter
+}
+
+func (u *MediaRepository) CountForMigration(ruleSlug, sourceDriverSlug, targetDriverSlug string) (uint64, error) {
+	filter := u.migrationFilter(ruleSlug, sourceDriverSlug, targetDriverSlug)
+	count, err := u.db.Collection(entity.Media{}.GetCollName()).CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(count), nil
+}
+
+// FindForMigration returns the next medias to migrate ordered by id, starting after afterID.
+func (u *MediaRepository) FindForMigration(ruleSlug, sourceDriverSlug, targetDriverSlug, afterID string, limit int64) ([]*entity.Media, error) {
+	ctx := context.TODO()
+	filter := u.migrationFilter(ruleSlug, sourceDriverSlug, targetDriverSlug)
+	if afterID != "" {
+		filter["_id"] = bson.M{"$gt": afterID}
+	}
+	opts := options.Find().SetSort(bson.M{"_id": 1}).SetLimit(limit)
+	medias := make([]*entity.Media, 0)
+	cur, err := u.db.Collection(entity.Media{}.GetCollName()).Find(ctx, filter, opts)
+	if err != nil {
+		log.Printf("Error finding medias: %v", err)
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var media entity.Media
+		err := cur.Decode(&media)
+		if err != nil {
+			log.Printf("Error decoding media: %v", err)
+			return nil, err
+		}
+		medias = append(medias, &media)
+	}
+	return medias, nil
+}
+
+// UpdateStorage stores the driver and location fields of a media and evicts its cached copies.
+func (u *MediaRepository) UpdateStorage(media *entity.Media) error {
+	media.UpdatedAt = time.Now()
+	filter := bson.M{"_id": media.ID}
+	data := bson.M{"$set": bson.M{
+		"updated_at":            media.UpdatedAt,
+		"driver_slug":           media.DriverSlug,
+		"file_path":             media.FilePath,
+		"file_path_from_driver": media.FilePathFromDriver,
+		"is_public":             media.IsPublic,
+		"locations":             media.Locations,
+	}}
+	_, err := u.db.Collection(entity.Media{}.GetCollName()).UpdateOne(context.TODO(), filter, data)
+	if err != nil {
+		return err
+	}
+	u.DeleteCachedMedia(media.RuleSlug, media.FileAliasName)
+	u.DeleteCachedSignedUrl(media.RuleSlug, media.FileAliasName)
+	return nil
+}
+
+func (u *MediaRepository) DeleteCachedSignedUrl(ruleSlug, fileAliasName string) {
+	if err := u.cache.Delete(fmt.Sprintf(common.CacheMediaSignedUrlKey, ruleSlug, fileAliasName)); err != nil {
+		log.Printf("Error delete media signed url from cache: %v", err)
+	}
+}
+
+// FindAllByDriver returns the medias stored in a driver, either as primary or as replica.
+func (u *MediaRepository) FindAllByDriver(driverSlug string) ([]*entity.Media, error) {
+	return u.findAllByDriver(driverSlug, nil)
+}
+
+// FindAllDeletedByDriver returns the soft deleted medias stored in a driver.
+func (u *MediaRepository) FindAllDeletedByDriver(driverSlug string) ([]*entity.Media, error) {
+	return u.findAllByDriver(driverSlug, bson.M{"$ne": nil})
+}
+
+func (u *MediaRepository) findAllByDriver(driverSlug string, deletedAt any) ([]*entity.Media, error) {
+	ctx := context.TODO()
+	medias := make([]*entity.Media, 0)
+	filter := bson.M{
+		"deleted_at": deletedAt,
+		"$or": bson.A{
+			bson.M{"driver_slug": driverSlug},
+			bson.M{"locations.driver_slug": driverSlug},
+		},
+	}
+	cur, err := u.db.Collection(entity.Media{}.GetCollName()).Find(ctx, filter)
+	if err != nil {
+		log.Printf("Error finding medias: %v", err)
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var media entity.Media
+		err := cur.Decode(&media)
+		if err != nil {
+			log.Printf("Error decoding media: %v", err)
+			return nil, err
+		}
+		medias = append(medias, &media)
+	}
+	return medias, nil
+}
+
+// FindChangedSince returns the rule slug and alias of the medias updated or deleted after since.
+func (u *MediaRepository) FindChangedSince(since time.Time) ([]*entity.Media, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"updated_at": bson.M{"$gt": since}},
+		bson.M{"deleted_at": bson.M{"$gt": since}},
+	}}
+	return u.findCacheKeys(filter)
+}
+
+// FindAllByRule returns the rule slug and alias of the medias of a rule.
+func (u *MediaRepository) FindAllByRule(ruleSlug string) ([]*entity.Media, error) {
+	return u.findCacheKeys(bson.M{"rule_slug": ruleSlug, "deleted_at": nil})
+}
+
+func (u *MediaRepository) findCacheKeys(filter bson.M) ([]*entity.Media, error) {
+	ctx := context.TODO()
+	medias := make([]*entity.Media, 0)
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "rule_slug": 1, "file_alias_name": 1})
+	cur, err := u.db.Collection(entity.Media{}.GetCollName()).Find(ctx, filter, opts)
+	if err != nil {
+		log.Printf("Error finding medias: %v", err)
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var media entity.Media
+		err := cur.Decode(&media)
+		if err != nil {
+			log.Printf("Error decoding media: %v", err)
+			return nil, err
+		}
+		medias = append(medias, &media)
+	}
+	return medias, nil
+}
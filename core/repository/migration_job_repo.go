@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	go_cache "github.com/sibeur/go-cache"
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MigrationJobRepository struct {
+	db    *mongo.Database
+	cache go_cache.Cache
+}
+
+func NewMigrationJobRepository(db *mongo.Database, cache go_cache.Cache) *MigrationJobRepository {
+	return &MigrationJobRepository{db: db, cache: cache}
+}
+
+func (u *MigrationJobRepository) FindAll() ([]*entity.MigrationJob, error) {
+	return u.find(bson.M{})
+}
+
+func (u *MigrationJobRepository) FindByStatus(status string) ([]*entity.MigrationJob, error) {
+	return u.find(bson.M{"status": status})
+}
+
+func (u *MigrationJobRepository) find(filter bson.M) ([]*entity.MigrationJob, error) {
+	ctx := context.TODO()
+	jobs := make([]*entity.MigrationJob, 0)
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	cur, err := u.db.Collection(entity.MigrationJob{}.GetCollName()).Find(ctx, filter, opts)
+	if err != nil {
+		log.Printf("Error finding migration jobs: %v", err)
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var job entity.MigrationJob
+		err := cur.Decode(&job)
+		if err != nil {
+			log.Printf("Error decoding migration job: %v", err)
+			return nil, err
+		}
+		jobs = append(jobs, &job)
+	}
+	return jobs, nil
+}
+
+func (u *MigrationJobRepository) FindByID(id string) (*entity.MigrationJob, error) {
+	var job entity.MigrationJob
+	err := u.db.Collection(job.GetCollName()).FindOne(context.TODO(), bson.M{"_id": id}).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (u *MigrationJobRepository) Create(job *entity.MigrationJob) error {
+	job.ID = uuid.NewString()
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = time.Now()
+	_, err := u.db.Collection(entity.MigrationJob{}.GetCollName()).InsertOne(context.TODO(), job)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Claim makes owner the instance running a job, when the job can move to running or was left
+// running by an instance whose lease expired. It returns nil when the job can not be claimed, e.g.
+// another instance claimed it first.
+func (u *MigrationJobRepository) Claim(job *entity.MigrationJob, owner string) (*entity.MigrationJob, error) {
+	now := time.Now()
+	filter := bson.M{"_id": job.ID, "$or": bson.A{
+		bson.M{"status": bson.M{"$in": common.GetMigrationJobStatusesTo(common.MigrationJobStatusRunning)}},
+		bson.M{"status": common.MigrationJobStatusRunning, "$or": bson.A{
+			bson.M{"lease_expired_at": bson.M{"$exists": false}},
+			bson.M{"lease_expired_at": bson.M{"$lt": now}},
+		}},
+	}}
+	data := bson.M{"$set": bson.M{
+		"updated_at":       now,
+		"status":           common.MigrationJobStatusRunning,
+		"started_at":       job.StartedAt,
+		"last_error":       "",
+		"owner":            owner,
+		"lease_expired_at": now.Add(common.MigrationJobLease),
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var claimedJob entity.MigrationJob
+	err := u.db.Collection(job.GetCollName()).FindOneAndUpdate(context.TODO(), filter, data, opts).Decode(&claimedJob)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &claimedJob, nil
+}
+
+// RenewLease extends the lease of a running job owned by an instance. It tells whether the instance
+// still owns the job, it does not once the job was paused, cancelled or taken over.
+func (u *MigrationJobRepository) RenewLease(job *entity.MigrationJob) (bool, error) {
+	filter := bson.M{"_id": job.ID, "owner": job.Owner, "status": common.MigrationJobStatusRunning}
+	data := bson.M{"$set": bson.M{"lease_expired_at": time.Now().Add(common.MigrationJobLease)}}
+	result, err := u.db.Collection(job.GetCollName()).UpdateOne(context.TODO(), filter, data)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// UpdateProgress stores the counters and cursor of a running job owned by the instance without
+// touching its status, so a pause or cancel requested meanwhile is not overwritten by the running
+// worker. It tells whether the instance still owns the job.
+func (u *MigrationJobRepository) UpdateProgress(job *entity.MigrationJob) (bool, error) {
+	job.UpdatedAt = time.Now()
+	data := bson.M{"$set": bson.M{
+		"updated_at":    job.UpdatedAt,
+		"total":         job.Total,
+		"processed":     job.Processed,
+		"migrated":      job.Migrated,
+		"failed":        job.Failed,
+		"last_media_id": job.LastMediaID,
+		"last_error":    job.LastError,
+		"failures":      job.Failures,
+	}}
+	filter := bson.M{"_id": job.ID, "owner": job.Owner, "status": common.MigrationJobStatusRunning}
+	result, err := u.db.Collection(entity.MigrationJob{}.GetCollName()).UpdateOne(context.TODO(), filter, data)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// UpdateStatus moves a job out of running to its status, only when the job is still in a status it
+// can move from and, when owner is set, still owned by that instance. It tells whether the job moved.
+func (u *MigrationJobRepository) UpdateStatus(job *entity.MigrationJob, owner string) (bool, error) {
+	job.UpdatedAt = time.Now()
+	filter := bson.M{"_id": job.ID, "status": bson.M{"$in": common.GetMigrationJobStatusesTo(job.Status)}}
+	if owner != "" {
+		filter["owner"] = owner
+	}
+	data := bson.M{
+		"$set": bson.M{
+			"updated_at":  job.UpdatedAt,
+			"status":      job.Status,
+			"finished_at": job.FinishedAt,
+			"last_error":  job.LastError,
+		},
+		"$unset": bson.M{"owner": "", "lease_expired_at": ""},
+	}
+	result, err := u.db.Collection(entity.MigrationJob{}.GetCollName()).UpdateOne(context.TODO(), filter, data)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sibeur/gotaro/core/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PersonalAccessTokenRepositoryUseCase interface {
+	FindAllByClientID(clientID string) ([]*entity.PersonalAccessToken, error)
+	FindByPrefix(prefix string) (*entity.PersonalAccessToken, error)
+	FindByID(clientID string, id string) (*entity.PersonalAccessToken, error)
+	Create(token *entity.PersonalAccessToken) error
+	UpdateLastUsedAt(id string, lastUsedAt time.Time) error
+	Delete(id string) error
+}
+
+type PersonalAccessTokenRepository struct {
+	db *mongo.Database
+}
+
+func NewPersonalAccessTokenRepository(db *mongo.Database) *PersonalAccessTokenRepository {
+	return &PersonalAccessTokenRepository{db: db}
+}
+
+func (r *PersonalAccessTokenRepository) FindAllByClientID(clientID string) ([]*entity.PersonalAccessToken, error) {
+	ctx := context.TODO()
+	tokens := make([]*entity.PersonalAccessToken, 0)
+	opts := options.Find().SetSort(bson.M{"created_at": 1})
+	cur, err := r.db.Collection(entity.PersonalAccessToken{}.GetCollName()).Find(ctx, bson.M{"client_id": clientID, "deleted_at": nil}, opts)
+	if err != nil {
+		log.Printf("Error finding personal access tokens: %v", err)
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var token entity.PersonalAccessToken
+		err := cur.Decode(&token)
+		if err != nil {
+			log.Printf("Error decoding personal access token: %v", err)
+			return nil, err
+		}
+		tokens = append(tokens, &token)
+	}
+	return tokens, nil
+}
+
+func (r *PersonalAccessTokenRepository) FindByPrefix(prefix string) (*entity.PersonalAccessToken, error) {
+	var token entity.PersonalAccessToken
+	err := r.db.Collection(token.GetCollName()).FindOne(context.TODO(), bson.M{"prefix": prefix, "deleted_at": nil}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *PersonalAccessTokenRepository) FindByID(clientID string, id string) (*entity.PersonalAccessToken, error) {
+	var token entity.PersonalAccessToken
+	err := r.db.Collection(token.GetCollName()).FindOne(context.TODO(), bson.M{"_id": id, "client_id": clientID, "deleted_at": nil}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *PersonalAccessTokenRepository) Create(token *entity.PersonalAccessToken) error {
+	token.ID = uuid.NewString()
+	token.CreatedAt = time.Now()
+	token.UpdatedAt = time.Now()
+	_, err := r.db.Collection(entity.PersonalAccessToken{}.GetCollName()).InsertOne(context.TODO(), token)
+	return err
+}
+
+func (r *PersonalAccessTokenRepository) UpdateLastUsedAt(id string, lastUsedAt time.Time) error {
+	filter := bson.M{"_id": id, "deleted_at": nil}
+	data := bson.M{"$set": bson.M{"last_used_at": lastUsedAt}}
+	_, err := r.db.Collection(entity.PersonalAccessToken{}.GetCollName()).UpdateOne(context.TODO(), filter, data)
+	return err
+}
+
+func (r *PersonalAccessTokenRepository) Delete(id string) error {
+	filter := bson.M{"_id": id, "deleted_at": nil}
+	data := bson.M{"$set": bson.M{"deleted_at": time.Now()}}
+	_, err := r.db.Collection(entity.PersonalAccessToken{}.GetCollName()).UpdateOne(context.TODO(), filter, data)
+	return err
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sibeur/gotaro/core/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RateLimitRepositoryUseCase interface {
+	CreateIndexes() error
+	Increment(key string, value uint64, ttl uint64) (uint64, error)
+	Decrement(key string, value uint64) error
+	Reset(key string) error
+	GetCount(key string) (uint64, error)
+}
+
+// RateLimitRepository keeps the request, login and upload counters in the database. A counter is a
+// document changed with $inc only, so the limits hold across the instances.
+type RateLimitRepository struct {
+	db *mongo.Database
+}
+
+func NewRateLimitRepository(db *mongo.Database) *RateLimitRepository {
+	return &RateLimitRepository{db: db}
+}
+
+// CreateIndexes creates the index dropping the expired counters.
+func (r *RateLimitRepository) CreateIndexes() error {
+	_, err := r.db.Collection(entity.RateLimitCounter{}.GetCollName()).Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"expired_at": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// Increment adds value to a counter and returns its new total. A missing or expired counter starts
+// again, expiring after ttl seconds.
+func (r *RateLimitRepository) Increment(key string, value uint64, ttl uint64) (uint64, error) {
+	ctx := context.TODO()
+	now := time.Now()
+	coll := r.db.Collection(entity.RateLimitCounter{}.GetCollName())
+	filter := bson.M{"_id": key, "expired_at": bson.M{"$gt": now}}
+	update := bson.M{
+		"$inc":         bson.M{"count": int64(value)},
+		"$setOnInsert": bson.M{"expired_at": now.Add(time.Second * time.Duration(ttl))},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter entity.RateLimitCounter
+	err := coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&counter)
+	if mongo.IsDuplicateKeyError(err) {
+		// the counter expired but was not dropped yet
+		if _, err := coll.DeleteOne(ctx, bson.M{"_id": key, "expired_at": bson.M{"$lte": now}}); err != nil {
+			return 0, err
+		}
+		err = coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&counter)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return uint64(max(counter.Count, 0)), nil
+}
+
+// Decrement takes value back from a counter, an expired counter is left as it is.
+func (r *RateLimitRepository) Decrement(key string, value uint64) error {
+	filter := bson.M{"_id": key, "expired_at": bson.M{"$gt": time.Now()}}
+	update := bson.M{"$inc": bson.M{"count": -int64(value)}}
+	_, err := r.db.Collection(entity.RateLimitCounter{}.GetCollName()).UpdateOne(context.TODO(), filter, update)
+	return err
+}
+
+func (r *RateLimitRepository) Reset(key string) error {
+	_, err := r.db.Collection(entity.RateLimitCounter{}.GetCollName()).DeleteOne(context.TODO(), bson.M{"_id": key})
+	return err
+}
+
+func (r *RateLimitRepository) GetCount(key string) (uint64, error) {
+	var counter entity.RateLimitCounter
+	filter := bson.M{"_id": key, "expired_at": bson.M{"$gt": time.Now()}}
+	err := r.db.Collection(counter.GetCollName()).FindOne(context.TODO(), filter).Decode(&counter)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return uint64(max(counter.Count, 0)), nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	go_cache "github.com/sibeur/go-cache"
+	"github.com/sibeur/gotaro/core/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ReconciliationJobRepository struct {
+	db    *mongo.Database
+	cache go_cache.Cache
+}
+
+func NewReconciliationJobRepository(db *mongo.Database, cache go_cache.Cache) *ReconciliationJobRepository {
+	return &ReconciliationJobRepository{db: db, cache: cache}
+}
+
+func (u *ReconciliationJobRepository) FindByDriver(driverSlug string) ([]*entity.ReconciliationJob, error) {
+	ctx := context.TODO()
+	jobs := make([]*entity.ReconciliationJob, 0)
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	cur, err := u.db.Collection(entity.ReconciliationJob{}.GetCollName()).Find(ctx, bson.M{"driver_slug": driverSlug}, opts)
+	if err != nil {
+		log.Printf("Error finding reconciliation jobs: %v", err)
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var job entity.ReconciliationJob
+		err := cur.Decode(&job)
+		if err != nil {
+			log.Printf("Error decoding reconciliation job: %v", err)
+			return nil, err
+		}
+		jobs = append(jobs, &job)
+	}
+	return jobs, nil
+}
+
+func (u *ReconciliationJobRepository) FindByID(id string) (*entity.ReconciliationJob, error) {
+	var job entity.ReconciliationJob
+	err := u.db.Collection(job.GetCollName()).FindOne(context.TODO(), bson.M{"_id": id}).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (u *ReconciliationJobRepository) Create(job *entity.ReconciliationJob) error {
+	job.ID = uuid.NewString()
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = time.Now()
+	_, err := u.db.Collection(entity.ReconciliationJob{}.GetCollName()).InsertOne(context.TODO(), job)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (u *ReconciliationJobRepository) Update(job *entity.ReconciliationJob) error {
+	job.UpdatedAt = time.Now()
+	_, err := u.db.Collection(entity.ReconciliationJob{}.GetCollName()).ReplaceOne(context.TODO(), bson.M{"_id": job.ID}, job)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -2,24 +2,40 @@ package repository
 
 import (
 	go_cache "github.com/sibeur/go-cache"
+	"github.com/sibeur/gotaro/core/common/oidc"
+	"github.com/sibeur/gotaro/core/common/signing"
 
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type Repository struct {
-	Driver    *DriverRepository
-	Rule      *RuleRepository
-	Media     *MediaRepository
-	APIClient *ApiClientRepository
-	Auth      *AuthRepository
+	Driver              *DriverRepository
+	Rule                *RuleRepository
+	Media               *MediaRepository
+	APIClient           ApiClientRepositoryUseCase
+	Role                *RoleRepository
+	PersonalAccessToken PersonalAccessTokenRepositoryUseCase
+	AuditEvent          *AuditEventRepository
+	Auth                AuthRepositoryUseCase
+	RateLimit           RateLimitRepositoryUseCase
+	MigrationJob        *MigrationJobRepository
+	ReconciliationJob   *ReconciliationJobRepository
+	ChangeStream        *ChangeStreamRepository
 }
 
-func NewRepository(mongoDB *mongo.Database, cache go_cache.Cache) *Repository {
+func NewRepository(mongoDB *mongo.Database, cache go_cache.Cache, keySet *signing.KeySet, oidcVerifier *oidc.Verifier) *Repository {
 	return &Repository{
-		Driver:    NewDriverRepository(mongoDB, cache),
-		Rule:      NewRuleRepository(mongoDB, cache),
-		Media:     NewMediaRepository(mongoDB, cache),
-		APIClient: NewApiClientRepository(mongoDB, cache),
-		Auth:      NewAuthRepository(cache),
+		Driver:              NewDriverRepository(mongoDB, cache),
+		Rule:                NewRuleRepository(mongoDB, cache),
+		Media:               NewMediaRepository(mongoDB, cache),
+		APIClient:           NewApiClientRepository(mongoDB, cache),
+		Role:                NewRoleRepository(mongoDB, cache),
+		PersonalAccessToken: NewPersonalAccessTokenRepository(mongoDB),
+		AuditEvent:          NewAuditEventRepository(mongoDB),
+		Auth:                NewAuthRepository(mongoDB, cache, keySet, oidcVerifier),
+		RateLimit:           NewRateLimitRepository(mongoDB),
+		MigrationJob:        NewMigrationJobRepository(mongoDB, cache),
+		ReconciliationJob:   NewReconciliationJobRepository(mongoDB, cache),
+		ChangeStream:        NewChangeStreamRepository(mongoDB),
 	}
 }
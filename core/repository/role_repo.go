@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	go_cache "github.com/sibeur/go-cache"
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RoleRepository struct {
+	db    *mongo.Database
+	cache go_cache.Cache
+}
+
+func NewRoleRepository(db *mongo.Database, cache go_cache.Cache) *RoleRepository {
+	return &RoleRepository{db: db, cache: cache}
+}
+
+func (r *RoleRepository) FindAll() ([]*entity.Role, error) {
+	ctx := context.TODO()
+	roles := make([]*entity.Role, 0)
+	opts := options.Find().SetSort(bson.M{"slug": 1})
+	cur, err := r.db.Collection(entity.Role{}.GetCollName()).Find(ctx, bson.M{"deleted_at": nil}, opts)
+	if err != nil {
+		log.Printf("Error finding roles: %v", err)
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var role entity.Role
+		err := cur.Decode(&role)
+		if err != nil {
+			log.Printf("Error decoding role: %v", err)
+			return nil, err
+		}
+		roles = append(roles, &role)
+	}
+	return roles, nil
+}
+
+func (r *RoleRepository) FindBySlug(slug string) (*entity.Role, error) {
+	var role entity.Role
+	err := r.db.Collection(role.GetCollName()).FindOne(context.TODO(), bson.M{"slug": slug, "deleted_at": nil}).Decode(&role)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *RoleRepository) Create(role *entity.Role) error {
+	role.ID = uuid.NewString()
+	role.CreatedAt = time.Now()
+	role.UpdatedAt = time.Now()
+	_, err := r.db.Collection(entity.Role{}.GetCollName()).InsertOne(context.TODO(), role)
+	if err != nil {
+		return err
+	}
+	r.DeleteCachedPermissions(role.Slug)
+	return nil
+}
+
+// Update stores the name and permissions of a role.
+func (r *RoleRepository) Update(role *entity.Role) error {
+	role.UpdatedAt = time.Now()
+	filter := bson.M{"slug": role.Slug, "deleted_at": nil}
+	data := bson.M{"$set": bson.M{
+		"updated_at":  role.UpdatedAt,
+		"name":        role.Name,
+		"permissions": role.Permissions,
+	}}
+	_, err := r.db.Collection(entity.Role{}.GetCollName()).UpdateOne(context.TODO(), filter, data)
+	if err != nil {
+		return err
+	}
+	r.DeleteCachedPermissions(role.Slug)
+	return nil
+}
+
+func (r *RoleRepository) Delete(slug string) error {
+	filter := bson.M{"slug": slug, "deleted_at": nil}
+	data := bson.M{"$set": bson.M{"deleted_at": time.Now()}}
+	_, err := r.db.Collection(entity.Role{}.GetCollName()).UpdateOne(context.TODO(), filter, data)
+	if err != nil {
+		return err
+	}
+	r.DeleteCachedPermissions(slug)
+	return nil
+}
+
+// FindPermissions returns the permissions of a role, none when it does not exist. The permissions
+// are checked on every request, they are cached briefly and dropped whenever the role changes.
+func (r *RoleRepository) FindPermissions(slug string) (common.Permissions, error) {
+	key := fmt.Sprintf(common.CacheRolePermissionsKey, slug)
+	cachedValue, _ := r.cache.Get(key)
+	if cachedValue != "" {
+		var permissions common.Permissions
+		if err := json.Unmarshal([]byte(cachedValue), &permissions); err == nil {
+			return permissions, nil
+		}
+	}
+
+	role, err := r.FindBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := common.Permissions{}
+	if role != nil {
+		permissions = role.Permissions
+	}
+	encodedPermissions, _ := json.Marshal(permissions)
+	if err := r.cache.SetWithExpire(key, string(encodedPermissions), common.DefaultRolePermissionsCacheTTL); err != nil {
+		log.Printf("Error set role permissions to cache: %v", err)
+	}
+	return permissions, nil
+}
+
+func (r *RoleRepository) DeleteCachedPermissions(slug string) {
+	if err := r.cache.Delete(fmt.Sprintf(common.CacheRolePermissionsKey, slug)); err != nil {
+		log.Printf("Error delete role permissions from cache: %v", err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type RuleRepository struct {
@@ -54,10 +55,22 @@ func (u *RuleRepository) Create(rule *entity.Rule) error {
 	return nil
 }
 
+// Update replaces the rule, the optional settings left empty are removed.
 func (u *RuleRepository) Update(rule *entity.Rule) error {
 	rule.UpdatedAt = time.Now()
 	filter := bson.M{"slug": rule.Slug, "deleted_at": nil}
-	_, err := u.db.Collection(entity.Rule{}.GetCollName()).UpdateOne(context.TODO(), filter, bson.M{"$set": rule})
+	update := bson.M{"$set": rule}
+	unset := bson.M{}
+	if rule.ReplicationPolicy == "" {
+		unset["replication_policy"] = ""
+	}
+	if rule.ObjectOptions == nil {
+		unset["object_options"] = ""
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	_, err := u.db.Collection(entity.Rule{}.GetCollName()).UpdateOne(context.TODO(), filter, update)
 	if err != nil {
 		return err
 	}
@@ -85,3 +98,30 @@ func (u *RuleRepository) FindBySlug(slug string) (*entity.Rule, error) {
 	}
 	return &rule, nil
 }
+
+// FindChangedSince returns the slug of the rules updated or deleted after since.
+func (u *RuleRepository) FindChangedSince(since time.Time) ([]*entity.Rule, error) {
+	ctx := context.TODO()
+	rules := make([]*entity.Rule, 0)
+	filter := bson.M{"$or": bson.A{
+		bson.M{"updated_at": bson.M{"$gt": since}},
+		bson.M{"deleted_at": bson.M{"$gt": since}},
+	}}
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "slug": 1})
+	cur, err := u.db.Collection(entity.Rule{}.GetCollName()).Find(ctx, filter, opts)
+	if err != nil {
+		log.Printf("Error finding rules: %v", err)
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var rule entity.Rule
+		err := cur.Decode(&rule)
+		if err != nil {
+			log.Printf("Error decoding rule: %v", err)
+			return nil, err
+		}
+		rules = append(rules, &rule)
+	}
+	return rules, nil
+}
@@ -2,19 +2,25 @@ package service
 
 import (
 	"errors"
+	"os"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/common/secret"
 	"github.com/sibeur/gotaro/core/entity"
 	"github.com/sibeur/gotaro/core/repository"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type ApiClientService struct {
-	repo *repository.Repository
+	repo    *repository.Repository
+	keyring *secret.Keyring
 }
 
-func NewApiClientService(repo *repository.Repository) *ApiClientService {
-	return &ApiClientService{repo: repo}
+func NewApiClientService(repo *repository.Repository, keyring *secret.Keyring) *ApiClientService {
+	return &ApiClientService{repo: repo, keyring: keyring}
 }
 
 func (u *ApiClientService) GenerateFirstSuperAdmin() (string, string, error) {
@@ -26,28 +32,215 @@ func (u *ApiClientService) GenerateFirstSuperAdmin() (string, string, error) {
 		return client.Key, "", errors.New(common.ErrAPIClientAlreadyExistMsg)
 	}
 
-	apikey := common.RandomString(16)
-	secretKey := common.RandomString(32)
+	client = &entity.APIClient{
+		Scopes: []string{common.APIClientSuperAdminScope},
+	}
+
+	secretKey, err := u.Create(client)
+	if err != nil {
+		return "", "", err
+	}
+
+	return client.Key, secretKey, nil
+}
+
+func (u *ApiClientService) FindAll() ([]*entity.APIClient, error) {
+	return u.repo.APIClient.FindAll()
+}
+
+// Create generates the key and secret of a new client and returns the secret, which is only stored
+// hashed and can not be read again.
+func (u *ApiClientService) Create(client *entity.APIClient) (string, error) {
+	if err := u.validateScopes(client.Scopes); err != nil {
+		return "", err
+	}
+
+	secretKey, err := common.SecureRandomString(32)
+	if err != nil {
+		return "", err
+	}
 
 	// hashedSecretKey with bcrypt
 	hashedSecretKey, err := bcrypt.GenerateFromPassword([]byte(secretKey), bcrypt.DefaultCost)
 
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
 
-	client = &entity.APIClient{
-		Key:    apikey,
-		Secret: string(hashedSecretKey),
-		Scopes: []string{common.APIClientSuperAdminScope},
+	client.Key, err = common.SecureRandomString(16)
+	if err != nil {
+		return "", err
 	}
+	client.Secret = string(hashedSecretKey)
+	client.SecretCreatedAt = time.Now()
 
 	err = u.repo.APIClient.Create(client)
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
 
-	return apikey, secretKey, nil
+	return secretKey, nil
+}
+
+// Update replaces the name and scopes of a client.
+func (u *ApiClientService) Update(client *entity.APIClient) error {
+	existingClient, err := u.repo.APIClient.FindByID(client.ID)
+	if err != nil {
+		return err
+	}
+	if existingClient == nil {
+		return errors.New(common.ErrAPIClientNotFoundMsg)
+	}
+	if err := u.validateScopes(client.Scopes); err != nil {
+		return err
+	}
+	if !slices.Contains(client.Scopes, common.APIClientSuperAdminScope) {
+		if err := u.checkLastSuperAdmin(existingClient); err != nil {
+			return err
+		}
+	}
+
+	client.Key = existingClient.Key
+	client.IsDisabled = existingClient.IsDisabled
+	client.TokensValidAfter = existingClient.TokensValidAfter
+	client.CreatedAt = existingClient.CreatedAt
+	return u.repo.APIClient.Update(client)
+}
+
+// SetDisabled disables or enables a client, a disabled client can not log in.
+func (u *ApiClientService) SetDisabled(id string, isDisabled bool) (*entity.APIClient, error) {
+	client, err := u.repo.APIClient.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, errors.New(common.ErrAPIClientNotFoundMsg)
+	}
+
+	if isDisabled {
+		if err := u.checkLastSuperAdmin(client); err != nil {
+			return nil, err
+		}
+	}
+
+	client.IsDisabled = isDisabled
+	// tokens issued before disabling stay revoked once enabled again
+	if isDisabled {
+		client.TokensValidAfter = time.Now()
+	}
+	if err := u.repo.APIClient.Update(client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// RotateSecret issues a new secret for a client and returns it. The previous secret keeps working
+// for the overlap so the services using it can switch without an outage, a nil overlap uses the
+// configured one.
+func (u *ApiClientService) RotateSecret(id string, overlap *time.Duration) (*entity.APIClient, string, error) {
+	client, err := u.repo.APIClient.FindByID(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if client == nil {
+		return nil, "", errors.New(common.ErrAPIClientNotFoundMsg)
+	}
+
+	secretKey, err := common.SecureRandomString(32)
+	if err != nil {
+		return nil, "", err
+	}
+	hashedSecretKey, err := bcrypt.GenerateFromPassword([]byte(secretKey), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if overlap == nil {
+		defaultOverlap := getSecretOverlap()
+		overlap = &defaultOverlap
+	}
+
+	now := time.Now()
+	previousSecret := client.Secret
+	client.SecretHistory = append(client.GetActiveSecretHistory(), entity.APIClientSecret{
+		Secret:    client.Secret,
+		CreatedAt: client.GetSecretCreatedAt(),
+		RotatedAt: now,
+		ExpiredAt: now.Add(*overlap),
+	})
+	client.Secret = string(hashedSecretKey)
+	client.SecretCreatedAt = now
+
+	isUpdated, err := u.repo.APIClient.UpdateSecret(client, previousSecret)
+	if err != nil {
+		return nil, "", err
+	}
+	if !isUpdated {
+		return nil, "", errors.New(common.ErrAPIClientSecretRotateMsg)
+	}
+	return client, secretKey, nil
+}
+
+// RotateSigningSecret issues a new secret signing the requests of a client and returns it, the
+// previous one stops working at once. It is stored encrypted like the driver secrets, or plain when
+// no master key is set and plaintext secrets are allowed.
+func (u *ApiClientService) RotateSigningSecret(id string) (*entity.APIClient, string, error) {
+	client, err := u.repo.APIClient.FindByID(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if client == nil {
+		return nil, "", errors.New(common.ErrAPIClientNotFoundMsg)
+	}
+
+	signingSecret, err := common.SecureRandomString(48)
+	if err != nil {
+		return nil, "", err
+	}
+	client.SigningSecret = signingSecret
+	if u.keyring.IsEnabled() {
+		client.SigningSecret, err = u.keyring.Encrypt([]byte(signingSecret))
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	client.SigningSecretCreatedAt = time.Now()
+
+	if err := u.repo.APIClient.UpdateSigningSecret(client); err != nil {
+		return nil, "", err
+	}
+	return client, signingSecret, nil
+}
+
+// RevokeTokens revokes every access and refresh token issued to a client so far.
+func (u *ApiClientService) RevokeTokens(id string) (*entity.APIClient, error) {
+	client, err := u.repo.APIClient.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, errors.New(common.ErrAPIClientNotFoundMsg)
+	}
+
+	client.TokensValidAfter = time.Now()
+	if err := u.repo.APIClient.Update(client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (u *ApiClientService) Delete(id string) error {
+	client, err := u.repo.APIClient.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return errors.New(common.ErrAPIClientNotFoundMsg)
+	}
+	if err := u.checkLastSuperAdmin(client); err != nil {
+		return err
+	}
+	return u.repo.APIClient.Delete(id)
 }
 
 func (u *ApiClientService) FindByID(id string) (*entity.APIClient, error) {
@@ -57,3 +250,47 @@ func (u *ApiClientService) FindByID(id string) (*entity.APIClient, error) {
 func (u *ApiClientService) FindByKey(key string) (*entity.APIClient, error) {
 	return u.repo.APIClient.FindByKey(key)
 }
+
+func getSecretOverlap() time.Duration {
+	overlap := common.DefaultAPIClientSecretOverlap
+	if os.Getenv("API_CLIENT_SECRET_OVERLAP_MINUTES") != "" {
+		minutes, err := strconv.Atoi(os.Getenv("API_CLIENT_SECRET_OVERLAP_MINUTES"))
+		if err == nil && minutes >= 0 {
+			overlap = time.Minute * time.Duration(minutes)
+		}
+	}
+	return overlap
+}
+
+// checkLastSuperAdmin refuses to take the super admin away from the last client having it, nobody
+// could manage the clients anymore.
+func (u *ApiClientService) checkLastSuperAdmin(client *entity.APIClient) error {
+	if client.IsDisabled || !slices.Contains(client.Scopes, common.APIClientSuperAdminScope) {
+		return nil
+	}
+	count, err := u.repo.APIClient.CountActiveByScope(common.APIClientSuperAdminScope, client.ID)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New(common.ErrAPIClientLastAdminMsg)
+	}
+	return nil
+}
+
+// validateScopes checks every scope of a client is a built-in or custom role.
+func (u *ApiClientService) validateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if _, isBuiltin := common.BuiltinRolePermissions[scope]; isBuiltin {
+			continue
+		}
+		role, err := u.repo.Role.FindBySlug(scope)
+		if err != nil {
+			return err
+		}
+		if role == nil {
+			return errors.New(common.ErrRoleNotFoundMsg)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/repository"
+)
+
+type AuditService struct {
+	repo *repository.Repository
+}
+
+var auditEventCSVHeader = []string{"id", "created_at", "action", "resource_type", "resource_id", "actor_client_id", "actor_ip", "request_id", "changes", "details"}
+
+func NewAuditService(repo *repository.Repository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Record stores an audit event with the changes between two states of its resource, before is nil
+// for a creation and after for a deletion. A failure is only logged, the action is already done.
+func (u *AuditService) Record(event *entity.AuditEvent, before common.GotaroMap, after common.GotaroMap) {
+	event.Changes = common.GetAuditChanges(before, after)
+	if err := u.repo.AuditEvent.Create(event); err != nil {
+		log.Printf("Error recording audit event %v of %v %v: %v", event.Action, event.ResourceType, event.ResourceID, err)
+	}
+}
+
+// RecordLogin stores a login attempt with the api key it was made with, the client is the one of
+// the key when it exists.
+func (u *AuditService) RecordLogin(event *entity.AuditEvent, apiKey string) {
+	event.ResourceType = common.AuditResourceAPIClient
+	event.Details = common.GotaroMap{"api_key": apiKey}
+
+	apiClient, err := u.repo.APIClient.FindByKey(apiKey)
+	if err != nil {
+		log.Printf("Error finding api client of login: %v", err)
+	}
+	if apiClient != nil {
+		event.ActorClientID = apiClient.ID
+		event.ResourceID = apiClient.ID
+	}
+	u.Record(event, nil, nil)
+}
+
+// FindAll returns the latest events matching a filter, limit is bound to MaxAuditEventsLimit.
+func (u *AuditService) FindAll(filter *entity.AuditEventFilter, limit int64) ([]*entity.AuditEvent, error) {
+	if limit <= 0 {
+		limit = common.DefaultAuditEventsLimit
+	}
+	return u.repo.AuditEvent.FindAll(filter, min(limit, common.MaxAuditEventsLimit))
+}
+
+// Export writes every event matching a filter as CSV or newline delimited JSON.
+func (u *AuditService) Export(filter *entity.AuditEventFilter, format string, w io.Writer) error {
+	switch format {
+	case common.AuditExportFormatNDJSON:
+		encoder := json.NewEncoder(w)
+		return u.repo.AuditEvent.ForEach(filter, func(event *entity.AuditEvent) error {
+			return encoder.Encode(event.ToJSON())
+		})
+	case common.AuditExportFormatCSV:
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write(auditEventCSVHeader); err != nil {
+			return err
+		}
+		err := u.repo.AuditEvent.ForEach(filter, func(event *entity.AuditEvent) error {
+			changes, _ := json.Marshal(event.Changes)
+			details, _ := json.Marshal(event.Details)
+			return csvWriter.Write([]string{
+				event.ID,
+				event.CreatedAt.Format(time.RFC3339Nano),
+				event.Action,
+				event.ResourceType,
+				event.ResourceID,
+				event.ActorClientID,
+				event.ActorIP,
+				event.RequestID,
+				string(changes),
+				string(details),
+			})
+		})
+		if err != nil {
+			return err
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	return errors.New(common.ErrAuditExportFormatInvalidMsg)
+}
@@ -1,32 +1,45 @@
 package service
 
 import (
+	"crypto/subtle"
 	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/common/oidc"
+	"github.com/sibeur/gotaro/core/common/secret"
+	"github.com/sibeur/gotaro/core/common/signing"
 	"github.com/sibeur/gotaro/core/entity"
 	"github.com/sibeur/gotaro/core/repository"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService struct {
-	repo *repository.Repository
+	repo    *repository.Repository
+	keyring *secret.Keyring
 }
 
-func NewAuthService(repo *repository.Repository) *AuthService {
-	return &AuthService{repo: repo}
+func NewAuthService(repo *repository.Repository, keyring *secret.Keyring) *AuthService {
+	return &AuthService{repo: repo, keyring: keyring}
 }
 
-func (u *AuthService) Login(apiKey string, secretKey string) (*entity.Auth, error) {
-	apiClient, err := u.repo.APIClient.FindByKey(apiKey)
+// Login exchanges the key and secret of a client for a token pair. The failed logins of a key or an
+// IP delay their next attempt, then lock them out, the same way whether the key exists or not.
+func (u *AuthService) Login(apiKey string, secretKey string, ip string) (*entity.Auth, error) {
+	apiClient, err := u.verifyClientSecret(apiKey, secretKey, getLoginSubjects(apiKey, ip))
 	if err != nil {
-		return nil, errors.New(common.ErrAuthenticationFailedMsg)
-	}
-	if err := bcrypt.CompareHashAndPassword([]byte(apiClient.Secret), []byte(secretKey)); err != nil {
-		return nil, errors.New(common.ErrAuthenticationFailedMsg)
+		return nil, err
 	}
+	u.trackClientUse(apiClient)
 	auth, err := u.repo.Auth.CreateToken(apiClient.ID, apiClient.Scopes)
 	if err != nil {
 		return nil, err
@@ -34,8 +47,10 @@ func (u *AuthService) Login(apiKey string, secretKey string) (*entity.Auth, erro
 	return auth, nil
 }
 
+// RefreshToken exchanges a refresh token for a new token pair of the same family. A refresh token
+// can be used once, presenting it again means it leaked so the whole family is revoked.
 func (u *AuthService) RefreshToken(refreshToken string) (*entity.Auth, error) {
-	token, err := u.repo.Auth.ValidateToken(refreshToken)
+	token, err := u.ValidateToken(refreshToken)
 	if err != nil {
 		return nil, err
 	}
@@ -59,17 +74,384 @@ func (u *AuthService) RefreshToken(refreshToken string) (*entity.Auth, error) {
 		return nil, err
 	}
 
-	if apiClient == nil {
+	if apiClient == nil || apiClient.IsDisabled {
 		return nil, errors.New(common.ErrJWTTokenInvalidMsg)
 	}
 
-	auth, err := u.repo.Auth.CreateToken(subject, apiClient.Scopes)
+	tokenID := repository.GetTokenID(token)
+	familyID := repository.GetTokenFamilyID(token)
+	if familyID == "" {
+		// tokens issued before families start one, they can not be used again either
+		if err := u.repo.Auth.RevokeToken(token); err != nil {
+			return nil, err
+		}
+		return u.repo.Auth.CreateToken(subject, apiClient.Scopes)
+	}
+
+	auth, err := u.repo.Auth.RotateToken(subject, apiClient.Scopes, familyID, tokenID)
 	if err != nil {
 		return nil, err
 	}
-	return auth, nil
+	if auth != nil {
+		return auth, nil
+	}
+
+	// the token is not the current one of its family, it was used already unless the family is gone
+	family, err := u.repo.Auth.FindTokenFamily(familyID)
+	if err != nil {
+		return nil, err
+	}
+	if family == nil || family.IsRevoked || family.ClientID != subject {
+		return nil, errors.New(common.ErrJWTTokenInvalidMsg)
+	}
+	log.Printf("[RefreshToken] Refresh token %v of client %v was already used, revoking token family %v as it may be stolen", tokenID, subject, familyID)
+	if err := u.repo.Auth.RevokeTokenFamily(familyID); err != nil {
+		return nil, err
+	}
+	return nil, errors.New(common.ErrJWTTokenReusedMsg)
 }
 
+// ValidateToken parses a token and checks it was neither revoked nor issued to a client since
+// disabled, deleted or whose tokens were revoked.
 func (u *AuthService) ValidateToken(token string) (*jwt.Token, error) {
-	return u.repo.Auth.ValidateToken(token)
+	t, err := u.repo.Auth.ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.repo.Auth.IsTokenRevoked(t) {
+		return nil, errors.New(common.ErrJWTTokenRevokedMsg)
+	}
+	if familyID := repository.GetTokenFamilyID(t); familyID != "" && u.repo.Auth.IsTokenFamilyRevoked(familyID) {
+		return nil, errors.New(common.ErrJWTTokenRevokedMsg)
+	}
+
+	subject, err := t.Claims.GetSubject()
+	if err != nil {
+		return nil, err
+	}
+	issuedAt, err := t.Claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return nil, errors.New(common.ErrJWTTokenInvalidMsg)
+	}
+
+	validAfter, isActive, err := u.repo.APIClient.FindTokensValidAfter(subject)
+	if err != nil {
+		return nil, err
+	}
+	if !isActive || common.IsIssuedBeforeValidAfter(issuedAt.Time, validAfter) {
+		return nil, errors.New(common.ErrJWTTokenRevokedMsg)
+	}
+	return t, nil
+}
+
+// Logout revokes the access token of the request with its token family and, when given, the refresh
+// token of the same client.
+func (u *AuthService) Logout(accessToken *jwt.Token, refreshToken string) error {
+	if familyID := repository.GetTokenFamilyID(accessToken); familyID != "" {
+		if err := u.repo.Auth.RevokeTokenFamily(familyID); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken != "" {
+		token, err := u.ValidateToken(refreshToken)
+		if err != nil {
+			return errors.New(common.ErrJWTTokenInvalidMsg)
+		}
+
+		issuer, _ := token.Claims.GetIssuer()
+		subject, _ := token.Claims.GetSubject()
+		accessSubject, _ := accessToken.Claims.GetSubject()
+		if issuer != common.JWTIssuerRefreshToken || subject != accessSubject {
+			return errors.New(common.ErrJWTTokenInvalidMsg)
+		}
+
+		if err := u.repo.Auth.RevokeToken(token); err != nil {
+			return err
+		}
+	}
+
+	return u.repo.Auth.RevokeToken(accessToken)
+}
+
+// GetJWKS returns the public keys verifying the gotaro tokens as a JSON Web Key Set.
+func (u *AuthService) GetJWKS() common.GotaroMap {
+	return u.repo.Auth.GetJWKS()
+}
+
+// AuthenticateAPIKey authenticates a request carrying the key and secret of a client, for the callers
+// that can not go through the login. A pair is verified with bcrypt once a minute at most, the
+// failures count as failed logins of the IP only, anyone knowing a key could lock it out otherwise.
+func (u *AuthService) AuthenticateAPIKey(apiKey string, secretKey string, ip string) (*entity.APIClient, error) {
+	if secretKey == "" {
+		return nil, errors.New(common.ErrAuthenticationFailedMsg)
+	}
+	apiClient, err := u.repo.APIClient.FindByKey(apiKey)
+	if err == nil && apiClient != nil && !apiClient.IsDisabled && u.repo.APIClient.IsKeyVerified(apiClient, apiKey, secretKey) {
+		u.trackClientUse(apiClient)
+		return apiClient, nil
+	}
+
+	subjects := make(map[string]string)
+	if ip != "" {
+		subjects[common.LoginSubjectIP] = ip
+	}
+	apiClient, err = u.verifyClientSecret(apiKey, secretKey, subjects)
+	if err != nil {
+		return nil, err
+	}
+	u.repo.APIClient.SetKeyVerified(apiClient, apiKey, secretKey)
+	u.trackClientUse(apiClient)
+	return apiClient, nil
+}
+
+// verifyClientSecret returns the client of a key when the secret is valid and none of the login
+// subjects are locked out, the failures are counted on them. An unknown key costs a bcrypt
+// comparison as well, so the response time does not tell which keys exist.
+func (u *AuthService) verifyClientSecret(apiKey string, secretKey string, subjects map[string]string) (*entity.APIClient, error) {
+	if u.getLoginSubjectsRetryAfter(subjects) > 0 {
+		return nil, errors.New(common.ErrLoginLockedMsg)
+	}
+
+	apiClient, err := u.repo.APIClient.FindByKey(apiKey)
+	if err != nil {
+		log.Printf("Error finding api client by key: %v", err)
+		return nil, errors.New(common.ErrAuthenticationFailedMsg)
+	}
+	if apiClient == nil || apiClient.IsDisabled {
+		bcrypt.CompareHashAndPassword(getDummySecretHash(), []byte(secretKey))
+		u.recordLoginFailure(subjects)
+		return nil, errors.New(common.ErrAuthenticationFailedMsg)
+	}
+	if !apiClient.IsSecretValid(secretKey) {
+		u.recordLoginFailure(subjects)
+		return nil, errors.New(common.ErrAuthenticationFailedMsg)
+	}
+
+	if _, isKeyCounted := subjects[common.LoginSubjectKey]; isKeyCounted {
+		u.resetLoginFailures(apiKey)
+	}
+	return apiClient, nil
+}
+
+// GetLoginRetryAfter returns how long the logins with a key from an IP are refused, zero when they
+// are not.
+func (u *AuthService) GetLoginRetryAfter(apiKey string, ip string) time.Duration {
+	return u.getLoginSubjectsRetryAfter(getLoginSubjects(apiKey, ip))
+}
+
+func (u *AuthService) getLoginSubjectsRetryAfter(subjects map[string]string) time.Duration {
+	var retryAfter time.Duration
+	for subjectType, subject := range subjects {
+		if lockedUntil := u.repo.Auth.GetLoginLock(subjectType, subject); !lockedUntil.IsZero() {
+			retryAfter = max(retryAfter, time.Until(lockedUntil))
+		}
+	}
+	return retryAfter
+}
+
+// UnlockLogin forgets the failed logins of a key and, when given, of an IP.
+func (u *AuthService) UnlockLogin(apiKey string, ip string) error {
+	for subjectType, subject := range getLoginSubjects(apiKey, ip) {
+		if err := u.repo.RateLimit.Reset(fmt.Sprintf(common.RateLimitLoginFailuresKey, subjectType, subject)); err != nil {
+			return err
+		}
+		if err := u.repo.Auth.DeleteLoginLock(subjectType, subject); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed login of its subjects and locks each of them for the delay its
+// failures reached. The counts last as long as a lockout.
+func (u *AuthService) recordLoginFailure(subjects map[string]string) {
+	lockout := getLoginLockout()
+	for subjectType, subject := range subjects {
+		failures, err := u.repo.RateLimit.Increment(fmt.Sprintf(common.RateLimitLoginFailuresKey, subjectType, subject), 1, uint64(lockout.Seconds()))
+		if err != nil {
+			log.Printf("Error counting failed login: %v", err)
+			continue
+		}
+
+		maxFailures := getLoginMaxFailures(subjectType)
+		lockDuration := common.GetLoginLockDuration(failures, maxFailures, lockout)
+		if lockDuration == 0 {
+			continue
+		}
+		if failures >= maxFailures {
+			log.Printf("[Login] Locking out %v %v for %v after %v failed logins", subjectType, subject, lockDuration, failures)
+		}
+		if err := u.repo.Auth.SetLoginLock(subjectType, subject, time.Now().Add(lockDuration)); err != nil {
+			log.Printf("Error locking login: %v", err)
+		}
+	}
+}
+
+// resetLoginFailures forgets the failed logins of a key once it logs in, those of the IP are kept as
+// an IP may try many keys.
+func (u *AuthService) resetLoginFailures(apiKey string) {
+	subject := common.GetStringSHA256(apiKey)
+	if err := u.repo.RateLimit.Reset(fmt.Sprintf(common.RateLimitLoginFailuresKey, common.LoginSubjectKey, subject)); err != nil {
+		log.Printf("Error resetting failed logins: %v", err)
+	}
+}
+
+// getLoginSubjects returns what the failed logins are counted on, the key is hashed as it may be any
+// string.
+func getLoginSubjects(apiKey string, ip string) map[string]string {
+	subjects := map[string]string{common.LoginSubjectKey: common.GetStringSHA256(apiKey)}
+	if ip != "" {
+		subjects[common.LoginSubjectIP] = ip
+	}
+	return subjects
+}
+
+func getLoginMaxFailures(subjectType string) uint64 {
+	envName, maxFailures := "LOGIN_MAX_FAILURES_PER_KEY", common.DefaultLoginMaxFailuresPerKey
+	if subjectType == common.LoginSubjectIP {
+		envName, maxFailures = "LOGIN_MAX_FAILURES_PER_IP", common.DefaultLoginMaxFailuresPerIP
+	}
+	if os.Getenv(envName) != "" {
+		value, err := strconv.Atoi(os.Getenv(envName))
+		if err == nil && value > 0 {
+			maxFailures = value
+		}
+	}
+	return uint64(maxFailures)
+}
+
+func getLoginLockout() time.Duration {
+	lockout := common.DefaultLoginLockout
+	if os.Getenv("LOGIN_LOCKOUT_MINUTES") != "" {
+		minutes, err := strconv.Atoi(os.Getenv("LOGIN_LOCKOUT_MINUTES"))
+		if err == nil && minutes > 0 {
+			lockout = time.Minute * time.Duration(minutes)
+		}
+	}
+	return lockout
+}
+
+// getDummySecretHash returns the hash an unknown key is compared with, computed on first use.
+var getDummySecretHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte(uuid.NewString()), bcrypt.DefaultCost)
+	return hash
+})
+
+// AuthenticatePersonalAccessToken authenticates a request carrying a personal access token and
+// returns its client with the scopes the token grants. Revoking the tokens of the client revokes the
+// personal access tokens created before as well.
+func (u *AuthService) AuthenticatePersonalAccessToken(token string) (*entity.APIClient, []string, error) {
+	prefix, _, isFound := strings.Cut(strings.TrimPrefix(token, common.PersonalAccessTokenPrefix), "_")
+	if !isFound {
+		return nil, nil, errors.New(common.ErrAuthenticationFailedMsg)
+	}
+
+	personalAccessToken, err := u.repo.PersonalAccessToken.FindByPrefix(prefix)
+	if err != nil || personalAccessToken == nil || personalAccessToken.IsExpired() {
+		return nil, nil, errors.New(common.ErrAuthenticationFailedMsg)
+	}
+	if subtle.ConstantTimeCompare([]byte(personalAccessToken.TokenHash), []byte(common.GetStringSHA256(token))) != 1 {
+		return nil, nil, errors.New(common.ErrAuthenticationFailedMsg)
+	}
+
+	apiClient, err := u.repo.APIClient.FindByID(personalAccessToken.ClientID)
+	if err != nil || apiClient == nil || apiClient.IsDisabled {
+		return nil, nil, errors.New(common.ErrAuthenticationFailedMsg)
+	}
+	if common.IsIssuedBeforeValidAfter(personalAccessToken.CreatedAt, apiClient.TokensValidAfter) {
+		return nil, nil, errors.New(common.ErrJWTTokenRevokedMsg)
+	}
+
+	if time.Since(personalAccessToken.LastUsedAt) > common.LastUsedInterval {
+		if err := u.repo.PersonalAccessToken.UpdateLastUsedAt(personalAccessToken.ID, time.Now()); err != nil {
+			log.Printf("Error update personal access token last used: %v", err)
+		}
+	}
+	return apiClient, personalAccessToken.GetScopes(apiClient), nil
+}
+
+// IsOIDCToken tells whether a bearer token claims to come from a trusted OIDC issuer rather than
+// from gotaro.
+func (u *AuthService) IsOIDCToken(token string) bool {
+	return u.repo.Auth.IsOIDCToken(token)
+}
+
+// AuthenticateOIDCToken authenticates a request carrying a token of a trusted OIDC issuer, the caller
+// gets the scopes its claims map to without an api client.
+func (u *AuthService) AuthenticateOIDCToken(token string) (*oidc.Identity, error) {
+	identity, err := u.repo.Auth.VerifyOIDCToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// AuthenticateSignedRequest authenticates a request signed with the signing secret of a client. The
+// timestamp has to be within the clock skew of the server time and a nonce is accepted once, so a
+// captured request can not be replayed.
+func (u *AuthService) AuthenticateSignedRequest(authorization, method, path, timestamp, nonce string, body []byte) (*entity.APIClient, error) {
+	requestAuthorization, isValid := signing.ParseRequestAuthorization(authorization)
+	if !isValid || nonce == "" || len(nonce) > 128 {
+		return nil, errors.New(common.ErrRequestSignatureInvalidMsg)
+	}
+
+	clockSkew := getRequestClockSkew()
+	requestTime, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, errors.New(common.ErrRequestSignatureInvalidMsg)
+	}
+	if skew := time.Since(time.Unix(requestTime, 0)); skew > clockSkew || skew < -clockSkew {
+		return nil, errors.New(common.ErrRequestExpiredMsg)
+	}
+
+	apiClient, err := u.repo.APIClient.FindByKey(requestAuthorization.Key)
+	if err != nil || apiClient == nil || apiClient.IsDisabled || apiClient.SigningSecret == "" {
+		return nil, errors.New(common.ErrAuthenticationFailedMsg)
+	}
+	signingSecret := []byte(apiClient.SigningSecret)
+	if secret.IsEncrypted(apiClient.SigningSecret) {
+		signingSecret, err = u.keyring.Decrypt(apiClient.SigningSecret)
+		if err != nil {
+			log.Printf("Error decrypting signing secret of %v: %v", apiClient.ID, err)
+			return nil, errors.New(common.ErrAuthenticationFailedMsg)
+		}
+	}
+	if !signing.IsRequestSignatureValid(signingSecret, requestAuthorization.Signature, method, path, timestamp, nonce, body) {
+		return nil, errors.New(common.ErrRequestSignatureInvalidMsg)
+	}
+
+	// the nonce is remembered as long as a request carrying it can be within the clock skew
+	isNew, err := u.repo.Auth.UseNonce(apiClient.ID, nonce, uint64((clockSkew * 2).Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	if !isNew {
+		return nil, errors.New(common.ErrRequestReplayedMsg)
+	}
+
+	u.trackClientUse(apiClient)
+	return apiClient, nil
+}
+
+func getRequestClockSkew() time.Duration {
+	clockSkew := common.DefaultRequestClockSkew
+	if os.Getenv("REQUEST_SIGNATURE_CLOCK_SKEW_SECONDS") != "" {
+		seconds, err := strconv.Atoi(os.Getenv("REQUEST_SIGNATURE_CLOCK_SKEW_SECONDS"))
+		if err == nil && seconds > 0 {
+			clockSkew = time.Second * time.Duration(seconds)
+		}
+	}
+	return clockSkew
+}
+
+// trackClientUse stores when the key of a client was last used.
+func (u *AuthService) trackClientUse(apiClient *entity.APIClient) {
+	if time.Since(apiClient.LastUsedAt) <= common.LastUsedInterval {
+		return
+	}
+	if err := u.repo.APIClient.UpdateLastUsedAt(apiClient.ID, time.Now()); err != nil {
+		log.Printf("Error update api client last used: %v", err)
+	}
 }
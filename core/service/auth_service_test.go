@@ -0,0 +1,199 @@
+package service_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/common/secret"
+	"github.com/sibeur/gotaro/core/common/signing"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/repository"
+	"github.com/sibeur/gotaro/core/service"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestApiClient(t *testing.T, id string, secretKey string) *entity.APIClient {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secretKey), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error hash secret: %v", err)
+	}
+	return &entity.APIClient{
+		ID:         id,
+		Key:        "key-" + id,
+		Secret:     string(hash),
+		Scopes:     []string{"uploader"},
+		LastUsedAt: time.Now(),
+	}
+}
+
+func newTestAuthService(repo *repository.Repository, keyring *secret.Keyring) *service.AuthService {
+	if repo.Auth == nil {
+		repo.Auth = newFakeAuthRepository(nil)
+	}
+	if repo.RateLimit == nil {
+		repo.RateLimit = newFakeRateLimitRepository()
+	}
+	return service.NewAuthService(repo, keyring)
+}
+
+func TestAuthenticateSignedRequestReplay(t *testing.T) {
+	keyring, err := secret.NewKeyring("v1", map[string][]byte{"v1": bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatalf("Error create keyring: %v", err)
+	}
+	encryptedSecret, err := keyring.Encrypt([]byte("signing-secret"))
+	if err != nil {
+		t.Fatalf("Error encrypt signing secret: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		keyring       *secret.Keyring
+		signingSecret string
+	}{
+		{name: "encrypted secret", keyring: keyring, signingSecret: encryptedSecret},
+		{name: "plain secret", keyring: nil, signingSecret: "signing-secret"},
+	}
+	for _, test := range tests {
+		apiClient := newTestApiClient(t, "client-1", "secret")
+		apiClient.SigningSecret = test.signingSecret
+		otherClient := newTestApiClient(t, "client-2", "secret")
+		otherClient.SigningSecret = test.signingSecret
+		authService := newTestAuthService(&repository.Repository{APIClient: newFakeApiClientRepository(apiClient, otherClient)}, test.keyring)
+
+		authenticate := func(client *entity.APIClient, nonce string) error {
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			body := []byte(`{"name":"avatar"}`)
+			signature := signing.SignRequest([]byte("signing-secret"), "POST", "/api/v1/media", timestamp, nonce, body)
+			authorization := signing.FormatRequestAuthorization(client.Key, signature)
+			_, err := authService.AuthenticateSignedRequest(authorization, "POST", "/api/v1/media", timestamp, nonce, body)
+			return err
+		}
+
+		steps := []struct {
+			name     string
+			client   *entity.APIClient
+			nonce    string
+			expected string
+		}{
+			{name: "first request", client: apiClient, nonce: "nonce-1"},
+			{name: "replayed request", client: apiClient, nonce: "nonce-1", expected: common.ErrRequestReplayedMsg},
+			{name: "other nonce", client: apiClient, nonce: "nonce-2"},
+			{name: "nonce of an other client", client: otherClient, nonce: "nonce-1"},
+		}
+		for _, step := range steps {
+			err := authenticate(step.client, step.nonce)
+			if (step.expected == "" && err != nil) || (step.expected != "" && (err == nil || err.Error() != step.expected)) {
+				t.Errorf("%v, %v: Expected %v, got %v", test.name, step.name, step.expected, err)
+			}
+		}
+	}
+}
+
+func TestAuthenticatePersonalAccessTokenRevocation(t *testing.T) {
+	revokedAt := time.Now().Truncate(time.Second).Add(time.Millisecond * 500)
+
+	tests := []struct {
+		name             string
+		createdAt        time.Time
+		tokensValidAfter time.Time
+		isDisabled       bool
+		token            string
+		expected         string
+	}{
+		{name: "never revoked", createdAt: revokedAt},
+		{name: "created before the revocation", createdAt: revokedAt.Add(-time.Hour), tokensValidAfter: revokedAt, expected: common.ErrJWTTokenRevokedMsg},
+		{name: "created after the revocation", createdAt: revokedAt.Add(time.Hour), tokensValidAfter: revokedAt},
+		// the second of the revocation is valid, as it is for the gotaro tokens
+		{name: "created the second of the revocation", createdAt: revokedAt.Add(-time.Millisecond * 200), tokensValidAfter: revokedAt},
+		{name: "disabled client", createdAt: revokedAt, isDisabled: true, expected: common.ErrAuthenticationFailedMsg},
+		{name: "wrong token", createdAt: revokedAt, token: "gtp_abcd_wrong", expected: common.ErrAuthenticationFailedMsg},
+	}
+	for _, test := range tests {
+		apiClient := newTestApiClient(t, "client-1", "secret")
+		apiClient.TokensValidAfter = test.tokensValidAfter
+		apiClient.IsDisabled = test.isDisabled
+
+		token := "gtp_abcd_token"
+		personalAccessToken := &entity.PersonalAccessToken{
+			ID:         "token-1",
+			ClientID:   apiClient.ID,
+			Prefix:     "abcd",
+			TokenHash:  common.GetStringSHA256(token),
+			CreatedAt:  test.createdAt,
+			LastUsedAt: time.Now(),
+		}
+		authService := newTestAuthService(&repository.Repository{
+			APIClient:           newFakeApiClientRepository(apiClient),
+			PersonalAccessToken: &fakePersonalAccessTokenRepository{tokens: map[string]*entity.PersonalAccessToken{"abcd": personalAccessToken}},
+		}, nil)
+
+		if test.token != "" {
+			token = test.token
+		}
+		_, scopes, err := authService.AuthenticatePersonalAccessToken(token)
+		if test.expected == "" {
+			if err != nil {
+				t.Errorf("%v: Expected no error, got %v", test.name, err)
+			} else if len(scopes) != 1 || scopes[0] != "uploader" {
+				t.Errorf("%v: Expected scopes [uploader], got %v", test.name, scopes)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("%v: Expected %v, got %v", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestLoginLocksKeyOutOnLoginOnly(t *testing.T) {
+	t.Setenv("LOGIN_MAX_FAILURES_PER_KEY", "2")
+
+	tests := []struct {
+		name        string
+		fail        func(authService *service.AuthService, apiKey string) error
+		isLockedOut bool
+	}{
+		{
+			name: "failed logins",
+			fail: func(authService *service.AuthService, apiKey string) error {
+				_, err := authService.Login(apiKey, "wrong-secret", "198.51.100.1")
+				return err
+			},
+			isLockedOut: true,
+		},
+		{
+			name: "failed api key requests",
+			fail: func(authService *service.AuthService, apiKey string) error {
+				_, err := authService.AuthenticateAPIKey(apiKey, "wrong-secret", "198.51.100.1")
+				return err
+			},
+			isLockedOut: false,
+		},
+	}
+	for _, test := range tests {
+		apiClient := newTestApiClient(t, "client-1", "secret")
+		authService := newTestAuthService(&repository.Repository{APIClient: newFakeApiClientRepository(apiClient)}, nil)
+
+		for i := 0; i < 2; i++ {
+			if err := test.fail(authService, apiClient.Key); err == nil || err.Error() != common.ErrAuthenticationFailedMsg {
+				t.Fatalf("%v: Expected %v, got %v", test.name, common.ErrAuthenticationFailedMsg, err)
+			}
+		}
+
+		// the owner of the key logs in from another IP
+		_, err := authService.Login(apiClient.Key, "secret", "198.51.100.2")
+		if test.isLockedOut && (err == nil || err.Error() != common.ErrLoginLockedMsg) {
+			t.Errorf("%v: Expected %v, got %v", test.name, common.ErrLoginLockedMsg, err)
+		}
+		if !test.isLockedOut && err != nil {
+			t.Errorf("%v: Expected the key not to be locked out, got %v", test.name, err)
+		}
+		if retryAfter := authService.GetLoginRetryAfter(apiClient.Key, "198.51.100.2"); (retryAfter > 0) != test.isLockedOut {
+			t.Errorf("%v: Expected locked out %v, got retry after %v", test.name, test.isLockedOut, retryAfter)
+		}
+	}
+}
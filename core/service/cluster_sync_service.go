@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/repository"
+)
+
+// ClusterSyncService keeps the driver clients and cached medias of this instance in line with the
+// changes made by other instances, using Mongo change streams or polling when they are not supported.
+type ClusterSyncService struct {
+	repo          *repository.Repository
+	driverService *DriverService
+	pollInterval  time.Duration
+}
+
+func NewClusterSyncService(repo *repository.Repository, driverService *DriverService) *ClusterSyncService {
+	pollInterval := common.DefaultClusterSyncPollInterval
+	if os.Getenv("CLUSTER_SYNC_POLL_INTERVAL_SECONDS") != "" {
+		seconds, _ := strconv.Atoi(os.Getenv("CLUSTER_SYNC_POLL_INTERVAL_SECONDS"))
+		if seconds > 0 {
+			pollInterval = time.Second * time.Duration(seconds)
+		}
+	}
+	return &ClusterSyncService{repo: repo, driverService: driverService, pollInterval: pollInterval}
+}
+
+func (u *ClusterSyncService) Start() {
+	go u.watch()
+}
+
+func (u *ClusterSyncService) watch() {
+	collNames := []string{entity.Driver{}.GetCollName(), entity.Rule{}.GetCollName(), entity.Media{}.GetCollName()}
+	for {
+		err := u.repo.ChangeStream.Watch(context.Background(), collNames, u.handleChange)
+		if repository.IsChangeStreamUnsupported(err) {
+			log.Printf("Change streams are not supported, polling changes every %v", u.pollInterval)
+			u.poll()
+			return
+		}
+		log.Printf("Change stream stopped: %v, reconnecting in %v", err, common.ClusterSyncRetryDelay)
+		time.Sleep(common.ClusterSyncRetryDelay)
+
+		// drivers changed while reconnecting are not replayed when the resume token was lost
+		if err := u.driverService.SyncDriverManager(); err != nil {
+			log.Printf("Error syncing drivers: %v", err)
+		}
+	}
+}
+
+func (u *ClusterSyncService) handleChange(event *repository.ChangeEvent) {
+	switch event.Collection {
+	case entity.Driver{}.GetCollName():
+		if err := u.driverService.SyncDriverManager(); err != nil {
+			log.Printf("Error syncing drivers: %v", err)
+		}
+	case entity.Rule{}.GetCollName():
+		if event.Slug != "" {
+			u.evictRule(event.Slug)
+		}
+	case entity.Media{}.GetCollName():
+		if event.RuleSlug != "" {
+			u.evictMedia(event.RuleSlug, event.FileAliasName)
+		}
+	}
+}
+
+func (u *ClusterSyncService) poll() {
+	ticker := time.NewTicker(u.pollInterval)
+	defer ticker.Stop()
+
+	since := time.Now()
+	for range ticker.C {
+		polledAt := time.Now()
+
+		if err := u.driverService.SyncDriverManager(); err != nil {
+			log.Printf("Error syncing drivers: %v", err)
+			continue
+		}
+
+		// the changes of an instance whose clock is behind are timed before they are made
+		changedSince := since.Add(-common.ClusterSyncClockSkew)
+		rules, err := u.repo.Rule.FindChangedSince(changedSince)
+		if err != nil {
+			continue
+		}
+		for _, rule := range rules {
+			u.evictRule(rule.Slug)
+		}
+
+		medias, err := u.repo.Media.FindChangedSince(changedSince)
+		if err != nil {
+			continue
+		}
+		for _, media := range medias {
+			u.evictMedia(media.RuleSlug, media.FileAliasName)
+		}
+
+		since = polledAt
+	}
+}
+
+func (u *ClusterSyncService) evictRule(ruleSlug string) {
+	medias, err := u.repo.Media.FindAllByRule(ruleSlug)
+	if err != nil {
+		return
+	}
+	for _, media := range medias {
+		u.evictMedia(media.RuleSlug, media.FileAliasName)
+	}
+}
+
+func (u *ClusterSyncService) evictMedia(ruleSlug, fileAliasName string) {
+	u.repo.Media.DeleteCachedMedia(ruleSlug, fileAliasName)
+	u.repo.Media.DeleteCachedSignedUrl(ruleSlug, fileAliasName)
+}
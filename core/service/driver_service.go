@@ -3,9 +3,14 @@ package service
 import (
 	"errors"
 	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/sibeur/gotaro/core/common"
 	driver_lib "github.com/sibeur/gotaro/core/common/driver"
+	"github.com/sibeur/gotaro/core/common/secret"
 	"github.com/sibeur/gotaro/core/entity"
 	"github.com/sibeur/gotaro/core/repository"
 )
@@ -13,10 +18,35 @@ import (
 type DriverService struct {
 	repo          *repository.Repository
 	DriverManager *driver_lib.DriverManager
+	HealthMonitor *driver_lib.HealthMonitor
+	keyring       *secret.Keyring
+	mutex         sync.Mutex
+	// loadedDrivers keeps the updated_at, in milliseconds, of the config each driver client was built from
+	loadedDrivers map[string]int64
 }
 
-func NewDriverService(repo *repository.Repository, driverManager *driver_lib.DriverManager) *DriverService {
-	return &DriverService{repo: repo, DriverManager: driverManager}
+func NewDriverService(repo *repository.Repository, driverManager *driver_lib.DriverManager, healthMonitor *driver_lib.HealthMonitor, keyring *secret.Keyring) *DriverService {
+	if !keyring.IsEnabled() {
+		log.Println("GOTARO_MASTER_KEYS is not set and ALLOW_PLAINTEXT_SECRETS is, driver secrets are stored unencrypted")
+	}
+	return &DriverService{
+		repo:          repo,
+		DriverManager: driverManager,
+		HealthMonitor: healthMonitor,
+		keyring:       keyring,
+		loadedDrivers: make(map[string]int64),
+	}
+}
+
+func NewHealthMonitor(driverManager *driver_lib.DriverManager) *driver_lib.HealthMonitor {
+	interval := common.DefaultDriverHealthInterval
+	if os.Getenv("DRIVER_HEALTH_CHECK_INTERVAL_SECONDS") != "" {
+		seconds, _ := strconv.Atoi(os.Getenv("DRIVER_HEALTH_CHECK_INTERVAL_SECONDS"))
+		if seconds > 0 {
+			interval = time.Second * time.Duration(seconds)
+		}
+	}
+	return driver_lib.NewHealthMonitor(driverManager, interval)
 }
 
 func (u *DriverService) FindAll() ([]*entity.Driver, error) {
@@ -42,7 +72,13 @@ func (u *DriverService) Create(driver *entity.Driver) error {
 	if err == nil && existingDriver != nil {
 		return errors.New(common.ErrDriverAlreadyExistMsg)
 	}
-	driverClient, err := driver_lib.NewDriverClient(driver.Slug, driver_lib.StorageDriverType(driver.Type), driver.GetDriverConfig())
+
+	driverConfig := driver.GetDriverConfig()
+	if err := checkSecretConfig(driver.Type, driverConfig); err != nil {
+		return err
+	}
+
+	driverClient, err := driver_lib.NewDriverClient(driver.Slug, driver_lib.StorageDriverType(driver.Type), driverConfig)
 	if err != nil {
 		return err
 	}
@@ -58,18 +94,50 @@ func (u *DriverService) Create(driver *entity.Driver) error {
 	}
 
 	driver.IsPublic = isPublic
+	driver.DriverConfig, err = u.encryptDriverConfig(driver.Type, driverConfig)
+	if err != nil {
+		return err
+	}
 
 	err = u.repo.Driver.Create(driver)
 	if err != nil {
 		return err
 	}
 
-	u.DriverManager.AddDriver(driverClient)
+	u.addDriverClient(driver, driverClient)
+	go u.HealthMonitor.Check(driver.Slug)
 	return nil
 }
 
+// Update replaces the driver config. Secret fields left empty keep their stored value.
 func (u *DriverService) Update(driver *entity.Driver) error {
-	driverClient, err := driver_lib.NewDriverClient(driver.Slug, driver_lib.StorageDriverType(driver.Type), driver.GetDriverConfig())
+	existingDriver, err := u.repo.Driver.FindBySlug(driver.Slug)
+	if err != nil {
+		return err
+	}
+	if existingDriver == nil {
+		return errors.New(common.ErrDriverNotFoundMsg)
+	}
+
+	driverConfig := driver.GetDriverConfig()
+	if existingDriver.Type == driver.Type {
+		existingDriverConfig := existingDriver.GetDriverConfig()
+		for _, field := range driver_lib.GetSecretConfigFields(driver_lib.StorageDriverType(driver.Type)) {
+			if driverConfig[field] == nil || driverConfig[field] == "" {
+				driverConfig[field] = existingDriverConfig[field]
+			}
+		}
+	}
+	if err := checkSecretConfig(driver.Type, driverConfig); err != nil {
+		return err
+	}
+
+	plainDriverConfig, err := u.decryptDriverConfig(driver.Type, driverConfig)
+	if err != nil {
+		return err
+	}
+
+	driverClient, err := driver_lib.NewDriverClient(driver.Slug, driver_lib.StorageDriverType(driver.Type), plainDriverConfig)
 	if err != nil {
 		return err
 	}
@@ -85,13 +153,18 @@ func (u *DriverService) Update(driver *entity.Driver) error {
 	}
 
 	driver.IsPublic = isPublic
+	driver.DriverConfig, err = u.encryptDriverConfig(driver.Type, plainDriverConfig)
+	if err != nil {
+		return err
+	}
 
 	err = u.repo.Driver.Update(driver)
 	if err != nil {
 		return err
 	}
 
-	u.DriverManager.AddDriver(driverClient)
+	u.addDriverClient(driver, driverClient)
+	go u.HealthMonitor.Check(driver.Slug)
 
 	return nil
 }
@@ -101,7 +174,7 @@ func (u *DriverService) Delete(slug string) error {
 	if err != nil {
 		return err
 	}
-	u.DriverManager.RemoveDriver(slug)
+	u.removeDriverClient(slug)
 	return nil
 }
 
@@ -113,6 +186,38 @@ func (u *DriverService) FindBySlug(slug string) (*entity.Driver, error) {
 	return result, nil
 }
 
+func (u *DriverService) FindAllTypes() []*driver_lib.DriverTypeInfo {
+	return driver_lib.GetAllDriverTypes()
+}
+
+// Validate builds a client from an unsaved driver config and runs every check against it.
+func (u *DriverService) Validate(driver *entity.Driver) *driver_lib.DriverValidationReport {
+	driverClient, err := driver_lib.NewDriverClient(driver.Slug, driver_lib.StorageDriverType(driver.Type), driver.GetDriverConfig())
+	if err != nil {
+		return driver_lib.NewDriverValidationReport([]driver_lib.DriverValidationCheck{{
+			Name:   common.DriverValidationCheckCredentials,
+			Status: common.DriverValidationStatusFailed,
+			Error:  err.Error(),
+		}})
+	}
+	defer driverClient.Close()
+
+	return driverClient.RunValidation()
+}
+
+// GetHealth returns the latest health of a driver, checking it right away when it is not known yet.
+func (u *DriverService) GetHealth(slug string) *driver_lib.DriverHealth {
+	health := u.HealthMonitor.GetHealth(slug)
+	if health == nil {
+		health = u.HealthMonitor.Check(slug)
+	}
+	return health
+}
+
+func (u *DriverService) StartHealthMonitor() {
+	u.HealthMonitor.Start()
+}
+
 func (u *DriverService) LoadDriverManager() error {
 	drivers, err := u.FindAll()
 	if err != nil {
@@ -120,14 +225,161 @@ func (u *DriverService) LoadDriverManager() error {
 	}
 
 	for _, driver := range drivers {
-		driverConfigJSON := driver.GetDriverConfig()
-		driverClient, err := driver_lib.NewDriverClient(driver.Slug, driver_lib.StorageDriverType(driver.Type), driverConfigJSON)
+		u.loadDriver(driver)
+	}
+
+	return nil
+}
+
+// SyncDriverManager reloads the driver clients whose config changed since they were built and removes
+// the ones of deleted drivers, picking up changes made by other instances.
+func (u *DriverService) SyncDriverManager() error {
+	drivers, err := u.FindAll()
+	if err != nil {
+		return err
+	}
+
+	existingDrivers := make(map[string]bool)
+	for _, driver := range drivers {
+		existingDrivers[driver.Slug] = true
+		u.mutex.Lock()
+		loadedUpdatedAt, isLoaded := u.loadedDrivers[driver.Slug]
+		u.mutex.Unlock()
+		if isLoaded && loadedUpdatedAt == driver.UpdatedAt.UnixMilli() {
+			continue
+		}
+		u.loadDriver(driver)
+		go u.HealthMonitor.Check(driver.Slug)
+	}
+
+	for _, driverName := range u.DriverManager.GetAllDriverNames() {
+		if !existingDrivers[driverName] {
+			log.Printf("Driver %v was deleted, removing its client", driverName)
+			u.removeDriverClient(driverName)
+		}
+	}
+
+	return nil
+}
+
+func (u *DriverService) loadDriver(driver *entity.Driver) {
+	driverConfigJSON, err := u.decryptDriverConfig(driver.Type, driver.GetDriverConfig())
+	if err != nil {
+		log.Printf("Error decrypting driver %v config: %v \n", driver.Slug, err)
+		return
+	}
+	driverClient, err := driver_lib.NewDriverClient(driver.Slug, driver_lib.StorageDriverType(driver.Type), driverConfigJSON)
+	if err != nil {
+		log.Printf("Error loading driver: %v \n", err)
+		return
+	}
+	u.addDriverClient(driver, driverClient)
+}
+
+func (u *DriverService) addDriverClient(driver *entity.Driver, driverClient driver_lib.DriverClientUseCase) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.DriverManager.AddDriver(driverClient)
+	u.loadedDrivers[driver.Slug] = driver.UpdatedAt.UnixMilli()
+}
+
+func (u *DriverService) removeDriverClient(slug string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.DriverManager.RemoveDriver(slug)
+	u.HealthMonitor.Remove(slug)
+	delete(u.loadedDrivers, slug)
+}
+
+// RotateSecrets encrypts again with the active master key every driver secret that is stored plain
+// or wrapped by a previous key, returning the number of drivers updated.
+func (u *DriverService) RotateSecrets() (uint32, error) {
+	if !u.keyring.IsEnabled() {
+		return 0, errors.New(common.ErrMasterKeyNotSetMsg)
+	}
+
+	drivers, err := u.FindAll()
+	if err != nil {
+		return 0, err
+	}
+
+	var rotatedCount uint32
+	for _, driver := range drivers {
+		driverConfig := driver.GetDriverConfig()
+		isRotated := false
+		for _, field := range driver_lib.GetSecretConfigFields(driver_lib.StorageDriverType(driver.Type)) {
+			value, _ := driverConfig[field].(string)
+			if value == "" || !u.keyring.NeedsRotation(value) {
+				continue
+			}
+			driverConfig[field], err = u.keyring.Reencrypt(value)
+			if err != nil {
+				return rotatedCount, err
+			}
+			isRotated = true
+		}
+		if !isRotated {
+			continue
+		}
+
+		driver.DriverConfig = driverConfig
+		if err := u.repo.Driver.Update(driver); err != nil {
+			return rotatedCount, err
+		}
+		rotatedCount++
+	}
+
+	return rotatedCount, nil
+}
+
+func (u *DriverService) encryptDriverConfig(driverType uint32, driverConfig map[string]any) (map[string]any, error) {
+	encryptedConfig := make(map[string]any)
+	for key, value := range driverConfig {
+		encryptedConfig[key] = value
+	}
+	if !u.keyring.IsEnabled() {
+		return encryptedConfig, nil
+	}
+
+	for _, field := range driver_lib.GetSecretConfigFields(driver_lib.StorageDriverType(driverType)) {
+		value, _ := driverConfig[field].(string)
+		if value == "" || secret.IsEncrypted(value) {
+			continue
+		}
+		encryptedValue, err := u.keyring.Encrypt([]byte(value))
 		if err != nil {
-			log.Printf("Error loading driver: %v \n", err)
+			return nil, err
+		}
+		encryptedConfig[field] = encryptedValue
+	}
+	return encryptedConfig, nil
+}
+
+func (u *DriverService) decryptDriverConfig(driverType uint32, driverConfig map[string]any) (map[string]any, error) {
+	plainConfig := make(map[string]any)
+	for key, value := range driverConfig {
+		plainConfig[key] = value
+	}
+
+	for _, field := range driver_lib.GetSecretConfigFields(driver_lib.StorageDriverType(driverType)) {
+		value, _ := driverConfig[field].(string)
+		if !secret.IsEncrypted(value) {
 			continue
 		}
-		u.DriverManager.AddDriver(driverClient)
+		plainValue, err := u.keyring.Decrypt(value)
+		if err != nil {
+			return nil, err
+		}
+		plainConfig[field] = string(plainValue)
 	}
+	return plainConfig, nil
+}
 
+func checkSecretConfig(driverType uint32, driverConfig map[string]any) error {
+	for _, field := range driver_lib.GetSecretConfigFields(driver_lib.StorageDriverType(driverType)) {
+		if value, _ := driverConfig[field].(string); value == "" {
+			return errors.New(common.ErrDriverSecretMissingMsg)
+		}
+	}
 	return nil
 }
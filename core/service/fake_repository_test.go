@@ -0,0 +1,171 @@
+package service_test
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/repository"
+)
+
+// fakeApiClientRepository keeps the api clients in memory, by id and by key.
+type fakeApiClientRepository struct {
+	repository.ApiClientRepositoryUseCase
+	clients map[string]*entity.APIClient
+}
+
+func newFakeApiClientRepository(clients ...*entity.APIClient) *fakeApiClientRepository {
+	repo := &fakeApiClientRepository{clients: make(map[string]*entity.APIClient)}
+	for _, client := range clients {
+		repo.clients[client.ID] = client
+	}
+	return repo
+}
+
+func (f *fakeApiClientRepository) FindByID(id string) (*entity.APIClient, error) {
+	return f.clients[id], nil
+}
+
+func (f *fakeApiClientRepository) FindByKey(key string) (*entity.APIClient, error) {
+	for _, client := range f.clients {
+		if client.Key == key {
+			return client, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeApiClientRepository) FindLimits(id string) (*entity.APIClientLimits, error) {
+	if client := f.clients[id]; client != nil {
+		return client.Limits, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeApiClientRepository) FindTokensValidAfter(id string) (time.Time, bool, error) {
+	client := f.clients[id]
+	if client == nil {
+		return time.Time{}, false, nil
+	}
+	return client.TokensValidAfter, !client.IsDisabled, nil
+}
+
+func (f *fakeApiClientRepository) UpdateLastUsedAt(id string, lastUsedAt time.Time) error {
+	return nil
+}
+
+func (f *fakeApiClientRepository) IsKeyVerified(apiClient *entity.APIClient, apiKey string, secretKey string) bool {
+	return false
+}
+
+func (f *fakeApiClientRepository) SetKeyVerified(apiClient *entity.APIClient, apiKey string, secretKey string) {
+}
+
+// fakePersonalAccessTokenRepository keeps the personal access tokens in memory, by prefix.
+type fakePersonalAccessTokenRepository struct {
+	repository.PersonalAccessTokenRepositoryUseCase
+	tokens map[string]*entity.PersonalAccessToken
+}
+
+func (f *fakePersonalAccessTokenRepository) FindByPrefix(prefix string) (*entity.PersonalAccessToken, error) {
+	return f.tokens[prefix], nil
+}
+
+func (f *fakePersonalAccessTokenRepository) UpdateLastUsedAt(id string, lastUsedAt time.Time) error {
+	return nil
+}
+
+// fakeRateLimitRepository keeps the counters in memory, they never expire.
+type fakeRateLimitRepository struct {
+	repository.RateLimitRepositoryUseCase
+	mutex  sync.Mutex
+	counts map[string]uint64
+}
+
+func newFakeRateLimitRepository() *fakeRateLimitRepository {
+	return &fakeRateLimitRepository{counts: make(map[string]uint64)}
+}
+
+func (f *fakeRateLimitRepository) Increment(key string, value uint64, ttl uint64) (uint64, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.counts[key] += value
+	return f.counts[key], nil
+}
+
+func (f *fakeRateLimitRepository) Decrement(key string, value uint64) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.counts[key] -= min(value, f.counts[key])
+	return nil
+}
+
+func (f *fakeRateLimitRepository) Reset(key string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.counts, key)
+	return nil
+}
+
+func (f *fakeRateLimitRepository) GetCount(key string) (uint64, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.counts[key], nil
+}
+
+// fakeAuthRepository keeps the nonces and login locks in memory. The other calls go to the embedded
+// repository, a real one without database for the calls that do not need it.
+type fakeAuthRepository struct {
+	repository.AuthRepositoryUseCase
+	mutex      sync.Mutex
+	nonces     map[string]bool
+	loginLocks map[string]time.Time
+}
+
+func newFakeAuthRepository(authRepository repository.AuthRepositoryUseCase) *fakeAuthRepository {
+	return &fakeAuthRepository{
+		AuthRepositoryUseCase: authRepository,
+		nonces:                make(map[string]bool),
+		loginLocks:            make(map[string]time.Time),
+	}
+}
+
+func (f *fakeAuthRepository) CreateToken(subject string, audiences []string) (*entity.Auth, error) {
+	return &entity.Auth{}, nil
+}
+
+func (f *fakeAuthRepository) UseNonce(clientID string, nonce string, ttl uint64) (bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	key := clientID + ":" + nonce
+	if f.nonces[key] {
+		return false, nil
+	}
+	f.nonces[key] = true
+	return true, nil
+}
+
+func (f *fakeAuthRepository) GetLoginLock(subjectType string, subject string) time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	lockedUntil := f.loginLocks[fmt.Sprintf("%s:%s", subjectType, subject)]
+	if time.Now().After(lockedUntil) {
+		return time.Time{}
+	}
+	return lockedUntil
+}
+
+func (f *fakeAuthRepository) SetLoginLock(subjectType string, subject string, lockedUntil time.Time) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.loginLocks[fmt.Sprintf("%s:%s", subjectType, subject)] = lockedUntil
+	return nil
+}
+
+func (f *fakeAuthRepository) DeleteLoginLock(subjectType string, subject string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.loginLocks, fmt.Sprintf("%s:%s", subjectType, subject))
+	return nil
+}
@@ -3,11 +3,18 @@ package service
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/sibeur/gotaro/core/common"
 	driver_lib "github.com/sibeur/gotaro/core/common/driver"
+	"github.com/sibeur/gotaro/core/common/secret"
 	"github.com/sibeur/gotaro/core/entity"
 	"github.com/sibeur/gotaro/core/repository"
 )
@@ -15,10 +22,12 @@ import (
 type MediaService struct {
 	repo          *repository.Repository
 	DriverManager *driver_lib.DriverManager
+	HealthMonitor *driver_lib.HealthMonitor
+	keyring       *secret.Keyring
 }
 
-func NewMediaService(repo *repository.Repository, driverManager *driver_lib.DriverManager) *MediaService {
-	return &MediaService{repo: repo, DriverManager: driverManager}
+func NewMediaService(repo *repository.Repository, driverManager *driver_lib.DriverManager, healthMonitor *driver_lib.HealthMonitor, keyring *secret.Keyring) *MediaService {
+	return &MediaService{repo: repo, DriverManager: driverManager, HealthMonitor: healthMonitor, keyring: keyring}
 }
 
 func (u *MediaService) FindAll() ([]*entity.Media, error) {
@@ -49,23 +58,41 @@ func (u *MediaService) Upload(ruleSlug, fileName string, opts ...*entity.MediaUp
 		return nil, errors.New(common.ErrRuleNotFoundMsg)
 	}
 
-	driver, err := u.repo.Driver.FindByID(rule.DriverID)
+	drivers, err := u.findRuleDrivers(rule)
 	if err != nil {
-		log.Printf("Error finding driver: %v", err)
 		return nil, err
 	}
+	driver := drivers[0]
+	replicaDrivers := drivers[1:]
+	isSyncReplication := rule.GetReplicationPolicy() == common.RuleReplicationPolicySync
 
-	if driver == nil {
-		log.Printf("Error finding driver")
-		return nil, errors.New(common.ErrDriverNotFoundMsg)
-	}
-
-	driverClient := u.DriverManager.GetDriver(driver.Slug)
+	driverClient, releaseDriverClient := u.DriverManager.AcquireDriver(driver.Slug)
+	defer releaseDriverClient()
 	if driverClient == nil {
 		log.Printf("Error finding driver client")
 		return nil, errors.New(common.ErrDriverClientNotFoundMsg)
 	}
 
+	if !u.HealthMonitor.IsHealthy(driver.Slug) {
+		log.Printf("Driver %v is unhealthy: %v", driver.Slug, u.HealthMonitor.GetHealth(driver.Slug).LastError)
+		return nil, errors.New(common.ErrDriverUnhealthyMsg)
+	}
+
+	// every replica has to be reachable before writing anything when replication is synchronous
+	if isSyncReplication {
+		for _, replicaDriver := range replicaDrivers {
+			if u.DriverManager.GetDriver(replicaDriver.Slug) == nil {
+				log.Printf("Error finding replica driver client %v", replicaDriver.Slug)
+				return nil, errors.New(common.ErrDriverClientNotFoundMsg)
+			}
+
+			if !u.HealthMonitor.IsHealthy(replicaDriver.Slug) {
+				log.Printf("Replica driver %v is unhealthy: %v", replicaDriver.Slug, u.HealthMonitor.GetHealth(replicaDriver.Slug).LastError)
+				return nil, errors.New(common.ErrDriverUnhealthyMsg)
+			}
+		}
+	}
+
 	fileAliasName := common.GetFileNameUnique(fileName)
 
 	fileMetaData, err := common.GetFileMetaData(tempFilePath)
@@ -87,57 +114,245 @@ func (u *MediaService) Upload(ruleSlug, fileName string, opts ...*entity.MediaUp
 		return nil, errors.New(common.ErrFileMimeInvalidMsg)
 	}
 
-	folder := driver.GetDefaultFolder()
-	if opt.Directory != "" {
-		fmt.Println("opt.Directory", opt.Directory)
-		optDirectory := strings.Trim(opt.Directory, " ")
-		optDirectory = strings.Trim(optDirectory, "")
-		optDirectory = strings.Trim(optDirectory, "/")
-		folder = optDirectory
+	checksum, err := common.GetFileSHA256(tempFilePath)
+	if err != nil {
+		log.Printf("Error getting file checksum: %v", err)
+		return nil, err
 	}
-	fmt.Println("folder", folder)
-	targetFilePath := folder + "/" + fileAliasName
-	if folder == "/" {
-		targetFilePath = fileAliasName
+
+	// the media is described before uploading so its record can be rebuilt from the object metadata
+	media := &entity.Media{
+		ID:               uuid.NewString(),
+		RuleSlug:         ruleSlug,
+		FileOriginalName: fileName,
+		FileAliasName:    getObjectPath(driver, opt, fileAliasName),
+		FileExt:          fileMetaData.FileExt,
+		FileMime:         fileMetaData.FileMime,
+		FileSize:         fileMetaData.FileSize,
+		FileDirectory:    getTargetFolder(driver, opt),
+		IsCommit:         opt.IsCommit,
+		Checksum:         checksum,
+		Metadata:         opt.Metadata,
 	}
 
-	fileAliasName = targetFilePath
+	// encrypted rules upload an encrypted copy so drivers never get the file content
+	uploadFilePath := tempFilePath
+	if rule.IsEncrypted {
+		uploadFilePath = common.TemporaryFolder + "/encrypted_" + media.ID
+		media.Encryption, err = u.encryptFile(tempFilePath, uploadFilePath)
+		if err != nil {
+			log.Printf("Error encrypting file: %v", err)
+			return nil, err
+		}
+		defer func() {
+			if err := os.Remove(uploadFilePath); err != nil {
+				log.Printf("Failed to delete encrypted file %v", uploadFilePath)
+			}
+		}()
+	}
+	uploadOpts := newUploadFileOpts(rule, media)
 
-	filePathFromDriver := driver.GetFilePathFromDriver(targetFilePath)
+	location, err := u.uploadToDriver(driver, driverClient, uploadFilePath, fileAliasName, opt, uploadOpts)
+	if err != nil {
+		log.Printf("Error uploading file: %v", err)
+		return nil, err
+	}
+	locations := []entity.MediaLocation{*location}
+
+	for _, replicaDriver := range replicaDrivers {
+		if !isSyncReplication {
+			locations = append(locations, entity.MediaLocation{
+				DriverSlug: replicaDriver.Slug,
+				Status:     common.MediaLocationStatusPending,
+				UpdatedAt:  time.Now(),
+			})
+			continue
+		}
 
-	uploadOpts := &driver_lib.UploadFileOpts{
-		Mime: fileMetaData.FileMime,
+		replicaClient, releaseReplicaClient := u.DriverManager.AcquireDriver(replicaDriver.Slug)
+		replicaLocation, err := u.uploadToDriver(replicaDriver, replicaClient, uploadFilePath, fileAliasName, opt, uploadOpts)
+		releaseReplicaClient()
+		if err != nil {
+			log.Printf("Error uploading file to replica %v: %v", replicaDriver.Slug, err)
+			u.deleteUploadedObjects(locations)
+			return nil, fmt.Errorf("%s: %w", common.ErrMediaReplicaFailedMsg, err)
+		}
+		locations = append(locations, *replicaLocation)
+	}
+
+	media.DriverSlug = driver.Slug
+	media.FilePath = location.FilePath
+	media.FilePathFromDriver = location.FilePathFromDriver
+	media.IsPublic = location.IsPublic
+	media.Locations = locations
+	err = u.repo.Media.Create(media)
+
+	if err != nil {
+		log.Printf("Error creating media: %v", err)
+		u.deleteUploadedObjects(locations)
+		return nil, err
+	}
+
+	if !isSyncReplication && len(replicaDrivers) > 0 {
+		// the temporary file is removed once the request is finished, so the replicas read from their own
+		// copy, named apart from the client file name so it can not escape the temporary folder
+		replicaFilePath := common.TemporaryFolder + "/replica_" + uuid.NewString() + "_" + filepath.Base(fileName)
+		if err := common.CopyFile(uploadFilePath, replicaFilePath); err != nil {
+			log.Printf("Error copying file for replicas: %v", err)
+			return media, nil
+		}
+		go u.replicateAsync(media, replicaDrivers, replicaFilePath, fileAliasName, opt, uploadOpts)
+	}
+
+	return media, nil
+}
+
+func (u *MediaService) findRuleDrivers(rule *entity.Rule) ([]*entity.Driver, error) {
+	drivers := make([]*entity.Driver, 0)
+	for _, driverID := range rule.GetDriverIDs() {
+		driver, err := u.repo.Driver.FindByID(driverID)
+		if err != nil {
+			log.Printf("Error finding driver: %v", err)
+			return nil, err
+		}
+
+		if driver == nil {
+			log.Printf("Error finding driver")
+			return nil, errors.New(common.ErrDriverNotFoundMsg)
+		}
+		drivers = append(drivers, driver)
 	}
+
+	if len(drivers) == 0 {
+		log.Printf("Error finding driver")
+		return nil, errors.New(common.ErrDriverNotFoundMsg)
+	}
+	return drivers, nil
+}
+
+func (u *MediaService) uploadToDriver(driver *entity.Driver, driverClient driver_lib.DriverClientUseCase, tempFilePath, fileAliasName string, opt *entity.MediaUploadOpts, uploadOpts *driver_lib.UploadFileOpts) (*entity.MediaLocation, error) {
+	if driverClient == nil {
+		return nil, errors.New(common.ErrDriverClientNotFoundMsg)
+	}
+
+	targetFilePath := getObjectPath(driver, opt, fileAliasName)
+
 	mediaLink, err := driverClient.UploadFile(tempFilePath, targetFilePath, uploadOpts)
 	if err != nil {
-		log.Printf("Error uploading file: %v", err)
 		return nil, err
 	}
 
 	isPublic, _ := driverClient.IsStorageAssetPublic()
 
-	media := entity.Media{
-		RuleSlug:           ruleSlug,
+	return &entity.MediaLocation{
 		DriverSlug:         driver.Slug,
-		FileOriginalName:   fileName,
-		FileAliasName:      fileAliasName,
-		FileExt:            fileMetaData.FileExt,
-		FileMime:           fileMetaData.FileMime,
-		FileSize:           fileMetaData.FileSize,
+		ObjectPath:         targetFilePath,
 		FilePath:           mediaLink,
-		FilePathFromDriver: filePathFromDriver,
-		FileDirectory:      folder,
-		IsCommit:           opt.IsCommit,
+		FilePathFromDriver: driver.GetFilePathFromDriver(targetFilePath),
 		IsPublic:           isPublic,
+		Status:             common.MediaLocationStatusSynced,
+		UpdatedAt:          time.Now(),
+	}, nil
+}
+
+// deleteUploadedObjects removes the objects of an upload that failed, so they are not left in the
+// storage without a media.
+func (u *MediaService) deleteUploadedObjects(locations []entity.MediaLocation) {
+	for _, location := range locations {
+		if location.Status != common.MediaLocationStatusSynced {
+			continue
+		}
+		driverClient, releaseDriverClient := u.DriverManager.AcquireDriver(location.DriverSlug)
+		if driverClient == nil {
+			log.Printf("Error deleting object %v: driver %v not found", location.ObjectPath, location.DriverSlug)
+			continue
+		}
+		if err := driverClient.DeleteFile(location.ObjectPath); err != nil {
+			log.Printf("Error deleting object %v from driver %v: %v", location.ObjectPath, location.DriverSlug, err)
+		}
+		releaseDriverClient()
 	}
-	err = u.repo.Media.Create(&media)
+}
 
-	if err != nil {
-		log.Printf("Error creating media: %v", err)
-		return nil, err
+func (u *MediaService) replicateAsync(media *entity.Media, replicaDrivers []*entity.Driver, replicaFilePath, fileAliasName string, opt *entity.MediaUploadOpts, uploadOpts *driver_lib.UploadFileOpts) {
+	defer func() {
+		if err := os.Remove(replicaFilePath); err != nil {
+			log.Printf("Failed to delete replica temp file %v", replicaFilePath)
+		}
+	}()
+
+	for _, replicaDriver := range replicaDrivers {
+		replicaClient, releaseReplicaClient := u.DriverManager.AcquireDriver(replicaDriver.Slug)
+		location, err := u.uploadToDriver(replicaDriver, replicaClient, replicaFilePath, fileAliasName, opt, uploadOpts)
+		releaseReplicaClient()
+		if err != nil {
+			log.Printf("Error uploading file to replica %v: %v", replicaDriver.Slug, err)
+			location = &entity.MediaLocation{
+				DriverSlug: replicaDriver.Slug,
+				Status:     common.MediaLocationStatusFailed,
+				Error:      err.Error(),
+			}
+		}
+
+		if err := u.repo.Media.UpdateLocation(media, *location); err != nil {
+			log.Printf("Error updating media location: %v", err)
+		}
+	}
+}
+
+// newUploadFileOpts builds the headers and metadata of a media object uploaded through a rule.
+func newUploadFileOpts(rule *entity.Rule, media *entity.Media) *driver_lib.UploadFileOpts {
+	uploadOpts := &driver_lib.UploadFileOpts{
+		Mime:     media.FileMime,
+		Metadata: make(map[string]string),
+	}
+	if media.IsEncrypted() {
+		uploadOpts.Mime = common.EncryptedObjectMime
+	}
+
+	if objectOptions := rule.ObjectOptions; objectOptions != nil {
+		uploadOpts.CacheControl = objectOptions.CacheControl
+		uploadOpts.ContentLanguage = objectOptions.ContentLanguage
+		uploadOpts.StorageClass = objectOptions.StorageClass
+		// the encoding and file name do not apply to the encrypted content
+		if !media.IsEncrypted() {
+			uploadOpts.ContentEncoding = objectOptions.ContentEncoding
+		}
+		if objectOptions.ContentDisposition != "" && !media.IsEncrypted() {
+			uploadOpts.ContentDisposition = mime.FormatMediaType(objectOptions.ContentDisposition, map[string]string{"filename": media.FileOriginalName})
+		}
+		for key, value := range objectOptions.Metadata {
+			uploadOpts.Metadata[key] = value
+		}
 	}
 
-	return &media, nil
+	for key, value := range media.Metadata {
+		uploadOpts.Metadata[key] = value
+	}
+
+	// gotaro metadata can not be overridden by the rule
+	for key, value := range media.GetObjectMetadata() {
+		uploadOpts.Metadata[key] = value
+	}
+	return uploadOpts
+}
+
+func getObjectPath(driver *entity.Driver, opt *entity.MediaUploadOpts, fileAliasName string) string {
+	folder := getTargetFolder(driver, opt)
+	if folder == "/" {
+		return fileAliasName
+	}
+	return folder + "/" + fileAliasName
+}
+
+func getTargetFolder(driver *entity.Driver, opt *entity.MediaUploadOpts) string {
+	folder := driver.GetDefaultFolder()
+	if opt.Directory != "" {
+		optDirectory := strings.Trim(opt.Directory, " ")
+		optDirectory = strings.Trim(optDirectory, "/")
+		folder = optDirectory
+	}
+	return folder
 }
 
 func (u *MediaService) Delete(ruleSlug, fileAliasName string) error {
@@ -154,6 +369,13 @@ func (u *MediaService) FindMedia(ruleSlug, fileAliasName string) (*entity.Media,
 	if media == nil {
 		return nil, nil
 	}
+
+	// encrypted medias are only readable through the download endpoint, never signed
+	if media.IsEncrypted() {
+		media.FilePath = media.GetDownloadPath()
+		return media, nil
+	}
+
 	if !media.IsPublic {
 		signedUrl, err := u.repo.Media.GetCachedSignedUrl(ruleSlug, fileAliasName)
 		if err != nil {
@@ -165,35 +387,226 @@ func (u *MediaService) FindMedia(ruleSlug, fileAliasName string) (*entity.Media,
 		}
 
 		if signedUrl == "" {
-			driver := u.DriverManager.GetDriver(media.DriverSlug)
-			if driver == nil {
-				log.Printf("Error finding driver client")
-				return nil, errors.New(common.ErrDriverNotFoundMsg)
-			}
-			newSignedUrl, err := driver.GetSignedUrl(media.FileAliasName)
+			newSignedUrl, isFromPrimary, err := u.getSignedUrl(media)
 			if err != nil {
-				log.Printf("Error getting signed url: %v", err)
 				return nil, err
 			}
 			media.FilePath = newSignedUrl
 			go func(ruleSlug, fileAliasName, newSignedUrl string) {
 				u.repo.Media.SetCachedSignedUrl(ruleSlug, fileAliasName, newSignedUrl)
-				err = u.repo.Media.SetSignedUrl(media.RuleSlug, media.FileAliasName, newSignedUrl)
+				if !isFromPrimary {
+					return
+				}
+				err := u.repo.Media.SetSignedUrl(media.RuleSlug, media.FileAliasName, newSignedUrl)
 				if err != nil {
 					log.Printf("Error setting signed url: %v", err)
 				}
 			}(ruleSlug, fileAliasName, newSignedUrl)
 		}
 	}
+
+	if media.IsPublic && u.DriverManager.GetDriver(media.DriverSlug) == nil {
+		for _, location := range media.GetReplicaLocations() {
+			replicaUrl, err := u.getLocationUrl(location)
+			if err != nil {
+				log.Printf("Error getting replica url from %v: %v", location.DriverSlug, err)
+				continue
+			}
+			media.FilePath = replicaUrl
+			break
+		}
+	}
 	return media, nil
 }
 
-func (u *MediaService) FindMediaBatch(mediaPaths []string) common.GotaroMap {
+// getSignedUrl signs the media from its primary driver and falls back to the synced replicas
+// when the primary driver is unavailable.
+func (u *MediaService) getSignedUrl(media *entity.Media) (string, bool, error) {
+	var primaryErr error
+	driver, releaseDriver := u.DriverManager.AcquireDriver(media.DriverSlug)
+	defer releaseDriver()
+	if driver == nil {
+		log.Printf("Error finding driver client")
+		primaryErr = errors.New(common.ErrDriverNotFoundMsg)
+	} else {
+		signedUrl, err := driver.GetSignedUrl(media.FileAliasName)
+		if err == nil {
+			return signedUrl, true, nil
+		}
+		log.Printf("Error getting signed url: %v", err)
+		primaryErr = err
+	}
+
+	for _, location := range media.GetReplicaLocations() {
+		replicaUrl, err := u.getLocationUrl(location)
+		if err != nil {
+			log.Printf("Error getting replica url from %v: %v", location.DriverSlug, err)
+			continue
+		}
+		return replicaUrl, false, nil
+	}
+	return "", false, primaryErr
+}
+
+func (u *MediaService) getLocationUrl(location entity.MediaLocation) (string, error) {
+	if location.IsPublic && location.FilePath != "" {
+		return location.FilePath, nil
+	}
+	driver, releaseDriver := u.DriverManager.AcquireDriver(location.DriverSlug)
+	defer releaseDriver()
+	if driver == nil {
+		return "", errors.New(common.ErrDriverClientNotFoundMsg)
+	}
+	return driver.GetSignedUrl(location.ObjectPath)
+}
+
+// Download returns the content of a media through gotaro from its primary driver or a synced replica
+// with its size, to be closed by the caller once sent. An encrypted media is decrypted into a
+// temporary file first, so it is authenticated before anything is sent.
+func (u *MediaService) Download(ruleSlug, fileAliasName string) (*entity.Media, io.ReadCloser, int, error) {
+	media, err := u.repo.Media.FindMedia(ruleSlug, fileAliasName)
+	if err != nil {
+		log.Printf("Error finding media: %v", err)
+		return nil, nil, 0, err
+	}
+	if media == nil {
+		return nil, nil, 0, errors.New(common.ErrMediaNotFoundMsg)
+	}
+
+	locations := []entity.MediaLocation{{DriverSlug: media.DriverSlug, ObjectPath: getMediaObjectPath(media, media.DriverSlug)}}
+	locations = append(locations, media.GetReplicaLocations()...)
+
+	var file *downloadFile
+	for _, location := range locations {
+		file, err = u.downloadLocation(location)
+		if err == nil {
+			break
+		}
+		log.Printf("Error downloading media from %v: %v", location.DriverSlug, err)
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	if !media.IsEncrypted() {
+		fileInfo, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, nil, 0, err
+		}
+		return media, file, int(fileInfo.Size()), nil
+	}
+
+	defer file.Close()
+	content, err := u.decryptFile(media.Encryption, file)
+	if err != nil {
+		log.Printf("Error decrypting media %v: %v", media.ID, err)
+		return nil, nil, 0, errors.New(common.ErrMediaDecryptFailedMsg)
+	}
+	fileInfo, err := content.Stat()
+	if err != nil {
+		content.Close()
+		return nil, nil, 0, err
+	}
+	return media, content, int(fileInfo.Size()), nil
+}
+
+// downloadFile is a downloaded temporary file, removed once closed.
+type downloadFile struct {
+	*os.File
+}
+
+func (f *downloadFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+func (u *MediaService) downloadLocation(location entity.MediaLocation) (*downloadFile, error) {
+	driver, releaseDriver := u.DriverManager.AcquireDriver(location.DriverSlug)
+	defer releaseDriver()
+	if driver == nil {
+		return nil, errors.New(common.ErrDriverClientNotFoundMsg)
+	}
+
+	tempFilePath := common.TemporaryFolder + "/download_" + uuid.NewString()
+	if err := driver.DownloadFile(location.ObjectPath, tempFilePath); err != nil {
+		os.Remove(tempFilePath)
+		return nil, err
+	}
+	file, err := os.Open(tempFilePath)
+	if err != nil {
+		os.Remove(tempFilePath)
+		return nil, err
+	}
+	return &downloadFile{File: file}, nil
+}
+
+// encryptFile encrypts a file into targetFilePath chunk by chunk with a new data key wrapped by the
+// master key.
+func (u *MediaService) encryptFile(filePath, targetFilePath string) (*entity.MediaEncryption, error) {
+	if !u.keyring.IsEnabled() {
+		return nil, errors.New(common.ErrMasterKeyNotSetMsg)
+	}
+
+	dataKey, nonce, err := secret.NewDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	keyID, wrappedKey, err := u.keyring.WrapKey(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	targetFile, err := os.OpenFile(targetFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer targetFile.Close()
+
+	if err := secret.EncryptStream(dataKey, nonce, secret.DataChunkSize, targetFile, file); err != nil {
+		return nil, err
+	}
+	return &entity.MediaEncryption{KeyID: keyID, WrappedKey: wrappedKey, Nonce: nonce, ChunkSize: secret.DataChunkSize}, nil
+}
+
+// decryptFile decrypts a downloaded media object into a temporary file, removed once closed.
+func (u *MediaService) decryptFile(encryption *entity.MediaEncryption, file io.Reader) (*downloadFile, error) {
+	dataKey, err := u.keyring.UnwrapKey(encryption.KeyID, encryption.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.CreateTemp(common.TemporaryFolder, "decrypted_")
+	if err != nil {
+		return nil, err
+	}
+	decryptedFile := &downloadFile{File: content}
+	if err := secret.DecryptStream(dataKey, encryption.Nonce, encryption.ChunkSize, content, file); err != nil {
+		decryptedFile.Close()
+		return nil, err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		decryptedFile.Close()
+		return nil, err
+	}
+	return decryptedFile, nil
+}
+
+// FindMediaBatch finds medias by their gotaro path, skipping the ones of the rules canRead denies.
+func (u *MediaService) FindMediaBatch(mediaPaths []string, canRead func(ruleSlug string) bool) common.GotaroMap {
 	uniqueMediaPaths := common.UniqueArrayString(mediaPaths)
 	resultChan := make(chan *entity.Media, len(uniqueMediaPaths))
 	errChan := make(chan error, len(uniqueMediaPaths))
 	for _, mediaPath := range uniqueMediaPaths {
-		go u.asyncFindMedia(mediaPath, resultChan, errChan)
+		go u.asyncFindMedia(mediaPath, canRead, resultChan, errChan)
 	}
 	var medias []*entity.Media
 	for i := 0; i < len(uniqueMediaPaths); i++ {
@@ -216,7 +629,7 @@ func (u *MediaService) FindMediaBatch(mediaPaths []string) common.GotaroMap {
 	return response
 }
 
-func (u *MediaService) asyncFindMedia(mediaPath string, result chan *entity.Media, errChan chan error) {
+func (u *MediaService) asyncFindMedia(mediaPath string, canRead func(ruleSlug string) bool, result chan *entity.Media, errChan chan error) {
 	// check mediaPath has "gotaro://" prefix
 
 	if !strings.HasPrefix(mediaPath, "gotaro://") {
@@ -230,6 +643,11 @@ func (u *MediaService) asyncFindMedia(mediaPath string, result chan *entity.Medi
 	splitMediaPath := strings.Split(mediaPath, "/")
 	ruleSlug := splitMediaPath[0]
 	fileAliasName := strings.Join(splitMediaPath[1:], "/")
+	if !canRead(ruleSlug) {
+		result <- nil
+		errChan <- nil
+		return
+	}
 	// find media
 	media, err := u.FindMedia(ruleSlug, fileAliasName)
 	if err != nil {
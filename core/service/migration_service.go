@@ -0,0 +1,435 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sibeur/gotaro/core/common"
+	driver_lib "github.com/sibeur/gotaro/core/common/driver"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/repository"
+)
+
+// MigrationService runs the migration jobs. An instance claims a job before running it and renews
+// its lease meanwhile, so a job runs on one instance at a time and is taken over by another one if
+// the instance stops without releasing it.
+type MigrationService struct {
+	repo          *repository.Repository
+	DriverManager *driver_lib.DriverManager
+	// instanceID identifies the instance as the owner of the jobs it runs
+	instanceID  string
+	mutex       sync.Mutex
+	runningJobs map[string]*migrationRun
+}
+
+// migrationRun is a job running on the instance.
+type migrationRun struct {
+	cancel context.CancelFunc
+}
+
+func NewMigrationService(repo *repository.Repository, driverManager *driver_lib.DriverManager) *MigrationService {
+	return &MigrationService{
+		repo:          repo,
+		DriverManager: driverManager,
+		instanceID:    uuid.NewString(),
+		runningJobs:   make(map[string]*migrationRun),
+	}
+}
+
+func (u *MigrationService) FindAll() ([]*entity.MigrationJob, error) {
+	return u.repo.MigrationJob.FindAll()
+}
+
+func (u *MigrationService) FindByID(id string) (*entity.MigrationJob, error) {
+	return u.repo.MigrationJob.FindByID(id)
+}
+
+func (u *MigrationService) Create(job *entity.MigrationJob) error {
+	rule, err := u.repo.Rule.FindBySlug(job.RuleSlug)
+	if err != nil {
+		return err
+	}
+	if rule == nil {
+		return errors.New(common.ErrRuleNotFoundMsg)
+	}
+
+	if job.SourceDriverSlug == job.TargetDriverSlug {
+		return errors.New(common.ErrMigrationSameDriverMsg)
+	}
+
+	targetDriver, err := u.repo.Driver.FindBySlug(job.TargetDriverSlug)
+	if err != nil {
+		return err
+	}
+	if targetDriver == nil {
+		return errors.New(common.ErrDriverNotFoundMsg)
+	}
+	if u.DriverManager.GetDriver(targetDriver.Slug) == nil {
+		return errors.New(common.ErrDriverClientNotFoundMsg)
+	}
+
+	if job.SourceDriverSlug != "" {
+		sourceDriver, err := u.repo.Driver.FindBySlug(job.SourceDriverSlug)
+		if err != nil {
+			return err
+		}
+		if sourceDriver == nil {
+			return errors.New(common.ErrDriverNotFoundMsg)
+		}
+	}
+
+	total, err := u.repo.Media.CountForMigration(job.RuleSlug, job.SourceDriverSlug, job.TargetDriverSlug)
+	if err != nil {
+		return err
+	}
+
+	job.Total = total
+	job.Status = common.MigrationJobStatusPending
+	if err := u.repo.MigrationJob.Create(job); err != nil {
+		return err
+	}
+
+	return u.start(job)
+}
+
+func (u *MigrationService) Pause(id string) (*entity.MigrationJob, error) {
+	return u.stop(id, common.MigrationJobStatusPaused)
+}
+
+func (u *MigrationService) Cancel(id string) (*entity.MigrationJob, error) {
+	return u.stop(id, common.MigrationJobStatusCancelled)
+}
+
+func (u *MigrationService) Resume(id string) (*entity.MigrationJob, error) {
+	job, err := u.repo.MigrationJob.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, errors.New(common.ErrMigrationJobNotFoundMsg)
+	}
+	if job.Status != common.MigrationJobStatusPaused && job.Status != common.MigrationJobStatusFailed {
+		return nil, errors.New(common.ErrMigrationJobStatusInvalidMsg)
+	}
+	if err := u.start(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// StartLeaseSweep takes over, every lease period, the jobs of the instances that stopped while this
+// one keeps running.
+func (u *MigrationService) StartLeaseSweep() {
+	go func() {
+		ticker := time.NewTicker(common.MigrationJobLease)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := u.ResumeInterruptedJobs(); err != nil {
+				log.Printf("Error sweeping migration jobs: %v", err)
+			}
+		}
+	}()
+}
+
+// ResumeInterruptedJobs takes over the running jobs whose instance stopped without releasing them,
+// the jobs whose lease is still renewed keep running on their instance.
+func (u *MigrationService) ResumeInterruptedJobs() error {
+	jobs, err := u.repo.MigrationJob.FindByStatus(common.MigrationJobStatusRunning)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if time.Now().Before(job.LeaseExpiredAt) {
+			continue
+		}
+		log.Printf("Resuming migration job %v from media %v", job.ID, job.LastMediaID)
+		if err := u.start(job); err != nil {
+			// another instance may have taken the job over first
+			log.Printf("Error resuming migration job %v: %v", job.ID, err)
+		}
+	}
+	return nil
+}
+
+// start claims a job for the instance and runs it, it fails when the job can not be started or
+// another instance claimed it first.
+func (u *MigrationService) start(job *entity.MigrationJob) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if _, isRunning := u.runningJobs[job.ID]; isRunning {
+		return errors.New(common.ErrMigrationJobStatusInvalidMsg)
+	}
+
+	if job.StartedAt.IsZero() {
+		job.StartedAt = time.Now()
+	}
+	claimedJob, err := u.repo.MigrationJob.Claim(job, u.instanceID)
+	if err != nil {
+		return err
+	}
+	if claimedJob == nil {
+		return errors.New(common.ErrMigrationJobStatusInvalidMsg)
+	}
+	*job = *claimedJob
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &migrationRun{cancel: cancel}
+	u.runningJobs[job.ID] = run
+	go u.renewLease(ctx, run, job)
+	go u.run(ctx, run, job)
+	return nil
+}
+
+// renewLease keeps the lease of a running job until it stops, and stops the job once the instance
+// no longer owns it, e.g. it was paused or cancelled through another instance.
+func (u *MigrationService) renewLease(ctx context.Context, run *migrationRun, job *entity.MigrationJob) {
+	ticker := time.NewTicker(common.MigrationJobLease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		isOwned, err := u.repo.MigrationJob.RenewLease(job)
+		if err != nil {
+			log.Printf("Error renewing lease of migration job %v: %v", job.ID, err)
+			continue
+		}
+		if !isOwned {
+			log.Printf("Migration job %v is no longer owned by this instance, stopping it", job.ID)
+			u.release(job.ID, run)
+			return
+		}
+	}
+}
+
+// release stops a job running on the instance, unless it was started again meanwhile.
+func (u *MigrationService) release(id string, run *migrationRun) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	run.cancel()
+	if u.runningJobs[id] == run {
+		delete(u.runningJobs, id)
+	}
+}
+
+func (u *MigrationService) stop(id string, status string) (*entity.MigrationJob, error) {
+	job, err := u.repo.MigrationJob.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, errors.New(common.ErrMigrationJobNotFoundMsg)
+	}
+
+	if !common.CanMigrationJobMove(job.Status, status) {
+		return nil, errors.New(common.ErrMigrationJobStatusInvalidMsg)
+	}
+
+	// the job only moves when nothing changed its status since it was read, the instance running it
+	// stops once it sees it lost the job
+	job.Status = status
+	if status == common.MigrationJobStatusCancelled {
+		job.FinishedAt = time.Now()
+	}
+	isUpdated, err := u.repo.MigrationJob.UpdateStatus(job, "")
+	if err != nil {
+		return nil, err
+	}
+	if !isUpdated {
+		return nil, errors.New(common.ErrMigrationJobStatusInvalidMsg)
+	}
+
+	u.mutex.Lock()
+	if run, isRunning := u.runningJobs[id]; isRunning {
+		run.cancel()
+		delete(u.runningJobs, id)
+	}
+	u.mutex.Unlock()
+
+	return job, nil
+}
+
+// finish ends a job run by the instance, unless it was paused, cancelled or taken over meanwhile.
+func (u *MigrationService) finish(run *migrationRun, job *entity.MigrationJob, status string, err error) {
+	u.release(job.ID, run)
+
+	owner := job.Owner
+	job.Status = status
+	job.FinishedAt = time.Now()
+	if err != nil {
+		job.LastError = err.Error()
+	}
+	isUpdated, err := u.repo.MigrationJob.UpdateStatus(job, owner)
+	if err != nil {
+		log.Printf("Error updating migration job %v: %v", job.ID, err)
+		return
+	}
+	if !isUpdated {
+		log.Printf("Migration job %v changed meanwhile, not marking it %v", job.ID, status)
+	}
+}
+
+func (u *MigrationService) run(ctx context.Context, run *migrationRun, job *entity.MigrationJob) {
+	ticker := time.NewTicker(time.Second / time.Duration(job.GetRateLimit()))
+	defer ticker.Stop()
+
+	for {
+		medias, err := u.repo.Media.FindForMigration(job.RuleSlug, job.SourceDriverSlug, job.TargetDriverSlug, job.LastMediaID, common.DefaultMigrationBatchSize)
+		if err != nil {
+			log.Printf("Error finding medias for migration job %v: %v", job.ID, err)
+			u.finish(run, job, common.MigrationJobStatusFailed, err)
+			return
+		}
+
+		if len(medias) == 0 {
+			u.finish(run, job, common.MigrationJobStatusCompleted, nil)
+			return
+		}
+
+		for _, media := range medias {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := u.migrateMedia(job, media); err != nil {
+				log.Printf("Error migrating media %v: %v", media.ID, err)
+				job.AddFailure(media.ID, err)
+			} else {
+				job.Migrated++
+			}
+			job.Processed++
+			job.LastMediaID = media.ID
+
+			isOwned, err := u.repo.MigrationJob.UpdateProgress(job)
+			if err != nil {
+				log.Printf("Error updating migration job %v: %v", job.ID, err)
+			} else if !isOwned {
+				log.Printf("Migration job %v is no longer owned by this instance, stopping it", job.ID)
+				u.release(job.ID, run)
+				return
+			}
+		}
+	}
+}
+
+func (u *MigrationService) migrateMedia(job *entity.MigrationJob, media *entity.Media) error {
+	sourceClient, releaseSourceClient := u.DriverManager.AcquireDriver(media.DriverSlug)
+	defer releaseSourceClient()
+	if sourceClient == nil {
+		return errors.New(common.ErrMigrationSourceDriverMissingMsg)
+	}
+
+	targetDriver, err := u.repo.Driver.FindBySlug(job.TargetDriverSlug)
+	if err != nil {
+		return err
+	}
+	if targetDriver == nil {
+		return errors.New(common.ErrDriverNotFoundMsg)
+	}
+	targetClient, releaseTargetClient := u.DriverManager.AcquireDriver(targetDriver.Slug)
+	defer releaseTargetClient()
+	if targetClient == nil {
+		return errors.New(common.ErrDriverClientNotFoundMsg)
+	}
+
+	// the object keeps its path so the gotaro file path of the media does not change
+	objectPath := media.FileAliasName
+
+	sourceAttrs, err := sourceClient.GetObjectAttrs(objectPath)
+	if err != nil {
+		return err
+	}
+
+	tempFilePath := fmt.Sprintf("%s/migration_%s_%s", common.TemporaryFolder, job.ID, media.ID)
+	if err := sourceClient.DownloadFile(objectPath, tempFilePath); err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.Remove(tempFilePath); err != nil {
+			log.Printf("Failed to delete temp file %v", tempFilePath)
+		}
+	}()
+
+	checksum, err := common.GetFileMD5(tempFilePath)
+	if err != nil {
+		return err
+	}
+	if len(sourceAttrs.MD5) > 0 && !bytes.Equal(sourceAttrs.MD5, checksum) {
+		return errors.New(common.ErrMigrationChecksumMismatchMsg)
+	}
+
+	// the storage class is left to the target bucket default
+	mediaLink, err := targetClient.UploadFile(tempFilePath, objectPath, &driver_lib.UploadFileOpts{
+		Mime:               media.FileMime,
+		CacheControl:       sourceAttrs.CacheControl,
+		ContentDisposition: sourceAttrs.ContentDisposition,
+		ContentLanguage:    sourceAttrs.ContentLanguage,
+		ContentEncoding:    sourceAttrs.ContentEncoding,
+		Metadata:           sourceAttrs.Metadata,
+	})
+	if err != nil {
+		return err
+	}
+
+	targetAttrs, err := targetClient.GetObjectAttrs(objectPath)
+	if err != nil {
+		return err
+	}
+	if len(targetAttrs.MD5) > 0 && !bytes.Equal(targetAttrs.MD5, checksum) {
+		return errors.New(common.ErrMigrationChecksumMismatchMsg)
+	}
+	if targetAttrs.Size != sourceAttrs.Size {
+		return errors.New(common.ErrMigrationChecksumMismatchMsg)
+	}
+
+	isPublic, _ := targetClient.IsStorageAssetPublic()
+	sourceDriverSlug := media.DriverSlug
+
+	targetLocation := entity.MediaLocation{
+		DriverSlug:         targetDriver.Slug,
+		ObjectPath:         objectPath,
+		FilePath:           mediaLink,
+		FilePathFromDriver: targetDriver.GetFilePathFromDriver(objectPath),
+		IsPublic:           isPublic,
+		Status:             common.MediaLocationStatusSynced,
+		UpdatedAt:          time.Now(),
+	}
+	locations := []entity.MediaLocation{targetLocation}
+	for _, location := range media.Locations {
+		if location.DriverSlug == sourceDriverSlug || location.DriverSlug == targetDriver.Slug {
+			continue
+		}
+		locations = append(locations, location)
+	}
+
+	media.DriverSlug = targetDriver.Slug
+	media.FilePath = mediaLink
+	media.FilePathFromDriver = targetLocation.FilePathFromDriver
+	media.IsPublic = isPublic
+	media.Locations = locations
+	if err := u.repo.Media.UpdateStorage(media); err != nil {
+		return err
+	}
+
+	if job.DeleteSource {
+		if err := sourceClient.DeleteFile(objectPath); err != nil {
+			log.Printf("Error deleting source object %v from %v: %v", objectPath, sourceDriverSlug, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/repository"
+)
+
+type PersonalAccessTokenService struct {
+	repo *repository.Repository
+}
+
+func NewPersonalAccessTokenService(repo *repository.Repository) *PersonalAccessTokenService {
+	return &PersonalAccessTokenService{repo: repo}
+}
+
+func (u *PersonalAccessTokenService) FindAllByClientID(clientID string) ([]*entity.PersonalAccessToken, error) {
+	return u.repo.PersonalAccessToken.FindAllByClientID(clientID)
+}
+
+// Create issues a personal access token to a client and returns it, only its hash is stored so it
+// can not be read again. The token scopes have to be scopes of the client.
+func (u *PersonalAccessTokenService) Create(personalAccessToken *entity.PersonalAccessToken) (string, error) {
+	apiClient, err := u.repo.APIClient.FindByID(personalAccessToken.ClientID)
+	if err != nil {
+		return "", err
+	}
+	if apiClient == nil {
+		return "", errors.New(common.ErrAPIClientNotFoundMsg)
+	}
+	for _, scope := range personalAccessToken.Scopes {
+		if !slices.Contains(apiClient.Scopes, scope) {
+			return "", errors.New(common.ErrPersonalAccessTokenScopeMsg)
+		}
+	}
+
+	prefix, err := common.SecureRandomString(8)
+	if err != nil {
+		return "", err
+	}
+	secret, err := common.SecureRandomString(32)
+	if err != nil {
+		return "", err
+	}
+	token := common.PersonalAccessTokenPrefix + prefix + "_" + secret
+
+	personalAccessToken.Prefix = prefix
+	personalAccessToken.TokenHash = common.GetStringSHA256(token)
+	if err := u.repo.PersonalAccessToken.Create(personalAccessToken); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (u *PersonalAccessTokenService) Delete(clientID string, id string) error {
+	personalAccessToken, err := u.repo.PersonalAccessToken.FindByID(clientID, id)
+	if err != nil {
+		return err
+	}
+	if personalAccessToken == nil {
+		return errors.New(common.ErrPersonalAccessTokenNotFoundMsg)
+	}
+	return u.repo.PersonalAccessToken.Delete(id)
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/repository"
+)
+
+type RateLimitService struct {
+	repo *repository.Repository
+}
+
+// RateLimitStatus is the state of the rate limit window of a request.
+type RateLimitStatus struct {
+	Limit     uint64
+	Remaining uint64
+	ResetAt   time.Time
+	IsAllowed bool
+}
+
+// UploadUsage are the bytes a client uploaded today and this month, in UTC, with its quotas.
+type UploadUsage struct {
+	DayBytes   uint64                  `json:"day_bytes"`
+	MonthBytes uint64                  `json:"month_bytes"`
+	Limits     *entity.APIClientLimits `json:"limits"`
+}
+
+// UploadReservation are the bytes of an upload counted against the quotas of the day and month it
+// was reserved in, released from the same ones.
+type UploadReservation struct {
+	DayKey   string
+	MonthKey string
+	Size     uint64
+}
+
+func NewRateLimitService(repo *repository.Repository) *RateLimitService {
+	return &RateLimitService{repo: repo}
+}
+
+// AllowRequest counts a request of a client to an endpoint class in the current one minute window,
+// nil when the client has no limit on the class.
+func (u *RateLimitService) AllowRequest(clientID string, endpointClass string) (*RateLimitStatus, error) {
+	limits, err := u.findLimits(clientID)
+	if err != nil {
+		return nil, err
+	}
+	limit := uint64(limits.GetRequestsPerMinute(endpointClass))
+	if limit == 0 {
+		return nil, nil
+	}
+
+	windowStart := time.Now().Truncate(time.Minute)
+	resetAt := windowStart.Add(time.Minute)
+	key := fmt.Sprintf(common.RateLimitRequestsKey, clientID, endpointClass, windowStart.Unix())
+	count, err := u.repo.RateLimit.Increment(key, 1, uint64(time.Until(resetAt).Seconds())+1)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &RateLimitStatus{Limit: limit, ResetAt: resetAt, IsAllowed: count <= limit}
+	if status.IsAllowed {
+		status.Remaining = limit - count
+	}
+	return status, nil
+}
+
+// ReserveUpload counts the bytes of an upload against the daily and monthly quotas of a client,
+// refusing it when either would be exceeded. The bytes of a failed upload have to be released.
+func (u *RateLimitService) ReserveUpload(clientID string, size uint64) (*UploadReservation, error) {
+	limits, err := u.findLimits(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	dayKey, monthKey := getUploadQuotaKeys(clientID)
+	reservation := &UploadReservation{DayKey: dayKey, MonthKey: monthKey, Size: size}
+	dayBytes, err := u.repo.RateLimit.Increment(dayKey, size, uint64((time.Hour * 48).Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	monthBytes, err := u.repo.RateLimit.Increment(monthKey, size, uint64((time.Hour * 24 * 32).Seconds()))
+	if err != nil {
+		u.releaseUploadKey(dayKey, size)
+		return nil, err
+	}
+
+	if limits != nil && ((limits.UploadBytesPerDay > 0 && dayBytes > limits.UploadBytesPerDay) ||
+		(limits.UploadBytesPerMonth > 0 && monthBytes > limits.UploadBytesPerMonth)) {
+		u.ReleaseUpload(reservation)
+		return nil, errors.New(common.ErrUploadQuotaExceededMsg)
+	}
+	return reservation, nil
+}
+
+// ReleaseUpload gives back the bytes reserved for an upload that failed.
+func (u *RateLimitService) ReleaseUpload(reservation *UploadReservation) {
+	u.releaseUploadKey(reservation.DayKey, reservation.Size)
+	u.releaseUploadKey(reservation.MonthKey, reservation.Size)
+}
+
+func (u *RateLimitService) GetUploadUsage(clientID string) (*UploadUsage, error) {
+	limits, err := u.findLimits(clientID)
+	if err != nil {
+		return nil, err
+	}
+	dayKey, monthKey := getUploadQuotaKeys(clientID)
+	dayBytes, err := u.repo.RateLimit.GetCount(dayKey)
+	if err != nil {
+		return nil, err
+	}
+	monthBytes, err := u.repo.RateLimit.GetCount(monthKey)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadUsage{
+		DayBytes:   dayBytes,
+		MonthBytes: monthBytes,
+		Limits:     limits,
+	}, nil
+}
+
+// findLimits returns the limits of a caller, the default limits of its issuer for an OIDC caller.
+func (u *RateLimitService) findLimits(clientID string) (*entity.APIClientLimits, error) {
+	if limits := u.repo.Auth.FindOIDCLimits(clientID); limits != nil {
+		return limits, nil
+	}
+	return u.repo.APIClient.FindLimits(clientID)
+}
+
+func (u *RateLimitService) releaseUploadKey(key string, size uint64) {
+	if err := u.repo.RateLimit.Decrement(key, size); err != nil {
+		log.Printf("Error releasing upload quota %v: %v", key, err)
+	}
+}
+
+func getUploadQuotaKeys(clientID string) (string, string) {
+	now := time.Now().UTC()
+	return fmt.Sprintf(common.RateLimitUploadQuotaKey, clientID, now.Format("20060102")),
+		fmt.Sprintf(common.RateLimitUploadQuotaKey, clientID, now.Format("200601"))
+}
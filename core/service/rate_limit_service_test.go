@@ -0,0 +1,96 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/common/oidc"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/repository"
+	"github.com/sibeur/gotaro/core/service"
+)
+
+func newTestRateLimitService(t *testing.T, apiClients ...*entity.APIClient) *service.RateLimitService {
+	verifier, err := oidc.NewVerifier([]*oidc.Issuer{
+		{
+			Name:     "corp",
+			URL:      "https://idp.example.com",
+			Audience: "gotaro",
+			Limits:   &oidc.Limits{UploadRequestsPerMinute: 5, UploadBytesPerDay: 100},
+		},
+		{Name: "partner", URL: "https://partner.example.com", Audience: "gotaro"},
+	}, 0)
+	if err != nil {
+		t.Fatalf("Error create verifier: %v", err)
+	}
+
+	// the issuer limits are read from the verifier, the auth repository needs no database for them
+	return service.NewRateLimitService(&repository.Repository{
+		Auth:      newFakeAuthRepository(repository.NewAuthRepository(nil, nil, nil, verifier)),
+		APIClient: newFakeApiClientRepository(apiClients...),
+		RateLimit: newFakeRateLimitRepository(),
+	})
+}
+
+func TestAllowRequestIssuerLimits(t *testing.T) {
+	apiClient := &entity.APIClient{ID: "client-1", Limits: &entity.APIClientLimits{UploadRequestsPerMinute: 10}}
+	rateLimitService := newTestRateLimitService(t, apiClient)
+
+	tests := []struct {
+		name          string
+		clientID      string
+		endpointClass string
+		expectedLimit uint64
+	}{
+		{name: "caller of an issuer with limits", clientID: "corp:billing-service", endpointClass: common.EndpointClassUpload, expectedLimit: 5},
+		{name: "class without issuer limit", clientID: "corp:billing-service", endpointClass: common.EndpointClassRead},
+		{name: "caller of an issuer without limits", clientID: "partner:billing-service", endpointClass: common.EndpointClassUpload},
+		{name: "api client", clientID: apiClient.ID, endpointClass: common.EndpointClassUpload, expectedLimit: 10},
+	}
+	for _, test := range tests {
+		status, err := rateLimitService.AllowRequest(test.clientID, test.endpointClass)
+		if err != nil {
+			t.Fatalf("%v: Error allow request: %v", test.name, err)
+		}
+		if test.expectedLimit == 0 {
+			if status != nil {
+				t.Errorf("%v: Expected no limit, got %v", test.name, status.Limit)
+			}
+			continue
+		}
+		if status == nil || status.Limit != test.expectedLimit || status.Remaining != test.expectedLimit-1 || !status.IsAllowed {
+			t.Errorf("%v: Expected limit %v with %v remaining, got %+v", test.name, test.expectedLimit, test.expectedLimit-1, status)
+		}
+	}
+}
+
+func TestReserveUploadIssuerLimits(t *testing.T) {
+	rateLimitService := newTestRateLimitService(t)
+
+	tests := []struct {
+		name     string
+		clientID string
+		size     uint64
+		expected string
+	}{
+		{name: "within the daily quota", clientID: "corp:billing-service", size: 60},
+		{name: "over the daily quota", clientID: "corp:billing-service", size: 60, expected: common.ErrUploadQuotaExceededMsg},
+		{name: "other caller of the issuer", clientID: "corp:report-service", size: 60},
+		{name: "caller of an issuer without limits", clientID: "partner:billing-service", size: 1000},
+	}
+	for _, test := range tests {
+		_, err := rateLimitService.ReserveUpload(test.clientID, test.size)
+		if (test.expected == "" && err != nil) || (test.expected != "" && (err == nil || err.Error() != test.expected)) {
+			t.Errorf("%v: Expected %v, got %v", test.name, test.expected, err)
+		}
+	}
+
+	// the refused upload is released, the usage shows the issuer limits
+	usage, err := rateLimitService.GetUploadUsage("corp:billing-service")
+	if err != nil {
+		t.Fatalf("Error get upload usage: %v", err)
+	}
+	if usage.DayBytes != 60 || usage.Limits == nil || usage.Limits.UploadBytesPerDay != 100 {
+		t.Errorf("Expected 60 bytes of the 100 a day, got %v bytes with limits %+v", usage.DayBytes, usage.Limits)
+	}
+}
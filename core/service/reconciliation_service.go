@@ -0,0 +1,257 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+	driver_lib "github.com/sibeur/gotaro/core/common/driver"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/repository"
+)
+
+type ReconciliationService struct {
+	repo          *repository.Repository
+	DriverManager *driver_lib.DriverManager
+}
+
+func NewReconciliationService(repo *repository.Repository, driverManager *driver_lib.DriverManager) *ReconciliationService {
+	return &ReconciliationService{repo: repo, DriverManager: driverManager}
+}
+
+func (u *ReconciliationService) FindByDriver(driverSlug string) ([]*entity.ReconciliationJob, error) {
+	return u.repo.ReconciliationJob.FindByDriver(driverSlug)
+}
+
+func (u *ReconciliationService) FindByID(id string) (*entity.ReconciliationJob, error) {
+	return u.repo.ReconciliationJob.FindByID(id)
+}
+
+// Create starts a reconciliation job comparing the objects of a driver with the medias stored in it.
+func (u *ReconciliationService) Create(job *entity.ReconciliationJob) error {
+	driver, err := u.repo.Driver.FindBySlug(job.DriverSlug)
+	if err != nil {
+		return err
+	}
+	if driver == nil {
+		return errors.New(common.ErrDriverNotFoundMsg)
+	}
+
+	if u.DriverManager.GetDriver(driver.Slug) == nil {
+		return errors.New(common.ErrDriverClientNotFoundMsg)
+	}
+
+	if job.UntrackedAction == "" {
+		job.UntrackedAction = common.ReconciliationActionReport
+	}
+
+	if job.UntrackedAction == common.ReconciliationActionImport {
+		if job.ImportRuleSlug == "" {
+			return errors.New(common.ErrReconciliationImportRuleMissingMsg)
+		}
+		rule, err := u.repo.Rule.FindBySlug(job.ImportRuleSlug)
+		if err != nil {
+			return err
+		}
+		if rule == nil {
+			return errors.New(common.ErrRuleNotFoundMsg)
+		}
+	}
+
+	job.Status = common.ReconciliationJobStatusRunning
+	if err := u.repo.ReconciliationJob.Create(job); err != nil {
+		return err
+	}
+
+	go u.run(job, driver)
+	return nil
+}
+
+func (u *ReconciliationService) run(job *entity.ReconciliationJob, driver *entity.Driver) {
+	if err := u.reconcile(job, driver); err != nil {
+		log.Printf("Error reconciling driver %v: %v", driver.Slug, err)
+		job.Status = common.ReconciliationJobStatusFailed
+		job.LastError = err.Error()
+	} else {
+		job.Status = common.ReconciliationJobStatusCompleted
+	}
+	job.FinishedAt = time.Now()
+
+	if err := u.repo.ReconciliationJob.Update(job); err != nil {
+		log.Printf("Error updating reconciliation job %v: %v", job.ID, err)
+	}
+}
+
+func (u *ReconciliationService) reconcile(job *entity.ReconciliationJob, driver *entity.Driver) error {
+	driverClient, releaseDriverClient := u.DriverManager.AcquireDriver(driver.Slug)
+	defer releaseDriverClient()
+	if driverClient == nil {
+		return errors.New(common.ErrDriverClientNotFoundMsg)
+	}
+
+	medias, err := u.repo.Media.FindAllByDriver(driver.Slug)
+	if err != nil {
+		return err
+	}
+	mediasByPath := make(map[string]*entity.Media)
+	for _, media := range medias {
+		objectPath := getMediaObjectPath(media, driver.Slug)
+		if !strings.HasPrefix(objectPath, job.Prefix) {
+			continue
+		}
+		job.MediaCount++
+		mediasByPath[objectPath] = media
+	}
+
+	// the objects of soft deleted medias are kept on purpose, they are not untracked
+	deletedMedias, err := u.repo.Media.FindAllDeletedByDriver(driver.Slug)
+	if err != nil {
+		return err
+	}
+	deletedPaths := make(map[string]bool)
+	for _, media := range deletedMedias {
+		deletedPaths[getMediaObjectPath(media, driver.Slug)] = true
+	}
+
+	// the objects are compared as they are listed, only the medias are held
+	foundPaths := make(map[string]bool)
+	err = driverClient.WalkObjects(job.Prefix, func(object *driver_lib.ObjectAttrs) error {
+		// quarantined objects were already reported by a previous run
+		if strings.HasPrefix(object.Name, common.ReconciliationQuarantineFolder+"/") {
+			return nil
+		}
+		job.ObjectCount++
+
+		if media, isTracked := mediasByPath[object.Name]; isTracked {
+			foundPaths[object.Name] = true
+			if uint64(object.Size) != media.GetObjectSize() {
+				job.AddSizeMismatch(entity.ReconciliationItem{
+					ObjectPath: object.Name,
+					ObjectSize: object.Size,
+					MediaID:    media.ID,
+					RuleSlug:   media.RuleSlug,
+					MediaSize:  media.FileSize,
+				})
+			}
+			return nil
+		}
+
+		if deletedPaths[object.Name] {
+			job.DeletedMediaObjectCount++
+			return nil
+		}
+
+		item := entity.ReconciliationItem{
+			ObjectPath: object.Name,
+			ObjectSize: object.Size,
+		}
+		if err := u.handleUntrackedObject(job, driver, driverClient, object, &item); err != nil {
+			log.Printf("Error handling untracked object %v: %v", object.Name, err)
+			item.Error = err.Error()
+		}
+		job.AddUntrackedObject(item)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for objectPath, media := range mediasByPath {
+		if foundPaths[objectPath] {
+			continue
+		}
+		job.AddMissingObject(entity.ReconciliationItem{
+			ObjectPath: objectPath,
+			MediaID:    media.ID,
+			RuleSlug:   media.RuleSlug,
+			MediaSize:  media.FileSize,
+		})
+	}
+
+	return nil
+}
+
+func (u *ReconciliationService) handleUntrackedObject(job *entity.ReconciliationJob, driver *entity.Driver, driverClient driver_lib.DriverClientUseCase, object *driver_lib.ObjectAttrs, item *entity.ReconciliationItem) error {
+	switch job.UntrackedAction {
+	case common.ReconciliationActionImport:
+		media := newMediaFromObject(job.ImportRuleSlug, driver, object)
+		if err := u.repo.Media.Create(media); err != nil {
+			return err
+		}
+		item.MediaID = media.ID
+		item.RuleSlug = media.RuleSlug
+		job.ImportedCount++
+	case common.ReconciliationActionQuarantine:
+		quarantinePath := common.ReconciliationQuarantineFolder + "/" + object.Name
+		if err := driverClient.CopyFile(object.Name, quarantinePath); err != nil {
+			return err
+		}
+		if err := driverClient.DeleteFile(object.Name); err != nil {
+			return err
+		}
+		job.QuarantinedCount++
+	case common.ReconciliationActionDelete:
+		if err := driverClient.DeleteFile(object.Name); err != nil {
+			return err
+		}
+		job.DeletedCount++
+	default:
+		return nil
+	}
+	item.Action = job.UntrackedAction
+	return nil
+}
+
+func getMediaObjectPath(media *entity.Media, driverSlug string) string {
+	for _, location := range media.Locations {
+		if location.DriverSlug == driverSlug && location.ObjectPath != "" {
+			return location.ObjectPath
+		}
+	}
+	return media.FileAliasName
+}
+
+func newMediaFromObject(ruleSlug string, driver *entity.Driver, object *driver_lib.ObjectAttrs) *entity.Media {
+	fileName := path.Base(object.Name)
+	fileExt := path.Ext(fileName)
+	fileMime := object.ContentType
+	if fileMime == "" {
+		fileMime = mime.TypeByExtension(fileExt)
+	}
+
+	fileDirectory := path.Dir(object.Name)
+	if fileDirectory == "." {
+		fileDirectory = "/"
+	}
+
+	filePathFromDriver := driver.GetFilePathFromDriver(object.Name)
+	location := entity.MediaLocation{
+		DriverSlug:         driver.Slug,
+		ObjectPath:         object.Name,
+		FilePath:           object.MediaLink,
+		FilePathFromDriver: filePathFromDriver,
+		IsPublic:           driver.IsPublic,
+		Status:             common.MediaLocationStatusSynced,
+		UpdatedAt:          time.Now(),
+	}
+
+	return &entity.Media{
+		RuleSlug:           ruleSlug,
+		DriverSlug:         driver.Slug,
+		FileOriginalName:   fileName,
+		FileAliasName:      object.Name,
+		FileExt:            fileExt,
+		FileMime:           fileMime,
+		FileSize:           uint64(object.Size),
+		FilePath:           object.MediaLink,
+		FilePathFromDriver: filePathFromDriver,
+		FileDirectory:      fileDirectory,
+		IsCommit:           true,
+		IsPublic:           driver.IsPublic,
+		Locations:          []entity.MediaLocation{location},
+	}
+}
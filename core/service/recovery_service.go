@@ -0,0 +1,245 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+	driver_lib "github.com/sibeur/gotaro/core/common/driver"
+	"github.com/sibeur/gotaro/core/common/secret"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/repository"
+)
+
+type RecoveryService struct {
+	repo          *repository.Repository
+	DriverManager *driver_lib.DriverManager
+}
+
+// RecoveryReport counts what a recovery did, or would do on a dry run, with the objects of a driver.
+type RecoveryReport struct {
+	DriverSlug       string            `json:"driver_slug"`
+	IsDryRun         bool              `json:"is_dry_run"`
+	ObjectCount      uint64            `json:"object_count"`
+	CreatedCount     uint64            `json:"created_count"`
+	RepairedCount    uint64            `json:"repaired_count"`
+	UnchangedCount   uint64            `json:"unchanged_count"`
+	SkippedCount     uint64            `json:"skipped_count"`
+	MissingRuleCount uint64            `json:"missing_rule_count"`
+	MissingRuleSlugs []string          `json:"missing_rule_slugs,omitempty"`
+	Errors           map[string]string `json:"errors,omitempty"`
+}
+
+func NewRecoveryService(repo *repository.Repository, driverManager *driver_lib.DriverManager) *RecoveryService {
+	return &RecoveryService{repo: repo, DriverManager: driverManager}
+}
+
+// Recover rebuilds the medias stored in a driver from the gotaro metadata of its objects. Missing
+// medias are created and existing ones get their location in the driver repaired. Objects without
+// gotaro metadata, of a rule that no longer exists and of deleted medias are skipped.
+func (u *RecoveryService) Recover(driverSlug string, isDryRun bool) (*RecoveryReport, error) {
+	driver, err := u.repo.Driver.FindBySlug(driverSlug)
+	if err != nil {
+		return nil, err
+	}
+	if driver == nil {
+		return nil, errors.New(common.ErrDriverNotFoundMsg)
+	}
+
+	driverClient, releaseDriverClient := u.DriverManager.AcquireDriver(driver.Slug)
+	defer releaseDriverClient()
+	if driverClient == nil {
+		return nil, errors.New(common.ErrDriverClientNotFoundMsg)
+	}
+
+	report := &RecoveryReport{DriverSlug: driver.Slug, IsDryRun: isDryRun, Errors: make(map[string]string)}
+	checkedRules := make(map[string]bool)
+	err = driverClient.WalkObjects("", func(object *driver_lib.ObjectAttrs) error {
+		if strings.HasPrefix(object.Name, common.ReconciliationQuarantineFolder+"/") {
+			return nil
+		}
+		report.ObjectCount++
+
+		mediaID := object.Metadata[common.ObjectMetadataMediaID]
+		ruleSlug := object.Metadata[common.ObjectMetadataRuleSlug]
+		if mediaID == "" || ruleSlug == "" {
+			report.SkippedCount++
+			return nil
+		}
+
+		isRuleExist, isChecked := checkedRules[ruleSlug]
+		if !isChecked {
+			rule, err := u.repo.Rule.FindBySlug(ruleSlug)
+			if err != nil {
+				report.Errors[object.Name] = err.Error()
+				return nil
+			}
+			isRuleExist = rule != nil
+			checkedRules[ruleSlug] = isRuleExist
+			if !isRuleExist {
+				report.MissingRuleSlugs = append(report.MissingRuleSlugs, ruleSlug)
+			}
+		}
+		// a media of a missing rule could not be served, its object is left for the rule to be restored
+		if !isRuleExist {
+			report.SkippedCount++
+			report.MissingRuleCount++
+			return nil
+		}
+
+		if err := u.recoverObject(report, driver, object, isDryRun); err != nil {
+			log.Printf("Error recovering object %v: %v", object.Name, err)
+			report.Errors[object.Name] = err.Error()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (u *RecoveryService) recoverObject(report *RecoveryReport, driver *entity.Driver, object *driver_lib.ObjectAttrs, isDryRun bool) error {
+	media, err := u.repo.Media.FindByID(object.Metadata[common.ObjectMetadataMediaID])
+	if err != nil {
+		return err
+	}
+
+	if media == nil {
+		if isDryRun {
+			report.CreatedCount++
+			return nil
+		}
+		media, err = newMediaFromObjectMetadata(driver, object)
+		if err != nil {
+			return err
+		}
+		if err := u.repo.Media.Create(media); err != nil {
+			return err
+		}
+		report.CreatedCount++
+		return nil
+	}
+
+	if !media.DeletedAt.IsZero() {
+		report.SkippedCount++
+		return nil
+	}
+
+	location := newSyncedMediaLocation(driver, object)
+	isLocated := false
+	for i, existingLocation := range media.Locations {
+		if existingLocation.DriverSlug != driver.Slug {
+			continue
+		}
+		isLocated = true
+		if existingLocation.ObjectPath == object.Name && existingLocation.Status == common.MediaLocationStatusSynced {
+			report.UnchangedCount++
+			return nil
+		}
+		media.Locations[i] = location
+	}
+	if !isLocated {
+		media.Locations = append(media.Locations, location)
+	}
+
+	report.RepairedCount++
+	if isDryRun {
+		return nil
+	}
+	return u.repo.Media.UpdateStorage(media)
+}
+
+func newSyncedMediaLocation(driver *entity.Driver, object *driver_lib.ObjectAttrs) entity.MediaLocation {
+	return entity.MediaLocation{
+		DriverSlug:         driver.Slug,
+		ObjectPath:         object.Name,
+		FilePath:           object.MediaLink,
+		FilePathFromDriver: driver.GetFilePathFromDriver(object.Name),
+		IsPublic:           driver.IsPublic,
+		Status:             common.MediaLocationStatusSynced,
+		UpdatedAt:          time.Now(),
+	}
+}
+
+// newMediaFromObjectMetadata rebuilds a media from the gotaro metadata stored with its object.
+func newMediaFromObjectMetadata(driver *entity.Driver, object *driver_lib.ObjectAttrs) (*entity.Media, error) {
+	metadata := object.Metadata
+
+	fileAliasName := metadata[common.ObjectMetadataAlias]
+	if fileAliasName == "" {
+		fileAliasName = object.Name
+	}
+
+	fileOriginalName := metadata[common.ObjectMetadataOriginalName]
+	if fileOriginalName == "" {
+		fileOriginalName = path.Base(object.Name)
+	}
+
+	fileExt := path.Ext(object.Name)
+	fileMime := object.ContentType
+	if fileMime == "" {
+		fileMime = mime.TypeByExtension(fileExt)
+	}
+
+	fileDirectory := path.Dir(fileAliasName)
+	if fileDirectory == "." {
+		fileDirectory = "/"
+	}
+
+	// objects uploaded before the commit flag was stored are considered committed
+	isCommit, err := strconv.ParseBool(metadata[common.ObjectMetadataCommit])
+	if err != nil {
+		isCommit = true
+	}
+
+	// the content type of an encrypted object is not the one of the file
+	fileSize := uint64(object.Size)
+	var encryption *entity.MediaEncryption
+	if keyID := metadata[common.ObjectMetadataKeyID]; keyID != "" {
+		wrappedKey, err := base64.StdEncoding.DecodeString(metadata[common.ObjectMetadataWrappedKey])
+		if err != nil {
+			return nil, err
+		}
+		nonce, err := base64.StdEncoding.DecodeString(metadata[common.ObjectMetadataNonce])
+		if err != nil {
+			return nil, err
+		}
+		// objects encrypted before chunking have no chunk size
+		chunkSize, _ := strconv.Atoi(metadata[common.ObjectMetadataChunkSize])
+		encryption = &entity.MediaEncryption{KeyID: keyID, WrappedKey: wrappedKey, Nonce: nonce, ChunkSize: chunkSize}
+		fileMime = mime.TypeByExtension(fileExt)
+		// an encrypted object always holds the GCM tag of each chunk, a smaller one was cut
+		fileSize, err = secret.GetPlaintextSize(fileSize, chunkSize)
+		if err != nil {
+			return nil, errors.New(common.ErrMediaObjectCorruptMsg)
+		}
+	}
+
+	location := newSyncedMediaLocation(driver, object)
+	return &entity.Media{
+		ID:                 metadata[common.ObjectMetadataMediaID],
+		RuleSlug:           metadata[common.ObjectMetadataRuleSlug],
+		DriverSlug:         driver.Slug,
+		FileOriginalName:   fileOriginalName,
+		FileAliasName:      fileAliasName,
+		FileExt:            fileExt,
+		FileMime:           fileMime,
+		FileSize:           fileSize,
+		FilePath:           location.FilePath,
+		FilePathFromDriver: location.FilePathFromDriver,
+		FileDirectory:      fileDirectory,
+		IsCommit:           isCommit,
+		IsPublic:           location.IsPublic,
+		Checksum:           metadata[common.ObjectMetadataChecksum],
+		Locations:          []entity.MediaLocation{location},
+		Encryption:         encryption,
+	}, nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"sort"
+
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/repository"
+)
+
+type RoleService struct {
+	repo *repository.Repository
+}
+
+func NewRoleService(repo *repository.Repository) *RoleService {
+	return &RoleService{repo: repo}
+}
+
+// FindAll returns the built-in roles followed by the custom ones.
+func (u *RoleService) FindAll() ([]*entity.Role, error) {
+	roles := make([]*entity.Role, 0)
+	for _, slug := range getBuiltinRoleSlugs() {
+		roles = append(roles, newBuiltinRole(slug))
+	}
+
+	customRoles, err := u.repo.Role.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	return append(roles, customRoles...), nil
+}
+
+func (u *RoleService) FindBySlug(slug string) (*entity.Role, error) {
+	if _, isBuiltin := common.BuiltinRolePermissions[slug]; isBuiltin {
+		return newBuiltinRole(slug), nil
+	}
+	return u.repo.Role.FindBySlug(slug)
+}
+
+func (u *RoleService) Create(role *entity.Role) error {
+	existingRole, err := u.FindBySlug(role.Slug)
+	if err != nil {
+		return err
+	}
+	if existingRole != nil {
+		return errors.New(common.ErrRoleAlreadyExistMsg)
+	}
+	if err := validatePermissionGrants(role.Permissions); err != nil {
+		return err
+	}
+	return u.repo.Role.Create(role)
+}
+
+// Update replaces the name and permissions of a custom role, the clients having it get the new
+// permissions on their next request.
+func (u *RoleService) Update(role *entity.Role) error {
+	existingRole, err := u.FindBySlug(role.Slug)
+	if err != nil {
+		return err
+	}
+	if existingRole == nil {
+		return errors.New(common.ErrRoleNotFoundMsg)
+	}
+	if existingRole.IsBuiltin {
+		return errors.New(common.ErrRoleBuiltinMsg)
+	}
+	if err := validatePermissionGrants(role.Permissions); err != nil {
+		return err
+	}
+
+	role.ID = existingRole.ID
+	role.CreatedAt = existingRole.CreatedAt
+	return u.repo.Role.Update(role)
+}
+
+// Delete removes a custom role no api client has anymore.
+func (u *RoleService) Delete(slug string) error {
+	existingRole, err := u.FindBySlug(slug)
+	if err != nil {
+		return err
+	}
+	if existingRole == nil {
+		return errors.New(common.ErrRoleNotFoundMsg)
+	}
+	if existingRole.IsBuiltin {
+		return errors.New(common.ErrRoleBuiltinMsg)
+	}
+
+	client, err := u.repo.APIClient.FindByScope(slug)
+	if err != nil {
+		return err
+	}
+	if client != nil {
+		return errors.New(common.ErrRoleInUseMsg)
+	}
+	return u.repo.Role.Delete(slug)
+}
+
+// GetPermissions collects the permissions granted by roles, the unknown ones grant nothing.
+func (u *RoleService) GetPermissions(roleSlugs []string) (common.Permissions, error) {
+	permissions := common.Permissions{}
+	for _, slug := range roleSlugs {
+		if builtinPermissions, isBuiltin := common.BuiltinRolePermissions[slug]; isBuiltin {
+			permissions = append(permissions, builtinPermissions...)
+			continue
+		}
+		rolePermissions, err := u.repo.Role.FindPermissions(slug)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, rolePermissions...)
+	}
+	return permissions, nil
+}
+
+func validatePermissionGrants(grants []string) error {
+	if slices.ContainsFunc(grants, func(grant string) bool { return !common.IsPermissionGrantValid(grant) }) {
+		return errors.New(common.ErrPermissionInvalidMsg)
+	}
+	return nil
+}
+
+func getBuiltinRoleSlugs() []string {
+	slugs := make([]string, 0, len(common.BuiltinRolePermissions))
+	for slug := range common.BuiltinRolePermissions {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+	return slugs
+}
+
+func newBuiltinRole(slug string) *entity.Role {
+	return &entity.Role{
+		Slug:        slug,
+		Name:        slug,
+		Permissions: common.BuiltinRolePermissions[slug],
+		IsBuiltin:   true,
+	}
+}
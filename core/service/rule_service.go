@@ -4,16 +4,18 @@ import (
 	"errors"
 
 	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/common/secret"
 	"github.com/sibeur/gotaro/core/entity"
 	"github.com/sibeur/gotaro/core/repository"
 )
 
 type RuleService struct {
-	repo *repository.Repository
+	repo    *repository.Repository
+	keyring *secret.Keyring
 }
 
-func NewRuleService(repo *repository.Repository) *RuleService {
-	return &RuleService{repo: repo}
+func NewRuleService(repo *repository.Repository, keyring *secret.Keyring) *RuleService {
+	return &RuleService{repo: repo, keyring: keyring}
 }
 
 func (u *RuleService) FindAll() ([]*entity.Rule, error) {
@@ -30,10 +32,16 @@ func (u *RuleService) Create(rule *entity.Rule) error {
 	if err == nil && existingRule != nil {
 		return errors.New(common.ErrRuleAlreadyExistMsg)
 	}
+	if rule.IsEncrypted && !u.keyring.IsEnabled() {
+		return errors.New(common.ErrMasterKeyNotSetMsg)
+	}
 	return u.repo.Rule.Create(rule)
 }
 
 func (u *RuleService) Update(rule *entity.Rule) error {
+	if rule.IsEncrypted && !u.keyring.IsEnabled() {
+		return errors.New(common.ErrMasterKeyNotSetMsg)
+	}
 	return u.repo.Rule.Update(rule)
 }
 
@@ -2,23 +2,44 @@ package service
 
 import (
 	"github.com/sibeur/gotaro/core/common/driver"
+	"github.com/sibeur/gotaro/core/common/secret"
 	"github.com/sibeur/gotaro/core/repository"
 )
 
 type Service struct {
-	Rule      *RuleService
-	Driver    *DriverService
-	Media     *MediaService
-	ApiClient *ApiClientService
-	Auth      *AuthService
+	Rule                *RuleService
+	Driver              *DriverService
+	Media               *MediaService
+	ApiClient           *ApiClientService
+	Role                *RoleService
+	RateLimit           *RateLimitService
+	PersonalAccessToken *PersonalAccessTokenService
+	UploadToken         *UploadTokenService
+	Audit               *AuditService
+	Auth                *AuthService
+	Migration           *MigrationService
+	Reconciliation      *ReconciliationService
+	ClusterSync         *ClusterSyncService
+	Recovery            *RecoveryService
 }
 
-func NewService(repo *repository.Repository, driverManager *driver.DriverManager) *Service {
+func NewService(repo *repository.Repository, driverManager *driver.DriverManager, keyring *secret.Keyring) *Service {
+	healthMonitor := NewHealthMonitor(driverManager)
+	driverService := NewDriverService(repo, driverManager, healthMonitor, keyring)
 	return &Service{
-		Rule:      NewRuleService(repo),
-		Driver:    NewDriverService(repo, driverManager),
-		Media:     NewMediaService(repo, driverManager),
-		ApiClient: NewApiClientService(repo),
-		Auth:      NewAuthService(repo),
+		Rule:                NewRuleService(repo, keyring),
+		Driver:              driverService,
+		Media:               NewMediaService(repo, driverManager, healthMonitor, keyring),
+		ApiClient:           NewApiClientService(repo, keyring),
+		Role:                NewRoleService(repo),
+		RateLimit:           NewRateLimitService(repo),
+		PersonalAccessToken: NewPersonalAccessTokenService(repo),
+		UploadToken:         NewUploadTokenService(repo),
+		Audit:               NewAuditService(repo),
+		Auth:                NewAuthService(repo, keyring),
+		Migration:           NewMigrationService(repo, driverManager),
+		Reconciliation:      NewReconciliationService(repo, driverManager),
+		ClusterSync:         NewClusterSyncService(repo, driverService),
+		Recovery:            NewRecoveryService(repo, driverManager),
 	}
 }
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/sibeur/gotaro/core/common"
+	"github.com/sibeur/gotaro/core/entity"
+	"github.com/sibeur/gotaro/core/repository"
+)
+
+type UploadTokenService struct {
+	repo *repository.Repository
+}
+
+func NewUploadTokenService(repo *repository.Repository) *UploadTokenService {
+	return &UploadTokenService{repo: repo}
+}
+
+// Create mints an upload token expiring after ttl, it is bound to a rule with constraints narrowing
+// the rule ones.
+func (u *UploadTokenService) Create(uploadToken *entity.UploadToken, ttl time.Duration) (string, error) {
+	rule, err := u.repo.Rule.FindBySlug(uploadToken.RuleSlug)
+	if err != nil {
+		return "", err
+	}
+	if rule == nil {
+		return "", errors.New(common.ErrRuleNotFoundMsg)
+	}
+	if uploadToken.MaxSize > rule.MaxSize {
+		return "", errors.New(common.ErrUploadTokenMaxSizeMsg)
+	}
+	for key := range uploadToken.Metadata {
+		if strings.HasPrefix(strings.ToLower(key), "gotaro-") {
+			return "", errors.New(common.ErrUploadTokenMetadataMsg)
+		}
+	}
+
+	if ttl <= 0 {
+		ttl = common.DefaultUploadTokenTTL
+	}
+	uploadToken.ExpiredAt = time.Now().Add(min(ttl, common.MaxUploadTokenTTL))
+	return u.repo.Auth.CreateUploadToken(uploadToken)
+}
+
+// Authenticate verifies an upload token and checks its client can still use tokens, the same way
+// as the access tokens.
+func (u *UploadTokenService) Authenticate(token string) (*entity.UploadToken, error) {
+	uploadToken, err := u.repo.Auth.ParseUploadToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	validAfter, isActive, err := u.repo.APIClient.FindTokensValidAfter(uploadToken.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if !isActive || common.IsIssuedBeforeValidAfter(uploadToken.IssuedAt, validAfter) {
+		return nil, errors.New(common.ErrJWTTokenRevokedMsg)
+	}
+	return uploadToken, nil
+}
+
+// ReserveFile counts a file against the max files of a token. The file of a failed upload has to be
+// released.
+func (u *UploadTokenService) ReserveFile(uploadToken *entity.UploadToken) error {
+	if uploadToken.MaxFiles == 0 {
+		return nil
+	}
+
+	key := fmt.Sprintf(common.RateLimitUploadTokenFilesKey, uploadToken.ID)
+	count, err := u.repo.RateLimit.Increment(key, 1, uint64(time.Until(uploadToken.ExpiredAt).Seconds())+1)
+	if err != nil {
+		return err
+	}
+	if count > uint64(uploadToken.MaxFiles) {
+		u.ReleaseFile(uploadToken)
+		return errors.New(common.ErrUploadTokenFilesMsg)
+	}
+	return nil
+}
+
+// ReleaseFile gives back the file reserved for an upload that failed.
+func (u *UploadTokenService) ReleaseFile(uploadToken *entity.UploadToken) {
+	if uploadToken.MaxFiles == 0 {
+		return
+	}
+	key := fmt.Sprintf(common.RateLimitUploadTokenFilesKey, uploadToken.ID)
+	if err := u.repo.RateLimit.Decrement(key, 1); err != nil {
+		log.Printf("Error releasing file of upload token %v: %v", uploadToken.ID, err)
+	}
+}